@@ -11,19 +11,117 @@ import (
 	"github.com/knadh/koanf/v2"
 	flags "github.com/spf13/pflag"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// k is the global configuration
-var k = koanf.New(".")
+// current holds the global configuration. Reloads replace it as a whole, so readers never see a koanf instance that
+// is being written to.
+var current atomic.Pointer[koanf.Koanf]
+
+func init() {
+	current.Store(koanf.New("."))
+}
 
 var ErrInvalidConfigParameter = errors.New("invalid config parameter")
 
+// configFile is the path of the configuration file loaded by InitConfig.
+var configFile string
+
+// flagSet holds the parsed command line flags so that they can be reapplied on reload.
+var flagSet *flags.FlagSet
+
+// restartRequiredKeys lists all configuration parameters that can't be applied while the application is running.
+var restartRequiredKeys = []string{
+	"http.host",
+	"http.port",
+	"logging.method",
+	"data.database_file",
+	"data.backend",
+	"data.memory.capacity",
+	"derived",
+	"recorder.queue_size",
+	"recorder.batch_size",
+	"recorder.flush_interval",
+	"mqtt",
+}
+
+// reloadHooks are called after every successful configuration reload.
+var reloadHooks []func()
+var reloadLock sync.Mutex
+
+// ReloadResult describes the changes a configuration reload made.
+type ReloadResult struct {
+	// Applied contains all changed parameters that have been applied.
+	Applied []string
+	// RestartRequired contains all changed parameters that only take effect after a restart.
+	RestartRequired []string
+}
+
 // InitConfig initializes the configuration.
 func InitConfig() error {
+	// Configure and parse flagset
+	f := NewFlagSet("config")
+	f.Usage = func() {
+		fmt.Println("Could not parse flags! For more information see 'excubitor --help'")
+		os.Exit(1)
+	}
+
+	f.String("host", "0.0.0.0", "Host the HTTP Server shall run on.")
+	f.Int("port", 8080, "Port the HTTP Server shall run on.")
+
+	return InitConfigWithFlags(f, os.Args[1:])
+}
+
+// NewFlagSet returns a flag set with the flags every command of Excubitor understands.
+func NewFlagSet(name string) *flags.FlagSet {
+	f := flags.NewFlagSet(name, flags.ContinueOnError)
+	f.String("config", "config.yml", "Path to the config file.")
+
+	return f
+}
+
+// InitConfigWithFlags initializes the configuration after parsing args into a flag set returned by NewFlagSet.
+// Commands other than the server use it to add flags of their own.
+func InitConfigWithFlags(f *flags.FlagSet, args []string) error {
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+
+	var err error
+	configFile, err = f.GetString("config")
+	if err != nil {
+		return fmt.Errorf("could not init config file: %w", err)
+	}
+
+	flagSet = f
+
+	conf := koanf.New(".")
+	if err := load(conf); err != nil {
+		return err
+	}
+
+	// Check config for errors
+	if err := validate(conf); err != nil {
+		return err
+	}
+
+	current.Store(conf)
+
+	return nil
+}
+
+// load loads defaults, the config file, environment variables and flags into the given koanf instance.
+func load(conf *koanf.Koanf) error {
 	// Load default values
-	err := k.Load(confmap.Provider(map[string]interface{}{
+	err := conf.Load(confmap.Provider(map[string]interface{}{
 		"main.print_startup_banner":          true,
+		"main.hostname":                      "",
+		"main.labels":                        map[string]string{},
 		"logging.log_level":                  "INFO",
 		"logging.method":                     "CONSOLE",
 		"http.host":                          "0.0.0.0",
@@ -34,53 +132,75 @@ func InitConfig() error {
 		"http.auth.jwt.access_token_secret":  "",
 		"http.auth.jwt.refresh_token_secret": "",
 		"data.module_clock":                  "5s",
-		"data.storage_time":                  "720h",
+		"data.storage_time":                  "48h",
 		"data.purge_cycle":                   "1h",
+		"data.rollups.minute_storage_time":   "720h",
+		"data.rollups.hour_storage_time":     "8760h",
+		"data.rollups.compaction_cycle":      "1m",
+		"data.events_storage_time":           "720h",
+		"data.retention_policies":            []map[string]interface{}{},
+		"data.max_database_size":             "0",
 		"data.database_file":                 "history.db",
+		"data.backend":                       "sqlite",
+		"data.memory.capacity":               10000,
+		"data.backup.cycle":                  "0",
+		"data.backup.directory":              "backups",
+		"data.backup.keep":                   7,
+		"data.procfs_root":                   "/proc",
+		"data.sysfs_root":                    "/sys",
+		"pubsub.queue_size":                  64,
+		"pubsub.drop_policy":                 "drop_oldest",
+		"recorder.include":                   []string{"#"},
+		"recorder.exclude":                   []string{},
+		"recorder.queue_size":                1024,
+		"recorder.batch_size":                100,
+		"recorder.flush_interval":            "1s",
+		"mqtt.enabled":                       false,
+		"mqtt.broker":                        "tcp://localhost:1883",
+		"mqtt.client_id":                     "",
+		"mqtt.username":                      "",
+		"mqtt.password":                      "",
+		"mqtt.topic_prefix":                  "excubitor",
+		"mqtt.monitors":                      []string{"#"},
+		"mqtt.qos":                           0,
+		"mqtt.retained":                      false,
+		"mqtt.tls.enabled":                   false,
+		"mqtt.tls.ca_file":                   "",
+		"mqtt.tls.cert_file":                 "",
+		"mqtt.tls.key_file":                  "",
+		"mqtt.tls.insecure_skip_verify":      false,
+		"pubsub.publish_policies": []map[string]interface{}{
+			{"monitor": "CPU.CpuInfo", "mode": "on_change", "heartbeat": "10m"},
+		},
 	}, "."), nil)
 	if err != nil {
 		return err
 	}
 
-	// Configure and parse flagset
-	f := flags.NewFlagSet("config", flags.ContinueOnError)
-	f.Usage = func() {
-		fmt.Println("Could not parse flags! For more information see 'excubitor --help'")
-		os.Exit(1)
-	}
-
-	f.String("config", "config.yml", "Path to the config file.")
-	f.String("host", "0.0.0.0", "Host the HTTP Server shall run on.")
-	f.Int("port", 8080, "Port the HTTP Server shall run on.")
-	if err := f.Parse(os.Args[1:]); err != nil {
-		return err
-	}
-
-	configFile, err := f.GetString("config")
-	if err != nil {
-		return fmt.Errorf("could not init config file: %w", err)
-	}
-
 	// Load YAML Config file
-	if err := k.Load(file.Provider(configFile), yaml.Parser()); err != nil {
+	if err := conf.Load(file.Provider(configFile), yaml.Parser()); err != nil {
 		return fmt.Errorf("could not init config file: %w", err)
 	}
 
 	// Load environment variables
-	err = k.Load(env.Provider("EXCUBITOR_", ".", func(s string) string {
+	err = conf.Load(env.Provider("EXCUBITOR_", ".", func(s string) string {
 		return strings.Replace(strings.Replace(strings.ToLower(strings.TrimPrefix(s, "EXCUBITOR_")), "_", ".", -1), "-", "_", -1)
 	}), nil)
 	if err != nil {
 		return fmt.Errorf("could not init environment variable configuration: %w", err)
 	}
 
+	if flagSet == nil {
+		return nil
+	}
+
 	// Load flagset into configuration
-	err = k.Load(posflag.ProviderWithFlag(f, ".", nil, func(flag *flags.Flag) (string, interface{}) {
+	err = conf.Load(posflag.ProviderWithFlag(flagSet, ".", nil, func(flag *flags.Flag) (string, interface{}) {
 		switch flag.Name {
 		case "host":
-			return "http.host", posflag.FlagVal(f, flag)
+			return "http.host", posflag.FlagVal(flagSet, flag)
 		case "port":
-			return "http.port", posflag.FlagVal(f, flag)
+			return "http.port", posflag.FlagVal(flagSet, flag)
 		default:
 			return "", ""
 		}
@@ -89,32 +209,181 @@ func InitConfig() error {
 		return fmt.Errorf("could not init configuration through flags: %w", err)
 	}
 
-	// Check config for errors
-	err = checkConfig()
-	if err != nil {
-		return err
+	return nil
+}
+
+// Reload reads the configuration from all sources again and applies every parameter that can be changed at runtime.
+// If the new configuration is invalid, the current configuration is kept and an error is returned.
+func Reload() (*ReloadResult, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	fresh := koanf.New(".")
+	if err := load(fresh); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := validate(fresh); err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	keep := map[string]interface{}{}
+
+	running := GetConfig().All()
+	changed := fresh.All()
+
+	// Parameters removed from all sources are changes as well.
+	for key := range running {
+		if _, ok := changed[key]; !ok {
+			changed[key] = nil
+		}
+	}
+
+	for key, value := range changed {
+		previous, ok := running[key]
+		if ok && reflect.DeepEqual(previous, value) {
+			continue
+		}
+
+		if requiresRestart(key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+			if ok {
+				keep[key] = previous
+			} else {
+				fresh.Delete(key)
+			}
+
+			continue
+		}
+
+		result.Applied = append(result.Applied, key)
+	}
+
+	// Keep the values of parameters that can't be changed at runtime so that GetConfig reflects the running state.
+	if err := fresh.Load(confmap.Provider(keep, "."), nil); err != nil {
+		return nil, err
+	}
+
+	current.Store(fresh)
+
+	sort.Strings(result.Applied)
+	sort.Strings(result.RestartRequired)
+
+	for _, hook := range reloadHooks {
+		hook()
+	}
+
+	return result, nil
 }
 
-// GetConfig returns a reference to the global config object.
+// RegisterReloadHook registers a function that is called after every successful configuration reload.
+func RegisterReloadHook(hook func()) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	reloadHooks = append(reloadHooks, hook)
+}
+
+// GetConfigFile returns the path of the loaded configuration file.
+func GetConfigFile() string {
+	return configFile
+}
+
+// requiresRestart reports whether a change of the given parameter can only be applied by restarting the application.
+func requiresRestart(key string) bool {
+	for _, restartKey := range restartRequiredKeys {
+		if key == restartKey || strings.HasPrefix(key, restartKey+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetConfig returns the global configuration. The returned instance isn't changed by reloads, which replace it, so
+// callers reading several related parameters should use the same instance for all of them.
 func GetConfig() *koanf.Koanf {
-	return k
+	return current.Load()
+}
+
+// Override replaces the global configuration by a copy with the given parameters loaded on top of it and returns a
+// function restoring the previous one. It is meant for tests, as it neither validates the parameters nor runs the
+// reload hooks.
+func Override(parameters map[string]interface{}) (func(), error) {
+	previous := GetConfig()
+
+	overridden := previous.Copy()
+	if err := overridden.Load(confmap.Provider(parameters, "."), nil); err != nil {
+		return nil, err
+	}
+
+	current.Store(overridden)
+
+	return func() {
+		current.Store(previous)
+	}, nil
 }
 
 // checkConfig returns an error if certain configuration parameters are out of spec.
 func checkConfig() error {
-	if k.String("http.auth.jwt.access_token_secret") == "" {
+	return validate(GetConfig())
+}
+
+// validate returns an error if certain configuration parameters of the given koanf instance are out of spec.
+func validate(conf *koanf.Koanf) error {
+	if conf.String("http.auth.jwt.access_token_secret") == "" {
 		return fmt.Errorf("%w: %s", ErrInvalidConfigParameter, "access token secret is not set")
 	}
 
-	if k.String("http.auth.jwt.refresh_token_secret") == "" {
+	if conf.String("http.auth.jwt.refresh_token_secret") == "" {
 		return fmt.Errorf("%w: %s", ErrInvalidConfigParameter, "refresh token secret is not set")
 	}
 
-	if k.Int("http.port") < 1 || k.Int("http.port") > 65535 {
-		return fmt.Errorf("%w: %s %d", ErrInvalidConfigParameter, "port needs to be at least 1 and lower than 65536. Is:", k.Int("http.port"))
+	if conf.Int("http.port") < 1 || conf.Int("http.port") > 65535 {
+		return fmt.Errorf("%w: %s %d", ErrInvalidConfigParameter, "port needs to be at least 1 and lower than 65536. Is:", conf.Int("http.port"))
+	}
+
+	for _, key := range []string{"data.module_clock", "data.purge_cycle", "data.rollups.compaction_cycle"} {
+		duration, err := time.ParseDuration(conf.String(key))
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("%w: %s %s %s", ErrInvalidConfigParameter, key, "needs to be a positive duration. Is:", conf.String(key))
+		}
+	}
+
+	// Storage times may be given in days, weeks and years as well.
+	for _, key := range []string{
+		"data.storage_time",
+		"data.rollups.minute_storage_time",
+		"data.rollups.hour_storage_time",
+		"data.events_storage_time",
+	} {
+		duration, err := ParseDuration(conf.String(key))
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("%w: %s %s %s", ErrInvalidConfigParameter, key, "needs to be a positive duration. Is:", conf.String(key))
+		}
+	}
+
+	if size, err := ParseSize(conf.String("data.max_database_size")); err != nil || size < 0 {
+		return fmt.Errorf("%w: %s %s", ErrInvalidConfigParameter, "data.max_database_size needs to be a size like 512MB or 0. Is:", conf.String("data.max_database_size"))
+	}
+
+	if cycle, err := ParseDuration(conf.String("data.backup.cycle")); err != nil || cycle < 0 {
+		return fmt.Errorf("%w: %s %s", ErrInvalidConfigParameter, "data.backup.cycle needs to be a duration or 0. Is:", conf.String("data.backup.cycle"))
+	}
+
+	if conf.Int("data.backup.keep") < 1 {
+		return fmt.Errorf("%w: %s %d", ErrInvalidConfigParameter, "data.backup.keep needs to be at least 1. Is:", conf.Int("data.backup.keep"))
+	}
+
+	if conf.Exists("pubsub.queue_size") && conf.Int("pubsub.queue_size") < 1 {
+		return fmt.Errorf("%w: %s %d", ErrInvalidConfigParameter, "pubsub.queue_size needs to be at least 1. Is:", conf.Int("pubsub.queue_size"))
+	}
+
+	switch policy := conf.String("pubsub.drop_policy"); policy {
+	case "", "drop_oldest", "drop_newest", "disconnect":
+	default:
+		return fmt.Errorf("%w: %s %s", ErrInvalidConfigParameter, "pubsub.drop_policy needs to be one of drop_oldest, drop_newest or disconnect. Is:", policy)
 	}
 
 	return nil
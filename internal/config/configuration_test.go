@@ -1,16 +1,19 @@
 package config
 
 import (
+	"fmt"
 	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/v2"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestCheckConfig(t *testing.T) {
 	// HTTP port too low
 
-	err := k.Load(confmap.Provider(map[string]interface{}{
+	err := GetConfig().Load(confmap.Provider(map[string]interface{}{
 		"http.port":                          0,
 		"http.auth.jwt.access_token_secret":  "abcde",
 		"http.auth.jwt.refresh_token_secret": "abcde",
@@ -26,7 +29,7 @@ func TestCheckConfig(t *testing.T) {
 
 	// HTTP port too high
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	err = GetConfig().Load(confmap.Provider(map[string]interface{}{
 		"http.port":                          65536,
 		"http.auth.jwt.access_token_secret":  "abcde",
 		"http.auth.jwt.refresh_token_secret": "abcde",
@@ -42,7 +45,7 @@ func TestCheckConfig(t *testing.T) {
 
 	// Access Token Secret not set
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	err = GetConfig().Load(confmap.Provider(map[string]interface{}{
 		"http.auth.jwt.access_token_secret": "",
 	}, "."), nil)
 	if err != nil {
@@ -56,7 +59,7 @@ func TestCheckConfig(t *testing.T) {
 
 	// Refresh Token Secret not set
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	err = GetConfig().Load(confmap.Provider(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "abcde",
 		"http.auth.jwt.refresh_token_secret": "",
 	}, "."), nil)
@@ -109,5 +112,81 @@ func TestInitConfigENV(t *testing.T) {
 		return
 	}
 
-	assert.Equal(t, "TRACE", k.String("logging.log_level"))
+	assert.Equal(t, "TRACE", GetConfig().String("logging.log_level"))
+}
+
+func TestReload(t *testing.T) {
+	previousConfigFile, previousFlagSet, previousConfig := configFile, flagSet, GetConfig()
+	defer func() {
+		configFile, flagSet = previousConfigFile, previousFlagSet
+		current.Store(previousConfig)
+	}()
+
+	configFile = filepath.Join(t.TempDir(), "config.yml")
+	flagSet = nil
+
+	writeConfig := func(logLevel string, port int, extra string) {
+		content := extra + fmt.Sprintf("logging:\n  log_level: %s\nhttp:\n  port: %d\n  auth:\n    jwt:\n      access_token_secret: abcde\n      refresh_token_secret: abcde\n", logLevel, port)
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("INFO", 8080, "custom: value\n")
+
+	conf := koanf.New(".")
+	if err := load(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	current.Store(conf)
+
+	hookCalls := 0
+	RegisterReloadHook(func() {
+		hookCalls++
+	})
+
+	// Live and restart-only changes
+
+	writeConfig("DEBUG", 9090, "")
+
+	result, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, result.Applied, "logging.log_level")
+	assert.Contains(t, result.RestartRequired, "http.port")
+	assert.Contains(t, result.Applied, "custom")
+	assert.False(t, GetConfig().Exists("custom"))
+	assert.Equal(t, "DEBUG", GetConfig().String("logging.log_level"))
+	assert.Equal(t, 8080, GetConfig().Int("http.port"))
+	assert.Equal(t, 1, hookCalls)
+
+	// Invalid configuration is rejected
+
+	writeConfig("TRACE", 0, "")
+
+	_, err = Reload()
+	assert.ErrorIs(t, err, ErrInvalidConfigParameter)
+	assert.Equal(t, "DEBUG", GetConfig().String("logging.log_level"))
+	assert.Equal(t, 1, hookCalls)
+}
+
+func TestOverride(t *testing.T) {
+	previous := GetConfig()
+
+	restore, err := Override(map[string]interface{}{
+		"test.override": "value",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "value", GetConfig().String("test.override"))
+	assert.False(t, previous.Exists("test.override"))
+
+	restore()
+
+	assert.Same(t, previous, GetConfig())
 }
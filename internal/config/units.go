@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// longDurationUnits are the units ParseDuration accepts in addition to the ones of time.ParseDuration.
+var longDurationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+var longDurationPattern = regexp.MustCompile(`([0-9]*\.?[0-9]+)([dwy])`)
+
+// ParseDuration parses a duration string like time.ParseDuration, but also accepts the units d (days), w (weeks) and
+// y (365 days), e.g. "90d" or "1y12h".
+func ParseDuration(duration string) (time.Duration, error) {
+	var err error
+
+	converted := longDurationPattern.ReplaceAllStringFunc(duration, func(match string) string {
+		parts := longDurationPattern.FindStringSubmatch(match)
+
+		value, parseErr := strconv.ParseFloat(parts[1], 64)
+		if parseErr != nil {
+			err = parseErr
+			return match
+		}
+
+		return strconv.FormatFloat(value*longDurationUnits[parts[2]].Hours(), 'f', -1, 64) + "h"
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", duration, err)
+	}
+
+	parsed, err := time.ParseDuration(converted)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", duration)
+	}
+
+	return parsed, nil
+}
+
+var sizePattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)\s*([KMGT]?)I?B?$`)
+
+var sizeUnits = map[string]float64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// ParseSize parses a size in bytes, which may carry one of the units KB, MB, GB or TB as powers of 1024,
+// e.g. "512MB". KiB, K and so on are accepted as well.
+func ParseSize(size string) (int64, error) {
+	parts := sizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(size)))
+	if parts == nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", size, err)
+	}
+
+	return int64(value * sizeUnits[parts[2]]), nil
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for duration, expected := range map[string]time.Duration{
+		"90s":     90 * time.Second,
+		"720h":    720 * time.Hour,
+		"1d":      24 * time.Hour,
+		"1.5d":    36 * time.Hour,
+		"2w":      14 * 24 * time.Hour,
+		"1y":      365 * 24 * time.Hour,
+		"1y12h":   365*24*time.Hour + 12*time.Hour,
+		"1d30m":   24*time.Hour + 30*time.Minute,
+		"-1d":     -24 * time.Hour,
+		"1d500ms": 24*time.Hour + 500*time.Millisecond,
+	} {
+		parsed, err := ParseDuration(duration)
+		if assert.NoError(t, err, duration) {
+			assert.Equal(t, expected, parsed, duration)
+		}
+	}
+
+	for _, duration := range []string{"", "d", "1x", "one day", "1dd"} {
+		_, err := ParseDuration(duration)
+		assert.Error(t, err, duration)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"0":      0,
+		"1024":   1024,
+		"100B":   100,
+		"512KB":  512 << 10,
+		"512kb":  512 << 10,
+		"1.5MiB": 3 << 19,
+		"2 GB":   2 << 30,
+		"1T":     1 << 40,
+	} {
+		parsed, err := ParseSize(size)
+		if assert.NoError(t, err, size) {
+			assert.Equal(t, expected, parsed, size)
+		}
+	}
+
+	for _, size := range []string{"", "MB", "12 apples", "-1MB"} {
+		_, err := ParseSize(size)
+		assert.Error(t, err, size)
+	}
+}
@@ -1,19 +1,25 @@
 package ctx
 
 import (
+	"fmt"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
 	"sync"
 	"time"
 )
 
 var clockOnce sync.Once
 
+// failing holds the modules whose last tick failed, so that a failing module is reported only once.
+var failing = map[string]bool{}
+
 func startClock() {
 	clockOnce.Do(func() {
-		clockString := config.GetConfig().String("data.module_clock")
-		clock, err := time.ParseDuration(clockString)
+		clock, err := readClock()
 		if err != nil {
-			context.logger.Fatal("Could not parse module clock from configuration. Check your configuration values!")
+			logging.GetLogger().Fatal("Could not parse module clock from configuration. Check your configuration values!")
 			panic(err)
 		}
 
@@ -21,10 +27,56 @@ func startClock() {
 			for {
 				modules := GetContext().GetModules()
 				for _, module := range modules {
-					module.TickFunction()
+					start := time.Now()
+					tick(module)
+					GetContext().setTickDuration(module.Name, time.Since(start))
 				}
 				time.Sleep(clock)
+
+				// Re-read the clock so that configuration reloads take effect on the next cycle.
+				newClock, err := readClock()
+				if err != nil {
+					logging.GetLogger().Error(fmt.Sprintf("Could not parse module clock from configuration. Keeping %s! Reason: %s", clock, err))
+					continue
+				}
+
+				clock = newClock
 			}
 		}()
 	})
 }
+
+// tick calls the tick function of a module.
+// A panicking module is reported as failed instead of taking down the clock and with it all other modules.
+func tick(module modules.Module) {
+	defer func() {
+		if reason := recover(); reason != nil {
+			if failing[module.Name] {
+				return
+			}
+
+			failing[module.Name] = true
+
+			logging.GetLogger().Error(fmt.Sprintf("Module %s failed! Reason: %v", module.Name, reason))
+
+			events.Emit(events.Event{
+				Type:       events.TypeModuleFailed,
+				Severity:   events.SeverityError,
+				Source:     module.Name,
+				Message:    fmt.Sprint(reason),
+				Attributes: map[string]string{"module": module.Name},
+			})
+
+			return
+		}
+
+		delete(failing, module.Name)
+	}()
+
+	module.TickFunction()
+}
+
+// readClock parses the module clock from the configuration.
+func readClock() (time.Duration, error) {
+	return time.ParseDuration(config.GetConfig().String("data.module_clock"))
+}
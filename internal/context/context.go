@@ -5,15 +5,17 @@ import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
 	"sync"
+	"time"
 )
 
 var singletonOnce sync.Once
 
 type Context struct {
-	broker  *pubsub.Broker
-	modules map[string]*modules.Module
-	logger  logging.Logger
-	lock    sync.RWMutex
+	broker        pubsub.Broker
+	modules       map[string]*modules.Module
+	tickDurations map[string]time.Duration
+	logger        logging.Logger
+	lock          sync.RWMutex
 }
 
 var context *Context
@@ -22,7 +24,8 @@ func GetContext() *Context {
 	if context == nil {
 		singletonOnce.Do(func() {
 			context = &Context{
-				modules: map[string]*modules.Module{},
+				modules:       map[string]*modules.Module{},
+				tickDurations: map[string]time.Duration{},
 			}
 		})
 	}
@@ -31,10 +34,9 @@ func GetContext() *Context {
 }
 
 func (ctx *Context) RegisterModule(module *modules.Module) {
-	ctx.lock.RLock()
-	defer ctx.lock.RUnlock()
-
+	ctx.lock.Lock()
 	ctx.modules[module.Name] = module
+	ctx.lock.Unlock()
 
 	startClock()
 }
@@ -51,14 +53,34 @@ func (ctx *Context) GetModules() []modules.Module {
 	return modules
 }
 
-func (ctx *Context) RegisterBroker(broker *pubsub.Broker) {
+// GetTickDurations returns how long the last tick of every module took, keyed by module name.
+func (ctx *Context) GetTickDurations() map[string]time.Duration {
 	ctx.lock.RLock()
 	defer ctx.lock.RUnlock()
 
+	durations := make(map[string]time.Duration, len(ctx.tickDurations))
+	for name, duration := range ctx.tickDurations {
+		durations[name] = duration
+	}
+
+	return durations
+}
+
+func (ctx *Context) setTickDuration(module string, duration time.Duration) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
+	ctx.tickDurations[module] = duration
+}
+
+func (ctx *Context) RegisterBroker(broker pubsub.Broker) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
 	ctx.broker = broker
 }
 
-func (ctx *Context) GetBroker() *pubsub.Broker {
+func (ctx *Context) GetBroker() pubsub.Broker {
 	ctx.lock.RLock()
 	defer ctx.lock.RUnlock()
 
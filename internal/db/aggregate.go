@@ -0,0 +1,283 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidAggregateQuery = errors.New("invalid aggregate query")
+
+// maxAggregateBuckets limits the number of buckets a single aggregate query may span.
+const maxAggregateBuckets = 10000
+
+// AggregateQuery describes the aggregation of a numeric field of a monitor's history into buckets of equal width.
+type AggregateQuery struct {
+	Target string
+	// Field is a field path as understood by jsonfields. The values of all fields it matches are aggregated together.
+	Field string
+	From  time.Time
+	Until time.Time
+	// Bucket is the width of the buckets, which are aligned to multiples of it.
+	Bucket time.Duration
+	// Percentiles lists the percentiles between 0 and 100 to compute for every bucket.
+	Percentiles []float64
+	// Rate treats the field as a counter and computes its per-second increase for every bucket.
+	// Decreasing values are taken as counter resets. If Field matches multiple fields, their sum is used.
+	Rate bool
+}
+
+// AggregateBucket holds the aggregated values of a field within a bucket.
+type AggregateBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	// Percentiles are keyed by the requested percentile. They are omitted for buckets read from rollups,
+	// which don't keep single values.
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	// Rate is omitted if the bucket holds no increase of the counter to compute it from.
+	Rate *float64 `json:"rate,omitempty"`
+}
+
+// validate checks whether the query can be executed.
+func (query AggregateQuery) validate() error {
+	if query.Bucket <= 0 {
+		return fmt.Errorf("%w: bucket width needs to be positive", ErrInvalidAggregateQuery)
+	}
+
+	if query.Until.Before(query.From) {
+		return fmt.Errorf("%w: until needs to be after from", ErrInvalidAggregateQuery)
+	}
+
+	if !query.From.IsZero() && query.Until.Sub(query.From)/query.Bucket > maxAggregateBuckets {
+		return fmt.Errorf("%w: the range spans more than %d buckets", ErrInvalidAggregateQuery, maxAggregateBuckets)
+	}
+
+	for _, percentile := range query.Percentiles {
+		if percentile < 0 || percentile > 100 {
+			return fmt.Errorf("%w: percentiles need to be between 0 and 100. Is: %g", ErrInvalidAggregateQuery, percentile)
+		}
+	}
+
+	return nil
+}
+
+// sample holds the values of the queried fields within a single history entry.
+type sample struct {
+	timestamp time.Time
+	// observed is the time the latest values were observed at, which is the end of the bucket for rollups.
+	observed time.Time
+	min      float64
+	max      float64
+	sum      float64
+	count    int
+	// values holds the single values of raw entries and is nil for rollups.
+	values []float64
+	// total is the sum of the latest values, which is used as the counter for rates.
+	total float64
+}
+
+// newSample extracts the values of the fields matching fieldPath from a history entry.
+// It returns false if the entry has no such numeric fields.
+func newSample(message HistoryMessage, fieldPath string) (sample, bool) {
+	if message.Resolution == "" {
+		value, err := jsonfields.Parse(message.Message.Value)
+		if err != nil {
+			return sample{}, false
+		}
+
+		values := jsonfields.LookupNumbers(value, fieldPath)
+		if len(values) == 0 {
+			return sample{}, false
+		}
+
+		return newValueSample(message.Timestamp, values), true
+	}
+
+	result := sample{
+		timestamp: message.Timestamp,
+		observed:  message.Timestamp.Add(resolutionOf(message.Resolution)),
+		min:       math.Inf(1),
+		max:       math.Inf(-1),
+	}
+
+	for path, aggregate := range message.Aggregates {
+		if !jsonfields.MatchPath(fieldPath, path) {
+			continue
+		}
+
+		result.min = math.Min(result.min, aggregate.Min)
+		result.max = math.Max(result.max, aggregate.Max)
+		result.sum += aggregate.Avg * float64(aggregate.Count)
+		result.count += aggregate.Count
+		result.total += aggregate.Last
+	}
+
+	return result, result.count > 0
+}
+
+// resolutionOf returns the resolution of the tier with the given label.
+func resolutionOf(label string) time.Duration {
+	for _, tier := range tiers {
+		if tier.label == label {
+			return tier.resolution
+		}
+	}
+
+	return 0
+}
+
+// bucketAccumulator collects the samples of a bucket.
+type bucketAccumulator struct {
+	bucket AggregateBucket
+	sum    float64
+	values []float64
+	// rollup is set once a sample of the bucket has been read from rollups.
+	rollup   bool
+	increase float64
+	elapsed  time.Duration
+}
+
+// add adds a sample to the bucket.
+func (accumulator *bucketAccumulator) add(sample sample) {
+	if accumulator.bucket.Count == 0 {
+		accumulator.bucket.Min = sample.min
+		accumulator.bucket.Max = sample.max
+	}
+
+	accumulator.bucket.Min = math.Min(accumulator.bucket.Min, sample.min)
+	accumulator.bucket.Max = math.Max(accumulator.bucket.Max, sample.max)
+	accumulator.bucket.Count += sample.count
+	accumulator.sum += sample.sum
+
+	if sample.values == nil {
+		accumulator.rollup = true
+	}
+
+	accumulator.values = append(accumulator.values, sample.values...)
+}
+
+// result computes the aggregated values of the bucket.
+func (accumulator *bucketAccumulator) result(query AggregateQuery) AggregateBucket {
+	bucket := accumulator.bucket
+	bucket.Avg = accumulator.sum / float64(bucket.Count)
+
+	if len(query.Percentiles) > 0 && !accumulator.rollup {
+		sort.Float64s(accumulator.values)
+
+		bucket.Percentiles = map[string]float64{}
+		for _, p := range query.Percentiles {
+			bucket.Percentiles[strconv.FormatFloat(p, 'f', -1, 64)] = percentile(accumulator.values, p)
+		}
+	}
+
+	if query.Rate && accumulator.elapsed > 0 {
+		rate := accumulator.increase / accumulator.elapsed.Seconds()
+		bucket.Rate = &rate
+	}
+
+	return bucket
+}
+
+// percentile returns the p-th percentile of the sorted values, interpolating linearly between the closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// seriesStorage is implemented by storages keeping numeric fields as series, which are aggregated without decoding the
+// history entries.
+type seriesStorage interface {
+	// readSeries reads the samples of the field at fieldPath of target between from and until from its series.
+	// It returns false if the series don't cover the field over the whole range.
+	readSeries(target string, fieldPath string, from time.Time, until time.Time) ([]sample, bool, error)
+}
+
+// AggregateHistory aggregates a numeric field of the history of a target into buckets. Buckets without values are
+// omitted. As percentiles need single values, the raw history is used unless it doesn't reach back to from anymore.
+// Fields stored as series are read from them instead of decoding the raw history.
+func AggregateHistory(storage Storage, query AggregateQuery) ([]AggregateBucket, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+
+	if series, ok := storage.(seriesStorage); ok {
+		samples, found, err := series.readSeries(query.Target, query.Field, query.From, query.Until)
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			return aggregateSamples(samples, query), nil
+		}
+	}
+
+	history, err := readHistory(storage, query.Target, query.From, query.Until, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregate(history, query), nil
+}
+
+// aggregate aggregates the history of a validated query into buckets.
+func aggregate(history History, query AggregateQuery) []AggregateBucket {
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	var samples []sample
+	for _, message := range history {
+		if current, ok := newSample(message, query.Field); ok {
+			samples = append(samples, current)
+		}
+	}
+
+	return aggregateSamples(samples, query)
+}
+
+// aggregateSamples aggregates the chronologically ordered samples of a validated query into buckets.
+func aggregateSamples(samples []sample, query AggregateQuery) []AggregateBucket {
+	var accumulators []*bucketAccumulator
+	var previous *sample
+
+	for i := range samples {
+		current := samples[i]
+		start := current.timestamp.Truncate(query.Bucket)
+		if len(accumulators) == 0 || !accumulators[len(accumulators)-1].bucket.Start.Equal(start) {
+			accumulators = append(accumulators, &bucketAccumulator{bucket: AggregateBucket{Start: start}})
+		}
+
+		accumulator := accumulators[len(accumulators)-1]
+		accumulator.add(current)
+
+		// The increase since the previous sample is accounted to the bucket of the current one.
+		if previous != nil {
+			increase := current.total - previous.total
+			if increase < 0 {
+				increase = current.total
+			}
+
+			accumulator.increase += increase
+			accumulator.elapsed += current.observed.Sub(previous.observed)
+		}
+
+		previous = &current
+	}
+
+	buckets := make([]AggregateBucket, len(accumulators))
+	for i, accumulator := range accumulators {
+		buckets[i] = accumulator.result(query)
+	}
+
+	return buckets
+}
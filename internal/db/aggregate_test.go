@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAggregateHistory(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute).UTC()
+
+	var entries []HistoryEntry
+	for i, content := range []string{
+		`{"rx": 100, "load": 10}`,
+		`{"rx": 200, "load": 20}`,
+		`{"rx": 300, "load": 30}`,
+		`{"rx": 400, "load": 40}`,
+		`{"rx": 50, "load": 50}`,
+	} {
+		entries = append(entries, HistoryEntry{
+			Timestamp: base.Add(time.Duration(i) * 20 * time.Second),
+			Target:    "Network.Traffic",
+			Content:   content,
+		})
+	}
+
+	require.NoError(t, GetWriter().AddHistoryEntries(entries))
+
+	buckets, err := AggregateHistory(GetStorage(), AggregateQuery{
+		Target:      "Network.Traffic",
+		Field:       "load",
+		From:        base,
+		Until:       base.Add(2 * time.Minute),
+		Bucket:      time.Minute,
+		Percentiles: []float64{50, 90},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(buckets))
+
+	assert.True(t, base.Equal(buckets[0].Start))
+	assert.Equal(t, 3, buckets[0].Count)
+	assert.Equal(t, 10.0, buckets[0].Min)
+	assert.Equal(t, 30.0, buckets[0].Max)
+	assert.Equal(t, 20.0, buckets[0].Avg)
+	assert.Equal(t, 20.0, buckets[0].Percentiles["50"])
+	assert.InDelta(t, 28.0, buckets[0].Percentiles["90"], 1e-9)
+	assert.Nil(t, buckets[0].Rate)
+
+	assert.True(t, base.Add(time.Minute).Equal(buckets[1].Start))
+	assert.Equal(t, 2, buckets[1].Count)
+	assert.Equal(t, 45.0, buckets[1].Avg)
+
+	buckets, err = AggregateHistory(GetStorage(), AggregateQuery{
+		Target: "Network.Traffic",
+		Field:  "rx",
+		From:   base,
+		Until:  base.Add(2 * time.Minute),
+		Bucket: time.Minute,
+		Rate:   true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(buckets))
+
+	// 200 bytes within 40 seconds, then 100 bytes and a counter reset to 50 bytes within 40 seconds.
+	require.NotNil(t, buckets[0].Rate)
+	assert.Equal(t, 5.0, *buckets[0].Rate)
+	require.NotNil(t, buckets[1].Rate)
+	assert.Equal(t, 3.75, *buckets[1].Rate)
+
+	buckets, err = AggregateHistory(GetStorage(), AggregateQuery{
+		Target: "Network.Traffic",
+		Field:  "tx",
+		From:   base,
+		Until:  base.Add(2 * time.Minute),
+		Bucket: time.Minute,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, buckets)
+}
+
+func TestAggregateHistoryInvalid(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+
+	now := time.Now()
+
+	for description, query := range map[string]AggregateQuery{
+		"Bucket":      {From: now.Add(-time.Hour), Until: now},
+		"Range":       {From: now, Until: now.Add(-time.Hour), Bucket: time.Minute},
+		"Buckets":     {From: now.Add(-time.Hour), Until: now, Bucket: time.Millisecond},
+		"Percentiles": {From: now.Add(-time.Hour), Until: now, Bucket: time.Minute, Percentiles: []float64{101}},
+	} {
+		t.Run(description, func(t *testing.T) {
+			_, err := AggregateHistory(GetStorage(), query)
+			assert.True(t, errors.Is(err, ErrInvalidAggregateQuery))
+		})
+	}
+}
+
+func TestNewSampleRollup(t *testing.T) {
+	timestamp := time.Now().Truncate(time.Minute)
+
+	message := HistoryMessage{Timestamp: timestamp, Resolution: "1m", Aggregates: map[string]Aggregate{
+		"cpu0.usage": {Min: 10, Max: 30, Avg: 20, Last: 30, Count: 4},
+		"cpu1.usage": {Min: 5, Max: 15, Avg: 10, Last: 15, Count: 4},
+		"cpu.clock":  {Min: 1000, Max: 2000, Avg: 1500, Last: 1000, Count: 4},
+	}}
+
+	result, ok := newSample(message, "cpu?.usage")
+	require.True(t, ok)
+	assert.Equal(t, 5.0, result.min)
+	assert.Equal(t, 30.0, result.max)
+	assert.Equal(t, 120.0, result.sum)
+	assert.Equal(t, 8, result.count)
+	assert.Equal(t, 45.0, result.total)
+	assert.Nil(t, result.values)
+	assert.True(t, timestamp.Add(time.Minute).Equal(result.observed))
+
+	_, ok = newSample(message, "memory")
+	assert.False(t, ok)
+}
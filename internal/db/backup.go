@@ -0,0 +1,142 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var ErrBackupNotSupported = errors.New("storage backend does not support backups")
+var ErrInvalidBackupConfiguration = errors.New("invalid backup configuration")
+
+// Scheduled backups are named after the time they were taken, so that sorting their names sorts them by age.
+const (
+	backupPrefix     = "history-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// backupPollInterval is how often the backup cycle checks whether scheduled backups have been enabled.
+const backupPollInterval = time.Minute
+
+// BackupFileName returns the name of a backup taken at the given time.
+func BackupFileName(t time.Time) string {
+	return backupPrefix + t.UTC().Format(backupTimeFormat) + backupSuffix
+}
+
+// Backup writes a consistent snapshot of the storage to file while it keeps being written to.
+// It returns ErrBackupNotSupported if the storage is no BackupStorage.
+func Backup(storage Storage, file string) error {
+	backupStorage, ok := storage.(BackupStorage)
+	if !ok {
+		return ErrBackupNotSupported
+	}
+
+	return backupStorage.Backup(file)
+}
+
+// startBackupCycle takes a backup of the storage into data.backup.directory every data.backup.cycle and keeps the
+// newest data.backup.keep of them. A cycle of 0 disables scheduled backups until the configuration is reloaded.
+func startBackupCycle(storage Storage, jobs *jobs) error {
+	if _, _, err := readBackupConfiguration(); err != nil {
+		return err
+	}
+
+	logger.Trace("Starting backup cycle...")
+
+	jobs.start(func() {
+		for {
+			// Re-read the configuration so that reloads take effect on the next cycle.
+			cycle, keep, err := readBackupConfiguration()
+			if err != nil {
+				logger.Error(fmt.Sprintf("Could not read backup configuration. Retrying in %s! Reason: %s", backupPollInterval, err))
+				if !jobs.sleep(backupPollInterval) {
+					return
+				}
+
+				continue
+			}
+
+			if cycle == 0 {
+				if !jobs.sleep(backupPollInterval) {
+					return
+				}
+
+				continue
+			}
+
+			if !jobs.sleep(cycle) {
+				return
+			}
+
+			directory := config.GetConfig().String("data.backup.directory")
+			file, err := backupToDirectory(storage, directory, keep, time.Now())
+			if err != nil {
+				logger.Error(fmt.Sprintf("Could not back up database to %s! Reason: %s", directory, err))
+				continue
+			}
+
+			logger.Info(fmt.Sprintf("Backed up database to %s.", file))
+		}
+	})
+
+	return nil
+}
+
+// readBackupConfiguration parses the backup cycle and the number of backups to keep from the configuration.
+func readBackupConfiguration() (time.Duration, int, error) {
+	k := config.GetConfig()
+
+	cycle, err := config.ParseDuration(k.String("data.backup.cycle"))
+	if err != nil || cycle < 0 {
+		return 0, 0, fmt.Errorf("%w: data.backup.cycle needs to be a duration or 0. Is: %s", ErrInvalidBackupConfiguration, k.String("data.backup.cycle"))
+	}
+
+	keep := k.Int("data.backup.keep")
+	if keep < 1 {
+		return 0, 0, fmt.Errorf("%w: data.backup.keep needs to be at least 1. Is: %d", ErrInvalidBackupConfiguration, keep)
+	}
+
+	return cycle, keep, nil
+}
+
+// backupToDirectory takes a backup of the storage into directory, named after the given time, and deletes the oldest
+// backups in directory, so that keep of them are left. It returns the path of the backup.
+func backupToDirectory(storage Storage, directory string, keep int, now time.Time) (string, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return "", err
+	}
+
+	file := filepath.Join(directory, BackupFileName(now))
+	if err := Backup(storage, file); err != nil {
+		return "", err
+	}
+
+	return file, rotateBackups(directory, keep)
+}
+
+// rotateBackups deletes the oldest backups in directory, so that keep of them are left.
+func rotateBackups(directory string, keep int) error {
+	backups, err := filepath.Glob(filepath.Join(directory, backupPrefix+"*"+backupSuffix))
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(backups)
+
+	for len(backups) > keep {
+		logger.Debug(fmt.Sprintf("Deleting old backup %s...", backups[0]))
+
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+
+		backups = backups[1:]
+	}
+
+	return nil
+}
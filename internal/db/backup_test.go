@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStorage_Backup(t *testing.T) {
+	// The logger is set up on initialization.
+	require.NoError(t, OpenDatabase())
+
+	directory := t.TempDir()
+
+	storage, err := openSQLite(filepath.Join(directory, "history.db"))
+	require.NoError(t, err)
+	defer func() { _ = storage.Close() }()
+
+	now := time.Now()
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: `{"usage": 10}`},
+	}))
+
+	file := filepath.Join(directory, "backup.db")
+	require.NoError(t, storage.Backup(file))
+
+	// Entries written after the snapshot aren't part of it.
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(time.Second), Target: "CPU.Usage", Content: `{"usage": 20}`},
+	}))
+
+	assertBackup := func(expected int) {
+		backup, err := openSQLite(file)
+		require.NoError(t, err)
+		defer func() { _ = backup.Close() }()
+
+		history, err := GetHistory(backup, "CPU.Usage", time.Time{}, now.Add(time.Minute), 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, len(history))
+	}
+
+	assertBackup(1)
+
+	// Existing backups are replaced.
+	require.NoError(t, storage.Backup(file))
+	assertBackup(2)
+
+	_, err = os.Stat(file + ".tmp")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestBackupNotSupported(t *testing.T) {
+	err := Backup(newMemoryStorage(1), filepath.Join(t.TempDir(), "backup.db"))
+	assert.True(t, errors.Is(err, ErrBackupNotSupported))
+}
+
+func TestBackupToDirectory(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+
+	directory := filepath.Join(t.TempDir(), "backups")
+
+	storage, err := openSQLite(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer func() { _ = storage.Close() }()
+
+	// Other files in the backup directory are never deleted.
+	require.NoError(t, os.MkdirAll(directory, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, "notes.txt"), nil, 0o600))
+
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var files []string
+	for i := 0; i < 4; i++ {
+		file, err := backupToDirectory(storage, directory, 2, start.Add(time.Duration(i)*time.Hour))
+		require.NoError(t, err)
+
+		files = append(files, file)
+	}
+
+	assert.Equal(t, filepath.Join(directory, "history-20230501T150000Z.db"), files[3])
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	assert.Equal(t, []string{"history-20230501T140000Z.db", "history-20230501T150000Z.db", "notes.txt"}, names)
+}
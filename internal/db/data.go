@@ -5,12 +5,27 @@ import (
 )
 
 // HistoryMessage serves as a model to describe entries in the history table.
+// Entries read from a rollup tier describe a whole bucket starting at Timestamp. Their value is the last message of the
+// bucket with every numeric field replaced by its average, and Aggregates holds the statistics of the numeric fields.
 type HistoryMessage struct {
 	Timestamp time.Time `json:"timestamp"`
 	Message   struct {
 		Target string `json:"target"`
 		Value  string `json:"value"`
 	} `json:"message"`
+	Host       string               `json:"host"`
+	Labels     map[string]string    `json:"labels"`
+	Resolution string               `json:"resolution,omitempty"`
+	Aggregates map[string]Aggregate `json:"aggregates,omitempty"`
+}
+
+// Aggregate summarizes the values of a numeric field within a rollup bucket.
+type Aggregate struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	Last  float64 `json:"last"`
+	Count int     `json:"count"`
 }
 
 type History []HistoryMessage
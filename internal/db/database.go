@@ -6,6 +6,7 @@ import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	_ "github.com/mattn/go-sqlite3"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,98 +19,165 @@ var logger logging.Logger
 var writer *Writer
 var reader *Reader
 
-const createStatement string = `
-	CREATE TABLE IF NOT EXISTS history (
-			time DATETIME NOT NULL,
-			target TEXT NOT NULL,
-			content TEXT NOT NULL,
-			PRIMARY KEY (time, target)
-	);
-`
+// connectionParameters configure every connection to the database file.
+// The write-ahead log lets readers proceed while a batch is written and, combined with the NORMAL synchronous level,
+// only syncs to disk on checkpoints instead of on every commit. A crash may lose the last commits but never corrupts the file.
+// Incremental auto vacuum lets purges release freed pages without rewriting the whole file.
+// Transactions take the write lock right away, as a transaction reading before it writes, like a compaction, fails
+// without waiting for the busy timeout if another connection has written in between.
+const connectionParameters = "_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000&_auto_vacuum=incremental&_txlock=immediate"
 
-// InitDatabase initializes the database connection and starts all recurring jobs on the database.
+// InitDatabase opens the storage backend selected by data.backend and starts all recurring jobs on it.
 func InitDatabase() error {
+	return initDatabase(true)
+}
+
+// OpenDatabase opens the storage backend without starting any recurring jobs or vacuuming the database.
+// It is meant for short-lived commands like export and import, which may run next to a running instance, so the
+// backend needs to be persistent.
+func OpenDatabase() error {
+	return initDatabase(false)
+}
+
+// initDatabase opens the storage backend selected by data.backend. An empty name selects sqlite.
+func initDatabase(startJobs bool) error {
 	var err error
 
 	singletonOnce.Do(func() {
 		logger = logging.GetLogger()
 
-		file := config.GetConfig().String("data.database_file")
-
-		logger.Trace("Opening database connection!")
-
-		var db *sql.DB
-		db, err = sql.Open("sqlite3", file)
-		if err != nil {
-			return
+		name := config.GetConfig().String("data.backend")
+		if name == "" {
+			name = "sqlite"
 		}
 
-		logger.Trace("Creating table if it doesn't exist already.")
-		_, err = db.Exec(createStatement)
-		if err != nil {
+		backend, ok := lookupBackend(name)
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrUnknownBackend, name)
 			return
 		}
 
-		writer = &Writer{db}
-		reader = &Reader{db}
-
-		err = vacuumDB(db)
-		if err != nil {
+		if !startJobs && !backend.Persistent {
+			err = fmt.Errorf("%w: %s keeps its data in the memory of the running instance", ErrBackendNotPersistent, name)
 			return
 		}
 
-		err = startPurgeCycle(db)
-		if err != nil {
-			return
-		}
+		logger.Debug(fmt.Sprintf("Opening %s storage backend...", name))
+
+		storage, err = backend.Open(startJobs)
 	})
 
-	if err != nil {
-		return err
+	return err
+}
+
+// dataSourceName appends the connectionParameters to the database file.
+func dataSourceName(file string) string {
+	if strings.Contains(file, "?") {
+		return file + "&" + connectionParameters
 	}
 
-	return nil
+	return file + "?" + connectionParameters
+}
+
+// Close closes the storage. Pending messages of the recorder need to be flushed before.
+func Close() error {
+	return storage.Close()
 }
 
 // startPurgeCycle starts the recurring job of purging all old database entries.
-func startPurgeCycle(db *sql.DB) error {
-	purgeCycleString := config.GetConfig().String("data.purge_cycle")
-	purgeCycle, err := time.ParseDuration(purgeCycleString)
+func startPurgeCycle(db *sql.DB, jobs *jobs) error {
+	purgeCycle, err := readPurgeCycle()
 	if err != nil {
 		return err
 	}
 
 	logger.Trace("Starting purge cycle...")
 
-	go func() {
+	jobs.start(func() {
 		for {
 			if err := purgeOldEntries(db); err != nil {
 				logger.Error("Could not purge old database entries! Reason:", err.Error())
 			}
 
-			time.Sleep(purgeCycle)
+			if !jobs.sleep(purgeCycle) {
+				return
+			}
+
+			// Re-read the purge cycle so that configuration reloads take effect on the next cycle.
+			newPurgeCycle, err := readPurgeCycle()
+			if err != nil {
+				logger.Error(fmt.Sprintf("Could not parse purge cycle from configuration. Keeping %s! Reason: %s", purgeCycle, err))
+				continue
+			}
+
+			purgeCycle = newPurgeCycle
 		}
-	}()
+	})
 
 	return nil
 }
 
-// purgeOldEntries purges all old database entries.
+// readPurgeCycle parses the purge cycle from the configuration.
+func readPurgeCycle() (time.Duration, error) {
+	return time.ParseDuration(config.GetConfig().String("data.purge_cycle"))
+}
+
+// purgeOldEntries purges all old database entries of the history, the rollup tiers, the series and the events.
+// If the database still exceeds its maximum size afterwards, the oldest entries are deleted until it fits.
 func purgeOldEntries(db *sql.DB) error {
-	storageTimeString := config.GetConfig().String("data.storage_time")
-	storageTime, err := time.ParseDuration(storageTimeString)
+	logger.Debug("Purging database...")
+
+	policies, err := readRetentionPolicies()
 	if err != nil {
 		return err
 	}
 
-	stmt, err := db.Prepare(`DELETE FROM history WHERE time < ?`)
+	now := time.Now()
+
+	for index := range tiers {
+		if err := purgeTier(db, index, policies, now); err != nil {
+			return err
+		}
+	}
+
+	if err := purgeSeries(db, policies, now); err != nil {
+		return err
+	}
+
+	if err := purgeEvents(db, now); err != nil {
+		return err
+	}
+
+	maxSize, err := readMaxDatabaseSize()
 	if err != nil {
 		return err
 	}
 
-	logger.Debug("Purging database...")
+	if err := enforceMaxSize(db, maxSize); err != nil {
+		return err
+	}
+
+	return incrementalVacuum(db)
+}
+
+// purgeEvents deletes the events that are older than the storage time of events.
+func purgeEvents(db *sql.DB, now time.Time) error {
+	eventStorageTime, err := config.ParseDuration(config.GetConfig().String("data.events_storage_time"))
+	if err != nil {
+		return err
+	}
+
+	return purgeTable(db, "events", now.Add(-eventStorageTime).UTC())
+}
+
+// purgeTable deletes all entries of a table older than before.
+func purgeTable(db *sql.DB, table string, before time.Time) error {
+	stmt, err := db.Prepare(fmt.Sprintf(`DELETE FROM %s WHERE time < ?`, table))
+	if err != nil {
+		return err
+	}
 
-	result, err := stmt.Exec(time.Now().Add(-storageTime))
+	result, err := stmt.Exec(before)
 	if err != nil {
 		_ = stmt.Close()
 		return err
@@ -121,17 +189,18 @@ func purgeOldEntries(db *sql.DB) error {
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		logger.Error("Couldn't determine how many rows were deleted on purge!")
+		logger.Error(fmt.Sprintf("Couldn't determine how many rows were deleted from %s on purge!", table))
 		return nil
 	}
 
-	logger.Debug(fmt.Sprintf("Deleted %d rows on purge.", rowsAffected))
+	logger.Debug(fmt.Sprintf("Deleted %d rows from %s on purge.", rowsAffected, table))
 
 	return nil
 }
 
 // vacuumDB executes a VACUUM statement on the sqlite database.
 // This shrinks the database's size so that it does not become unnecessarily large.
+// It also switches databases created before incremental auto vacuum was enabled over to it.
 func vacuumDB(db *sql.DB) error {
 	logger.Debug("Vacuuming database...")
 
@@ -1,13 +1,14 @@
 package db
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
 
-func TestInitDatabaseCreateTable(t *testing.T) {
-	err := InitDatabase()
+func TestOpenDatabaseCreateTable(t *testing.T) {
+	err := OpenDatabase()
 	if err != nil {
 		t.Error(err)
 		return
@@ -36,8 +37,46 @@ func TestInitDatabaseCreateTable(t *testing.T) {
 	}
 }
 
+func TestOpenDatabaseJournalMode(t *testing.T) {
+	if err := OpenDatabase(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Use a single connection for both pragmas, as the synchronous level is a setting of the connection.
+	conn, err := GetWriter().db.Conn(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var journalMode string
+	if err := conn.QueryRowContext(context.Background(), "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Error(err)
+		return
+	}
+
+	var synchronous int
+	if err := conn.QueryRowContext(context.Background(), "PRAGMA synchronous").Scan(&synchronous); err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, "wal", journalMode)
+	assert.Equal(t, 1, synchronous) // NORMAL
+}
+
+func TestDataSourceName(t *testing.T) {
+	assert.Equal(t, "history.db?"+connectionParameters, dataSourceName("history.db"))
+	assert.Equal(t, "file:history.db?cache=shared&"+connectionParameters, dataSourceName("file:history.db?cache=shared"))
+}
+
 func TestPurgeDatabaseEntries(t *testing.T) {
-	err := InitDatabase()
+	err := OpenDatabase()
 	if err != nil {
 		t.Error(err)
 		return
@@ -96,6 +135,12 @@ func TestPurgeDatabaseEntries(t *testing.T) {
 
 	assert.Equal(t, 3, count)
 
+	// Raw history is only purged once it has been rolled up.
+	if err := compact(GetWriter().db, time.Now()); err != nil {
+		t.Error(err)
+		return
+	}
+
 	if err := purgeOldEntries(GetWriter().db); err != nil {
 		t.Error(err)
 		return
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"math"
+	"time"
+)
+
+var ErrInvalidMaxPoints = errors.New("invalid maximum number of points")
+
+// minPoints is the smallest number of points downsampling can reduce a history to, as the first and the last entry
+// are always kept.
+const minPoints = 3
+
+// point is a history entry placed on a chart of the downsampled field.
+type point struct {
+	x     float64
+	y     float64
+	entry HistoryMessage
+}
+
+// GetHistoryDownsampled gets the history of target between from and until reduced to at most maxPoints entries by
+// Largest-Triangle-Three-Buckets downsampling of the numeric field at fieldPath.
+// If the storage keeps rollups, the tier is chosen so that its resolution doesn't exceed the spacing of maxPoints
+// entries over the range.
+func GetHistoryDownsampled(storage Storage, target string, from time.Time, until time.Time, fieldPath string, maxPoints int) (History, error) {
+	if err := validateMaxPoints(maxPoints); err != nil {
+		return nil, err
+	}
+
+	var density time.Duration
+	if !from.IsZero() {
+		density = until.Sub(from) / time.Duration(maxPoints)
+	}
+
+	history, err := readHistory(storage, target, from, until, density)
+	if err != nil {
+		return nil, err
+	}
+
+	return downsample(history, fieldPath, maxPoints), nil
+}
+
+// validateMaxPoints checks whether a history can be downsampled to maxPoints entries.
+func validateMaxPoints(maxPoints int) error {
+	if maxPoints < minPoints {
+		return fmt.Errorf("%w: at least %d points are needed. Is: %d", ErrInvalidMaxPoints, minPoints, maxPoints)
+	}
+
+	return nil
+}
+
+// downsample reduces the history to at most maxPoints entries with the Largest-Triangle-Three-Buckets algorithm, which
+// keeps the entries that shape the chart of the field most, i.e. short spikes.
+// If the field path matches multiple fields, like the usage of every core, the highest of their values is charted.
+// Rollup entries are charted by the maximum of their bucket and keep their aggregates.
+// Entries without a numeric value for the field are left out.
+func downsample(history History, fieldPath string, maxPoints int) History {
+	points := make([]point, 0, len(history))
+	for _, entry := range history {
+		y, ok := chartValue(entry, fieldPath)
+		if !ok {
+			continue
+		}
+
+		var x float64
+		if len(points) > 0 {
+			x = entry.Timestamp.Sub(points[0].entry.Timestamp).Seconds()
+		}
+
+		points = append(points, point{x: x, y: y, entry: entry})
+	}
+
+	if len(points) <= maxPoints {
+		return entries(points)
+	}
+
+	selected := []point{points[0]}
+
+	// Every bucket but the ones of the first and the last point gets the same share of the remaining points.
+	bucketSize := float64(len(points)-2) / float64(maxPoints-2)
+	previous := points[0]
+
+	for i := 0; i < maxPoints-2; i++ {
+		// The point chosen from this bucket forms the largest triangle with the previously chosen point and the average of
+		// the next bucket.
+		nextStart := int(float64(i+1)*bucketSize) + 1
+		nextEnd := int(float64(i+2)*bucketSize) + 1
+		if nextEnd > len(points) {
+			nextEnd = len(points)
+		}
+
+		var averageX, averageY float64
+		for _, next := range points[nextStart:nextEnd] {
+			averageX += next.x
+			averageY += next.y
+		}
+
+		averageX /= float64(nextEnd - nextStart)
+		averageY /= float64(nextEnd - nextStart)
+
+		start := int(float64(i)*bucketSize) + 1
+		end := nextStart
+
+		chosen := points[start]
+		largestArea := -1.0
+
+		for _, candidate := range points[start:end] {
+			area := math.Abs((previous.x-averageX)*(candidate.y-previous.y) - (previous.x-candidate.x)*(averageY-previous.y))
+			if area > largestArea {
+				largestArea = area
+				chosen = candidate
+			}
+		}
+
+		selected = append(selected, chosen)
+		previous = chosen
+	}
+
+	selected = append(selected, points[len(points)-1])
+
+	return entries(selected)
+}
+
+// chartValue returns the value of the field at fieldPath charted for the entry. The value of a rollup entry is the
+// maximum of its bucket, as its averaged value would flatten the spikes downsampling is meant to keep.
+func chartValue(entry HistoryMessage, fieldPath string) (float64, bool) {
+	y := math.Inf(-1)
+
+	if len(entry.Aggregates) > 0 {
+		for path, aggregate := range entry.Aggregates {
+			if jsonfields.MatchPath(fieldPath, path) {
+				y = math.Max(y, aggregate.Max)
+			}
+		}
+
+		return y, !math.IsInf(y, -1)
+	}
+
+	value, err := jsonfields.Parse(entry.Message.Value)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, number := range jsonfields.LookupNumbers(value, fieldPath) {
+		y = math.Max(y, number)
+	}
+
+	return y, !math.IsInf(y, -1)
+}
+
+// entries returns the history entries of the points.
+func entries(points []point) History {
+	history := make(History, len(points))
+	for i, p := range points {
+		history[i] = p.entry
+	}
+
+	return history
+}
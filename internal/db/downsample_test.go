@@ -0,0 +1,140 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// generateHistory generates a history of count entries a second apart whose value is computed by value.
+func generateHistory(start time.Time, count int, value func(i int) string) History {
+	history := make(History, count)
+	for i := range history {
+		history[i].Timestamp = start.Add(time.Duration(i) * time.Second)
+		history[i].Message.Target = "CPU.Usage"
+		history[i].Message.Value = value(i)
+	}
+
+	return history
+}
+
+func TestDownsample(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	history := generateHistory(start, 1000, func(i int) string {
+		usage := 10
+		if i == 567 {
+			usage = 100
+		}
+
+		return fmt.Sprintf(`{"usage": %d}`, usage)
+	})
+
+	downsampled := downsample(history, "usage", 50)
+	require.Equal(t, 50, len(downsampled))
+
+	assert.True(t, start.Equal(downsampled[0].Timestamp))
+	assert.True(t, start.Add(999*time.Second).Equal(downsampled[49].Timestamp))
+
+	// The short spike survives even though its neighbours are dropped.
+	var spike bool
+	for i, entry := range downsampled {
+		if i > 0 {
+			assert.True(t, entry.Timestamp.After(downsampled[i-1].Timestamp))
+		}
+
+		spike = spike || entry.Message.Value == `{"usage": 100}`
+	}
+
+	assert.True(t, spike)
+
+	// Short histories are left alone.
+	assert.Equal(t, history[:20], downsample(history[:20], "usage", 50))
+}
+
+func TestDownsampleNested(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+
+	history := generateHistory(start, 500, func(i int) string {
+		if i == 123 {
+			return `{"cpu0": {"usage": 5}, "cpu1": {"usage": 95}}`
+		}
+
+		if i%100 == 50 {
+			return `{"name": "Some CPU"}`
+		}
+
+		return `{"cpu0": {"usage": 5}, "cpu1": {"usage": 10}}`
+	})
+
+	downsampled := downsample(history, "cpu*.usage", 10)
+	require.Equal(t, 10, len(downsampled))
+
+	var spike bool
+	for _, entry := range downsampled {
+		assert.NotEqual(t, `{"name": "Some CPU"}`, entry.Message.Value)
+		spike = spike || entry.Timestamp.Equal(start.Add(123*time.Second))
+	}
+
+	assert.True(t, spike)
+}
+
+func TestDownsampleRollups(t *testing.T) {
+	start := time.Now().Truncate(time.Minute)
+
+	// The averages are flat, only the maximum of a single bucket shows the spike.
+	history := generateHistory(start, 500, func(i int) string {
+		return `{"usage": 10}`
+	})
+
+	for i := range history {
+		history[i].Resolution = "1m"
+		history[i].Aggregates = map[string]Aggregate{"usage": {Min: 10, Max: 10, Avg: 10, Last: 10, Count: 60}}
+	}
+
+	history[321].Aggregates = map[string]Aggregate{"usage": {Min: 5, Max: 100, Avg: 10, Last: 10, Count: 60}}
+
+	downsampled := downsample(history, "usage", 10)
+	require.Equal(t, 10, len(downsampled))
+
+	var spike bool
+	for _, entry := range downsampled {
+		if entry.Timestamp.Equal(history[321].Timestamp) {
+			spike = true
+			assert.Equal(t, 100.0, entry.Aggregates["usage"].Max)
+		}
+	}
+
+	assert.True(t, spike)
+
+	// Rollups without an aggregate of the field are left out.
+	assert.Empty(t, downsample(history, "temperature", 10))
+}
+
+func TestGetHistoryDownsampled(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	var entries []HistoryEntry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, HistoryEntry{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Target:    "CPU.Usage",
+			Content:   fmt.Sprintf(`{"usage": %d}`, i%7),
+		})
+	}
+
+	require.NoError(t, GetWriter().AddHistoryEntries(entries))
+
+	history, err := GetHistoryDownsampled(GetStorage(), "CPU.Usage", start, start.Add(time.Minute+39*time.Second), "usage", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 10, len(history))
+
+	_, err = GetHistoryDownsampled(GetStorage(), "CPU.Usage", start, start.Add(time.Minute), "usage", 2)
+	assert.True(t, errors.Is(err, ErrInvalidMaxPoints))
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"time"
+)
+
+// AddEvent adds an event to the events table.
+func (writer *Writer) AddEvent(event events.Event) error {
+	attributes := event.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	encodedAttributes, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := writer.db.Prepare(`
+		INSERT INTO events (time, type, severity, source, message, attributes, host) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(event.Timestamp.UTC(), event.Type, string(event.Severity), event.Source, event.Message, string(encodedAttributes), event.Host)
+	if err != nil {
+		_ = stmt.Close()
+		return err
+	}
+
+	if err := stmt.Close(); err != nil {
+		logger.Error("Error on closing statement for writer:", err)
+	}
+
+	return nil
+}
+
+// GetEvents gets all events between "from" and "until" ordered by time.
+func (reader *Reader) GetEvents(from time.Time, until time.Time) ([]events.Event, error) {
+	rows, err := reader.db.Query(`
+		SELECT time, type, severity, source, message, attributes, host FROM events WHERE time >= ? AND time <= ? ORDER BY time;
+	`, from.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	result := []events.Event{}
+	for rows.Next() {
+		event := events.Event{}
+		var attributes string
+		if err := rows.Scan(&event.Timestamp, &event.Type, &event.Severity, &event.Source, &event.Message, &attributes, &event.Host); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(attributes), &event.Attributes); err != nil {
+			logger.Error(fmt.Sprintf("Could not parse attributes of %s event at %s! Reason: %s", event.Type, event.Timestamp.UTC().String(), err))
+		}
+
+		result = append(result, event)
+	}
+
+	if err := rows.Close(); err != nil {
+		logger.Error("Error upon closing rows:", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	now := time.Now()
+
+	for i, event := range []events.Event{
+		{Timestamp: now.Add(-2 * time.Hour), Type: events.TypeStarted, Severity: events.SeverityInfo, Source: "Excubitor", Host: "test-host"},
+		{Timestamp: now.Add(-time.Hour), Type: events.TypePluginCrashed, Severity: events.SeverityError, Source: "Plugins", Message: "Plugin exited.", Attributes: map[string]string{"plugin": "demo"}, Host: "test-host"},
+		{Timestamp: now, Type: events.TypeConfigReloaded, Severity: events.SeverityInfo, Source: "Excubitor", Host: "test-host"},
+	} {
+		require.NoError(t, GetWriter().AddEvent(event), "event %d", i)
+	}
+
+	result, err := GetReader().GetEvents(now.Add(-90*time.Minute), now)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	assert.Equal(t, events.TypePluginCrashed, result[0].Type)
+	assert.Equal(t, events.SeverityError, result[0].Severity)
+	assert.Equal(t, "Plugins", result[0].Source)
+	assert.Equal(t, "Plugin exited.", result[0].Message)
+	assert.Equal(t, map[string]string{"plugin": "demo"}, result[0].Attributes)
+	assert.Equal(t, "test-host", result[0].Host)
+	assert.True(t, result[0].Timestamp.Equal(now.Add(-time.Hour)))
+
+	assert.Equal(t, events.TypeConfigReloaded, result[1].Type)
+	assert.Equal(t, map[string]string{}, result[1].Attributes)
+}
+
+func TestPurgeEvents(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	restore, err := config.Override(map[string]interface{}{"data.events_storage_time": "2h"})
+	require.NoError(t, err)
+	t.Cleanup(restore)
+
+	// Events are stored in UTC, so the storage time applies regardless of the zone of the current time.
+	now := time.Now().In(conformanceZone)
+
+	for _, timestamp := range []time.Time{now.Add(-3 * time.Hour), now.Add(-time.Hour)} {
+		require.NoError(t, GetWriter().AddEvent(events.Event{Timestamp: timestamp, Type: events.TypeStarted, Severity: events.SeverityInfo, Source: "Excubitor", Host: "test-host"}))
+	}
+
+	require.NoError(t, purgeEvents(GetWriter().db, now))
+
+	result, err := GetReader().GetEvents(now.Add(-4*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.True(t, result[0].Timestamp.Equal(now.Add(-time.Hour)))
+}
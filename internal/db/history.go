@@ -0,0 +1,31 @@
+package db
+
+import (
+	"time"
+)
+
+// GetHistory gets the history of target between from and until, thinned out to maxDensity if it is positive.
+// A zero from reads the whole history. Storages keeping rollups answer with them if maxDensity is at least their
+// resolution or if the raw history of the range has already been purged.
+func GetHistory(storage Storage, target string, from time.Time, until time.Time, maxDensity time.Duration) (History, error) {
+	history, err := readHistory(storage, target, from, until, maxDensity)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxDensity > 0 {
+		return thinData(history, maxDensity), nil
+	}
+
+	return history, nil
+}
+
+// readHistory gets the history of target between from and until from the tier fitting maxResolution if the storage
+// keeps rollups and from the raw history otherwise.
+func readHistory(storage Storage, target string, from time.Time, until time.Time, maxResolution time.Duration) (History, error) {
+	if rollupStorage, ok := storage.(RollupStorage); ok {
+		return rollupStorage.GetTierHistory(target, from, until, maxResolution)
+	}
+
+	return storage.GetHistoryEntriesFromUntil(target, from, until)
+}
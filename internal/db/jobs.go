@@ -0,0 +1,46 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// jobs runs the recurring jobs of a storage, like purges and compactions, until the storage is closed.
+type jobs struct {
+	stop    chan struct{}
+	running sync.WaitGroup
+}
+
+func newJobs() *jobs {
+	return &jobs{stop: make(chan struct{})}
+}
+
+// start runs job in its own goroutine.
+func (jobs *jobs) start(job func()) {
+	jobs.running.Add(1)
+
+	go func() {
+		defer jobs.running.Done()
+		job()
+	}()
+}
+
+// sleep waits for d. It returns false as soon as the jobs are stopped, in which case the job needs to return.
+func (jobs *jobs) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-jobs.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// shutdown stops the jobs and waits for the running ones to return, so that none of them uses the database after it
+// has been closed.
+func (jobs *jobs) shutdown() {
+	close(jobs.stop)
+	jobs.running.Wait()
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJobsShutdown(t *testing.T) {
+	jobs := newJobs()
+
+	var runs atomic.Int32
+	jobs.start(func() {
+		for {
+			runs.Add(1)
+
+			if !jobs.sleep(time.Millisecond) {
+				return
+			}
+		}
+	})
+
+	assert.Eventually(t, func() bool { return runs.Load() > 2 }, time.Second, time.Millisecond)
+
+	// A job sleeping for a whole cycle doesn't hold up the shutdown.
+	jobs.start(func() {
+		jobs.sleep(time.Hour)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		jobs.shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Jobs have not been stopped!")
+	}
+
+	stopped := runs.Load()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
@@ -0,0 +1,325 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidCapacity = errors.New("invalid capacity")
+
+// memoryStorage is a Storage keeping the newest entries of every monitor and the newest events in ring buffers.
+// Nothing is written to disk, so it suits diskless and read-only systems, but the history is lost on restart.
+// It keeps no rollups, so coarse densities and long ranges are answered from the raw entries it holds.
+type memoryStorage struct {
+	lock        sync.RWMutex
+	capacity    int
+	history     map[string]*ring[HistoryMessage]
+	events      *ring[events.Event]
+	size        int64
+	rowsWritten atomic.Uint64
+}
+
+var _ Storage = (*memoryStorage)(nil)
+var _ SizedStorage = (*memoryStorage)(nil)
+var _ CountingStorage = (*memoryStorage)(nil)
+
+// ring is a ring buffer keeping up to its capacity of values in chronological order. Once it is full, every
+// insertion drops the oldest value.
+type ring[T any] struct {
+	values   []T
+	start    int
+	capacity int
+}
+
+// openMemoryFromConfig creates an empty memory storage keeping data.memory.capacity entries per monitor.
+func openMemoryFromConfig(bool) (Storage, error) {
+	capacity := config.GetConfig().Int("data.memory.capacity")
+	if capacity <= 0 {
+		return nil, fmt.Errorf("%w: data.memory.capacity needs to be positive. Is: %d", ErrInvalidCapacity, capacity)
+	}
+
+	return newMemoryStorage(capacity), nil
+}
+
+// newMemoryStorage creates an empty memory storage keeping capacity entries per monitor and capacity events.
+func newMemoryStorage(capacity int) *memoryStorage {
+	return &memoryStorage{
+		capacity: capacity,
+		history:  map[string]*ring[HistoryMessage]{},
+		events:   newRing[events.Event](capacity),
+	}
+}
+
+func newRing[T any](capacity int) *ring[T] {
+	return &ring[T]{capacity: capacity}
+}
+
+// len returns the number of values in the ring.
+func (r *ring[T]) len() int {
+	return len(r.values)
+}
+
+// at returns the value at the given position, counted from the oldest value.
+func (r *ring[T]) at(i int) T {
+	return r.values[(r.start+i)%len(r.values)]
+}
+
+func (r *ring[T]) set(i int, value T) {
+	r.values[(r.start+i)%len(r.values)] = value
+}
+
+// insert inserts value at its position by time and returns the value dropped to make room for it, if any.
+// Values older than all others are dropped themselves if the ring is full.
+func (r *ring[T]) insert(value T, timeOf func(T) time.Time) (T, bool) {
+	var dropped T
+	full := len(r.values) == r.capacity
+
+	if full {
+		if timeOf(value).Before(timeOf(r.at(0))) {
+			return value, true
+		}
+
+		dropped = r.at(0)
+		r.set(0, value)
+		r.start = (r.start + 1) % len(r.values)
+	} else {
+		r.values = append(r.values, value)
+	}
+
+	// Values are usually inserted in chronological order, so they rarely need to be moved.
+	i := len(r.values) - 1
+	for i > 0 && timeOf(value).Before(timeOf(r.at(i-1))) {
+		r.set(i, r.at(i-1))
+		i--
+	}
+
+	r.set(i, value)
+
+	return dropped, full
+}
+
+// between returns the values between from and until, both inclusively.
+func (r *ring[T]) between(from time.Time, until time.Time, timeOf func(T) time.Time) []T {
+	first := sort.Search(r.len(), func(i int) bool {
+		return !timeOf(r.at(i)).Before(from)
+	})
+
+	var values []T
+	for i := first; i < r.len() && !timeOf(r.at(i)).After(until); i++ {
+		values = append(values, r.at(i))
+	}
+
+	return values
+}
+
+func messageTime(message HistoryMessage) time.Time {
+	return message.Timestamp
+}
+
+func eventTime(event events.Event) time.Time {
+	return event.Timestamp
+}
+
+// sizeOf returns the number of bytes the strings of a message take up.
+func sizeOf(message HistoryMessage) int64 {
+	size := len(message.Message.Target) + len(message.Message.Value) + len(message.Host)
+	for key, value := range message.Labels {
+		size += len(key) + len(value)
+	}
+
+	return int64(size)
+}
+
+// AddHistoryEntries adds multiple entries to the history. Entries older than all others of a full ring are dropped.
+func (storage *memoryStorage) AddHistoryEntries(entries []HistoryEntry) error {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+
+	for _, entry := range entries {
+		message := HistoryMessage{Timestamp: entry.Timestamp.UTC(), Host: entry.Host, Labels: map[string]string{}}
+		message.Message.Target = entry.Target
+		message.Message.Value = entry.Content
+
+		for key, value := range entry.Labels {
+			message.Labels[key] = value
+		}
+
+		history, ok := storage.history[entry.Target]
+		if !ok {
+			history = newRing[HistoryMessage](storage.capacity)
+			storage.history[entry.Target] = history
+		}
+
+		storage.size += sizeOf(message)
+		if dropped, ok := history.insert(message, messageTime); ok {
+			storage.size -= sizeOf(dropped)
+		}
+	}
+
+	storage.rowsWritten.Add(uint64(len(entries)))
+
+	return nil
+}
+
+// GetHistoryEntriesFromUntil gets the history of target between from and until in chronological order.
+func (storage *memoryStorage) GetHistoryEntriesFromUntil(target string, from time.Time, until time.Time) (History, error) {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+
+	history, ok := storage.history[target]
+	if !ok {
+		return History{}, nil
+	}
+
+	return append(History{}, history.between(from, until, messageTime)...), nil
+}
+
+// GetLatestHistoryEntries gets the n newest entries of target up to until in chronological order.
+func (storage *memoryStorage) GetLatestHistoryEntries(target string, until time.Time, n int) (History, error) {
+	history, err := storage.GetHistoryEntriesFromUntil(target, time.Time{}, until)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history) > n {
+		history = history[len(history)-n:]
+	}
+
+	return history, nil
+}
+
+// GetTargets returns the targets matching at least one of the given patterns in alphabetical order.
+// Without patterns, all targets are returned.
+func (storage *memoryStorage) GetTargets(patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if err := pubsub.ValidatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+
+	var targets []string
+	for target := range storage.history {
+		if len(patterns) == 0 {
+			targets = append(targets, target)
+			continue
+		}
+
+		for _, pattern := range patterns {
+			if pubsub.MatchTopic(pattern, target) {
+				targets = append(targets, target)
+				break
+			}
+		}
+	}
+
+	sort.Strings(targets)
+
+	return targets, nil
+}
+
+// WalkHistory calls fn for every history entry of the targets matching the given patterns between from and until in
+// chronological order. Walking stops at the first error returned by fn.
+func (storage *memoryStorage) WalkHistory(patterns []string, from time.Time, until time.Time, fn func(message HistoryMessage) error) error {
+	targets, err := storage.GetTargets(patterns)
+	if err != nil {
+		return err
+	}
+
+	var history History
+	for _, target := range targets {
+		entries, err := storage.GetHistoryEntriesFromUntil(target, from, until)
+		if err != nil {
+			return err
+		}
+
+		history = append(history, entries...)
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	for _, message := range history {
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetEarliest returns the time of the earliest entry of the history. It returns false if the history is empty.
+func (storage *memoryStorage) GetEarliest() (time.Time, bool, error) {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+
+	var earliest time.Time
+	found := false
+
+	for _, history := range storage.history {
+		if history.len() == 0 {
+			continue
+		}
+
+		if timestamp := history.at(0).Timestamp; !found || timestamp.Before(earliest) {
+			earliest = timestamp
+			found = true
+		}
+	}
+
+	return earliest, found, nil
+}
+
+// AddEvent adds an event to the event stream.
+func (storage *memoryStorage) AddEvent(event events.Event) error {
+	attributes := map[string]string{}
+	for key, value := range event.Attributes {
+		attributes[key] = value
+	}
+
+	event.Timestamp = event.Timestamp.UTC()
+	event.Attributes = attributes
+
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+
+	storage.events.insert(event, eventTime)
+
+	return nil
+}
+
+// GetEvents gets all events between "from" and "until" ordered by time.
+func (storage *memoryStorage) GetEvents(from time.Time, until time.Time) ([]events.Event, error) {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+
+	return append([]events.Event{}, storage.events.between(from, until, eventTime)...), nil
+}
+
+// GetRowsWritten returns the number of history entries written since the storage has been created.
+func (storage *memoryStorage) GetRowsWritten() uint64 {
+	return storage.rowsWritten.Load()
+}
+
+// GetSize returns the number of bytes the strings of the history entries take up.
+func (storage *memoryStorage) GetSize() (int64, error) {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+
+	return storage.size, nil
+}
+
+// Close does nothing, the history is lost with the process.
+func (storage *memoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	base := time.Now()
+	timeOf := func(offset int) time.Time { return base.Add(time.Duration(offset) * time.Second) }
+
+	r := newRing[int](3)
+
+	contents := func() []int {
+		var result []int
+		for i := 0; i < r.len(); i++ {
+			result = append(result, r.at(i))
+		}
+
+		return result
+	}
+
+	for _, value := range []int{1, 3, 2} {
+		_, dropped := r.insert(value, timeOf)
+		assert.False(t, dropped)
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, contents())
+
+	// Once full, the oldest value makes room for newer ones.
+	dropped, ok := r.insert(5, timeOf)
+	assert.True(t, ok)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, []int{2, 3, 5}, contents())
+
+	dropped, ok = r.insert(4, timeOf)
+	assert.True(t, ok)
+	assert.Equal(t, 2, dropped)
+	assert.Equal(t, []int{3, 4, 5}, contents())
+
+	// Values older than all others aren't inserted into a full ring.
+	dropped, ok = r.insert(0, timeOf)
+	assert.True(t, ok)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, []int{3, 4, 5}, contents())
+
+	assert.Equal(t, []int{4, 5}, r.between(timeOf(4), timeOf(10), timeOf))
+}
+
+func TestMemoryStorageCapacity(t *testing.T) {
+	storage := newMemoryStorage(2)
+	now := time.Now()
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: "First"},
+		{Timestamp: now.Add(time.Second), Target: "CPU.Usage", Content: "Second"},
+		{Timestamp: now, Target: "Memory.RAM", Content: "Other"},
+	}))
+
+	size, err := storage.GetSize()
+	require.NoError(t, err)
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(2 * time.Second), Target: "CPU.Usage", Content: "Third"},
+	}))
+
+	// Every monitor keeps its own entries.
+	history, err := GetHistory(storage, "CPU.Usage", time.Time{}, now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Second", "Third"}, values(history))
+
+	history, err = GetHistory(storage, "Memory.RAM", time.Time{}, now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Other"}, values(history))
+
+	// The dropped entry is as large as the new one.
+	newSize, err := storage.GetSize()
+	require.NoError(t, err)
+	assert.Equal(t, size, newSize)
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+// createMigrationsStatement creates the table recording the migrations applied to the database.
+const createMigrationsStatement string = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied DATETIME NOT NULL
+	);
+`
+
+// migration is a step of the database layout from the version before it to its version.
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *sql.Tx) error
+}
+
+// migrations are the steps of the database layout in the order they are applied. Released migrations must never be
+// changed, new layouts are added as new steps at the end.
+// The first four steps recreate the layout of the versions before migrations were introduced. They don't fail on
+// tables and columns that exist already, so these databases are migrated like every other one.
+var migrations = []migration{
+	{1, "Create history table", execMigration(`
+		CREATE TABLE IF NOT EXISTS history (
+				time DATETIME NOT NULL,
+				target TEXT NOT NULL,
+				content TEXT NOT NULL,
+				PRIMARY KEY (time, target)
+		);
+	`)},
+	{2, "Add identity columns to history", addIdentityColumns},
+	{3, "Create events table", execMigration(`
+		CREATE TABLE IF NOT EXISTS events (
+				time DATETIME NOT NULL,
+				type TEXT NOT NULL,
+				severity TEXT NOT NULL,
+				source TEXT NOT NULL,
+				message TEXT NOT NULL,
+				attributes TEXT NOT NULL DEFAULT '{}',
+				host TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS events_time ON events (time);
+	`)},
+	{4, "Create rollup tables", execMigration(`
+		CREATE TABLE IF NOT EXISTS rollups (
+				resolution INTEGER NOT NULL,
+				time DATETIME NOT NULL,
+				target TEXT NOT NULL,
+				content TEXT NOT NULL,
+				fields TEXT NOT NULL DEFAULT '{}',
+				host TEXT NOT NULL DEFAULT '',
+				labels TEXT NOT NULL DEFAULT '{}',
+				PRIMARY KEY (resolution, target, time)
+		);
+		CREATE TABLE IF NOT EXISTS rollup_progress (
+				resolution INTEGER PRIMARY KEY,
+				until DATETIME NOT NULL
+		);
+	`)},
+	// The primary key on (time, target) rejected two messages of a monitor with the same timestamp, so the history is
+	// rebuilt with indexes in its place.
+	{5, "Drop primary key of history", execMigration(`
+		CREATE TABLE history_new (
+				time DATETIME NOT NULL,
+				target TEXT NOT NULL,
+				content TEXT NOT NULL,
+				host TEXT NOT NULL DEFAULT '',
+				labels TEXT NOT NULL DEFAULT '{}'
+		);
+		INSERT INTO history_new (time, target, content, host, labels) SELECT time, target, content, host, labels FROM history;
+		DROP TABLE history;
+		ALTER TABLE history_new RENAME TO history;
+		CREATE INDEX history_target_time ON history (target, time);
+		CREATE INDEX history_time ON history (time);
+	`)},
+	// Series values are read in large numbers, so their time is stored as Unix nanoseconds, which is scanned faster.
+	{6, "Add numeric series", execMigration(`
+		CREATE TABLE series (
+				id INTEGER PRIMARY KEY,
+				target TEXT NOT NULL,
+				field TEXT NOT NULL,
+				since DATETIME NOT NULL,
+				UNIQUE (target, field)
+		);
+		CREATE TABLE series_values (
+				series_id INTEGER NOT NULL,
+				time INTEGER NOT NULL,
+				value REAL NOT NULL
+		);
+		CREATE INDEX series_values_series_time ON series_values (series_id, time);
+		CREATE INDEX series_values_time ON series_values (time);
+	`)},
+}
+
+// identityColumns are the columns added to the history table to tell apart the data of multiple Excubitor instances.
+var identityColumns = []struct {
+	name       string
+	definition string
+}{
+	{"host", `TEXT NOT NULL DEFAULT ''`},
+	{"labels", `TEXT NOT NULL DEFAULT '{}'`},
+}
+
+// execMigration returns a migration step executing the given statements.
+func execMigration(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// migrate applies all migrations the database hasn't seen yet. Every migration is applied within a transaction of
+// its own together with its entry in schema_migrations, so a failed migration leaves the database at the version
+// before it.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(createMigrationsStatement); err != nil {
+		return err
+	}
+
+	version, err := readSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := migrations[len(migrations)-1].version
+	if version > latest {
+		return fmt.Errorf("%w: database has version %d, but this version of Excubitor only knows up to version %d", ErrUnknownSchemaVersion, version, latest)
+	}
+
+	for _, step := range migrations {
+		if step.version <= version {
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Migrating database to version %d: %s", step.version, step.description))
+
+		if err := applyMigration(db, step); err != nil {
+			return fmt.Errorf("could not migrate database to version %d: %w", step.version, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration applies a single migration and records it in schema_migrations.
+func applyMigration(db *sql.DB, step migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := step.apply(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description, applied) VALUES (?, ?, ?);`, step.version, step.description, time.Now().UTC()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// readSchemaVersion returns the version of the latest migration applied to the database, zero if there is none.
+func readSchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`).Scan(&version)
+
+	return version, err
+}
+
+// addIdentityColumns adds the columns in identityColumns to the history table if they don't exist yet.
+func addIdentityColumns(tx *sql.Tx) error {
+	columns, err := queryStrings(tx, `SELECT name FROM pragma_table_info('history')`)
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, name := range columns {
+		existing[name] = true
+	}
+
+	for _, column := range identityColumns {
+		if existing[column.name] {
+			continue
+		}
+
+		logger.Debug(fmt.Sprintf("Adding column %s to history table.", column.name))
+
+		if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE history ADD COLUMN %s %s`, column.name, column.definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openFixture opens a new database in a temporary directory and loads the SQL fixture from testdata into it.
+// An empty fixture opens an empty database.
+func openFixture(t *testing.T, fixture string) *sql.DB {
+	// The logger is set up on initialization.
+	require.NoError(t, OpenDatabase())
+
+	db, err := sql.Open("sqlite3", dataSourceName(filepath.Join(t.TempDir(), "fixture.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	if fixture != "" {
+		statements, err := os.ReadFile(filepath.Join("testdata", fixture))
+		require.NoError(t, err)
+
+		_, err = db.Exec(string(statements))
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+// assertLatestLayout checks that the database is at the latest version and that messages of a monitor with the same
+// timestamp no longer collide.
+func assertLatestLayout(t *testing.T, db *sql.DB) {
+	version, err := readSchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+
+	indexes, err := queryStrings(db, `SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'history' ORDER BY name;`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"history_target_time", "history_time"}, indexes)
+
+	writer, err := newWriter(db)
+	require.NoError(t, err)
+	defer func() { _ = writer.close() }()
+
+	timestamp := time.Now()
+	assert.NoError(t, writer.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: timestamp, Target: "Some.Target", Content: "First"},
+		{Timestamp: timestamp, Target: "Some.Target", Content: "Second"},
+	}))
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	db := openFixture(t, "")
+
+	require.NoError(t, migrate(db))
+	assertLatestLayout(t, db)
+
+	for _, table := range []string{"history", "events", "rollups", "rollup_progress", "series", "series_values"} {
+		tables, err := queryStrings(db, `SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?;`, table)
+		require.NoError(t, err)
+		assert.Equal(t, []string{table}, tables)
+	}
+}
+
+func TestMigrateInitial(t *testing.T) {
+	db := openFixture(t, "initial.sql")
+
+	require.NoError(t, migrate(db))
+	assertLatestLayout(t, db)
+
+	history, err := (&Reader{db}).GetHistoryEntries("CPU.Usage")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(history))
+	assert.True(t, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC).Equal(history[0].Timestamp))
+	assert.Equal(t, `{"usage": 10}`, history[0].Message.Value)
+	assert.Equal(t, `{"usage": 20}`, history[1].Message.Value)
+
+	// Entries written before the identity columns existed belong to no host.
+	assert.Empty(t, history[0].Host)
+	assert.Empty(t, history[0].Labels)
+}
+
+func TestMigrateUnversioned(t *testing.T) {
+	db := openFixture(t, "unversioned.sql")
+
+	require.NoError(t, migrate(db))
+	assertLatestLayout(t, db)
+
+	history, err := (&Reader{db}).GetHistoryEntries("Memory.RAM")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(history))
+	assert.Equal(t, `{"total": 16384}`, history[0].Message.Value)
+	assert.Equal(t, "some-host", history[0].Host)
+	assert.Equal(t, map[string]string{"env": "prod"}, history[0].Labels)
+
+	events, err := queryStrings(db, `SELECT message FROM events;`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Started"}, events)
+
+	progress, ok, err := readProgress(db, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC).Equal(progress))
+}
+
+func TestMigrateTwice(t *testing.T) {
+	db := openFixture(t, "initial.sql")
+
+	require.NoError(t, migrate(db))
+	require.NoError(t, migrate(db))
+
+	versions, err := queryStrings(db, `SELECT version FROM schema_migrations ORDER BY version;`)
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), len(versions))
+
+	count, err := queryStrings(db, `SELECT count(*) FROM history;`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, count)
+}
+
+func TestMigrateUnknownVersion(t *testing.T) {
+	db := openFixture(t, "")
+
+	require.NoError(t, migrate(db))
+
+	_, err := db.Exec(`INSERT INTO schema_migrations (version, description, applied) VALUES (?, ?, ?);`, len(migrations)+1, "From the future", time.Now())
+	require.NoError(t, err)
+
+	assert.True(t, errors.Is(migrate(db), ErrUnknownSchemaVersion))
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := openFixture(t, "")
+
+	require.NoError(t, migrate(db))
+
+	failing := migration{version: len(migrations) + 1, description: "Failing", apply: execMigration(`
+		CREATE TABLE some_table (value TEXT);
+		SELECT * FROM missing_table;
+	`)}
+
+	assert.Error(t, applyMigration(db, failing))
+
+	// The failed migration is rolled back completely.
+	version, err := readSchemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+
+	tables, err := queryStrings(db, `SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'some_table';`)
+	require.NoError(t, err)
+	assert.Empty(t, tables)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -17,7 +18,7 @@ func GetReader() *Reader {
 // GetHistoryEntries gets history all entries from the loaded database file
 func (reader *Reader) GetHistoryEntries(target string) (History, error) {
 	stmt, err := reader.db.Prepare(`
-		SELECT * FROM history WHERE target = ?;
+		SELECT time, target, content, host, labels FROM history WHERE target = ? ORDER BY time;
 	`)
 	if err != nil {
 		return nil, err
@@ -56,13 +57,34 @@ func (reader *Reader) GetHistoryEntriesFromThinned(target string, from time.Time
 // GetHistoryEntriesFromUntil gets History entries after "from" and before "until"
 func (reader *Reader) GetHistoryEntriesFromUntil(target string, from time.Time, until time.Time) (History, error) {
 	stmt, err := reader.db.Prepare(`
-		SELECT * FROM history WHERE target = ? AND time >= ? AND time <= ?;
+		SELECT time, target, content, host, labels FROM history WHERE target = ? AND time >= ? AND time <= ? ORDER BY time;
 	`)
 	if err != nil {
 		return nil, err
 	}
 
-	return retrieveHistoryFromDB(stmt, target, from, until)
+	return retrieveHistoryFromDB(stmt, target, from.UTC(), until.UTC())
+}
+
+// GetLatestHistoryEntries gets the n newest History entries before "until" in chronological order.
+func (reader *Reader) GetLatestHistoryEntries(target string, until time.Time, n int) (History, error) {
+	stmt, err := reader.db.Prepare(`
+		SELECT time, target, content, host, labels FROM history WHERE target = ? AND time <= ? ORDER BY time DESC LIMIT ?;
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := retrieveHistoryFromDB(stmt, target, until.UTC(), n)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+
+	return data, nil
 }
 
 // GetHistoryEntriesFromUntilThinned thins out the history data gathered
@@ -125,22 +147,40 @@ func thinData(history History, maxDensity time.Duration) History {
 func collectHistoryMessages(rows *sql.Rows) (History, error) {
 	data := History{}
 	for rows.Next() {
-		message := HistoryMessage{}
-		err := rows.Scan(&message.Timestamp, &message.Message.Target, &message.Message.Value)
+		message, ok, err := scanHistoryMessage(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		decompressedValue, err := decompress(message.Message.Value)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Could not decompress value of timestamp %s of target %s! Reason: %s", message.Timestamp.UTC().String(), message.Message.Target, err))
-			continue
+		if ok {
+			data = append(data, message)
 		}
+	}
 
-		message.Message.Value = decompressedValue
+	return data, nil
+}
 
-		data = append(data, message)
+// scanHistoryMessage scans the current row of a history query into a HistoryMessage and decompresses its value.
+// It returns false if the value can't be decompressed.
+func scanHistoryMessage(rows *sql.Rows) (HistoryMessage, bool, error) {
+	message := HistoryMessage{}
+	var labels string
+	err := rows.Scan(&message.Timestamp, &message.Message.Target, &message.Message.Value, &message.Host, &labels)
+	if err != nil {
+		return HistoryMessage{}, false, err
 	}
 
-	return data, nil
+	if err := json.Unmarshal([]byte(labels), &message.Labels); err != nil {
+		logger.Error(fmt.Sprintf("Could not parse labels of timestamp %s of target %s! Reason: %s", message.Timestamp.UTC().String(), message.Message.Target, err))
+	}
+
+	decompressedValue, err := decompress(message.Message.Value)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not decompress value of timestamp %s of target %s! Reason: %s", message.Timestamp.UTC().String(), message.Message.Target, err))
+		return HistoryMessage{}, false, nil
+	}
+
+	message.Message.Value = decompressedValue
+
+	return message, true, nil
 }
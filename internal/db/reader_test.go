@@ -8,7 +8,7 @@ import (
 )
 
 func TestReader_GetHistoryEntriesByTarget(t *testing.T) {
-	if err := InitDatabase(); err != nil {
+	if err := OpenDatabase(); err != nil {
 		t.Error(err)
 		return
 	}
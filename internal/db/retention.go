@@ -0,0 +1,339 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"time"
+)
+
+var ErrInvalidRetentionPolicy = errors.New("invalid retention policy")
+
+// sizeEnforcementSteps is the number of steps the span of the stored data is deleted in, oldest first, to get the
+// database below its maximum size.
+const sizeEnforcementSteps = 100
+
+// RetentionPolicy sets the storage time of the monitors matching a pattern.
+// It replaces data.storage_time for the raw history of these monitors. Their rollups are kept as long as configured
+// for the tier, but not longer than StorageTime.
+type RetentionPolicy struct {
+	Monitor     string
+	StorageTime time.Duration
+}
+
+// readRetentionPolicies reads the retention policies from the configuration.
+func readRetentionPolicies() ([]RetentionPolicy, error) {
+	var configured []struct {
+		Monitor     string `koanf:"monitor"`
+		StorageTime string `koanf:"storage_time"`
+	}
+
+	if err := config.GetConfig().Unmarshal("data.retention_policies", &configured); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRetentionPolicy, err)
+	}
+
+	policies := make([]RetentionPolicy, len(configured))
+	for i, policy := range configured {
+		if err := pubsub.ValidatePattern(policy.Monitor); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRetentionPolicy, err)
+		}
+
+		storageTime, err := config.ParseDuration(policy.StorageTime)
+		if err != nil || storageTime <= 0 {
+			return nil, fmt.Errorf("%w: storage time of %s needs to be a positive duration. Is: %s", ErrInvalidRetentionPolicy, policy.Monitor, policy.StorageTime)
+		}
+
+		policies[i] = RetentionPolicy{Monitor: policy.Monitor, StorageTime: storageTime}
+	}
+
+	return policies, nil
+}
+
+// storageTimeOf returns how long the entries of a monitor are kept in the tier with the given index.
+// The first policy matching the monitor applies.
+func storageTimeOf(monitor string, index int, tierStorageTime time.Duration, policies []RetentionPolicy) time.Duration {
+	for _, policy := range policies {
+		if !pubsub.MatchTopic(policy.Monitor, monitor) {
+			continue
+		}
+
+		if index == 0 || policy.StorageTime < tierStorageTime {
+			return policy.StorageTime
+		}
+
+		break
+	}
+
+	return tierStorageTime
+}
+
+// purgeTier deletes the entries of the tier with the given index that are older than the storage time of their monitor.
+// Entries that haven't been rolled up into the next tier yet are kept regardless, as compaction may lag behind, e.g.
+// after downtime or an upgrade.
+func purgeTier(db *sql.DB, index int, policies []RetentionPolicy, now time.Time) error {
+	tierStorageTime, err := config.ParseDuration(config.GetConfig().String(tiers[index].storageKey))
+	if err != nil {
+		return err
+	}
+
+	var compacted time.Time
+	if index+1 < len(tiers) {
+		progress, ok, err := readProgress(db, index+1)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			logger.Debug(fmt.Sprintf("Skipping purge of tier %d as it hasn't been rolled up yet.", index))
+			return nil
+		}
+
+		compacted = progress.UTC()
+	}
+
+	var targets []string
+	if index == 0 {
+		targets, err = queryStrings(db, `SELECT DISTINCT target FROM history;`)
+	} else {
+		targets, err = queryStrings(db, `SELECT DISTINCT target FROM rollups WHERE resolution = ?;`, tiers[index].seconds())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var deleted int64
+	for _, target := range targets {
+		before := now.Add(-storageTimeOf(target, index, tierStorageTime, policies)).UTC()
+		if !compacted.IsZero() && compacted.Before(before) {
+			before = compacted
+		}
+
+		var result sql.Result
+		if index == 0 {
+			result, err = db.Exec(`DELETE FROM history WHERE target = ? AND time < ?;`, target, before)
+		} else {
+			result, err = db.Exec(`DELETE FROM rollups WHERE resolution = ? AND target = ? AND time < ?;`, tiers[index].seconds(), target, before)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil {
+			deleted += rowsAffected
+		}
+	}
+
+	if index == 0 {
+		logger.Debug(fmt.Sprintf("Deleted %d rows from history on purge.", deleted))
+	} else {
+		logger.Debug(fmt.Sprintf("Deleted %d %s rollups on purge.", deleted, tiers[index].label))
+	}
+
+	return nil
+}
+
+// seriesStorageTimeOf returns how long the series of a monitor are kept. Series replace the raw history for field
+// queries, so they are kept as long as the raw history and at least as long as the minute rollups they take over from.
+func seriesStorageTimeOf(monitor string, rawStorageTime time.Duration, minuteStorageTime time.Duration, policies []RetentionPolicy) time.Duration {
+	raw := storageTimeOf(monitor, 0, rawStorageTime, policies)
+	minutes := storageTimeOf(monitor, 1, minuteStorageTime, policies)
+
+	if raw > minutes {
+		return raw
+	}
+
+	return minutes
+}
+
+// purgeSeries deletes the values of the series that are older than the storage time of their monitor and moves the
+// since time of the series past them, so that queries reaching further back are answered from the rollups.
+func purgeSeries(db *sql.DB, policies []RetentionPolicy, now time.Time) error {
+	var storageTimes [2]time.Duration
+	for index := range storageTimes {
+		storageTime, err := config.ParseDuration(config.GetConfig().String(tiers[index].storageKey))
+		if err != nil {
+			return err
+		}
+
+		storageTimes[index] = storageTime
+	}
+
+	targets, err := queryStrings(db, `SELECT DISTINCT target FROM series;`)
+	if err != nil {
+		return err
+	}
+
+	var deleted int64
+	for _, target := range targets {
+		before := now.Add(-seriesStorageTimeOf(target, storageTimes[0], storageTimes[1], policies)).UTC()
+
+		result, err := db.Exec(`DELETE FROM series_values WHERE series_id IN (SELECT id FROM series WHERE target = ?) AND time < ?;`, target, before.UnixNano())
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.Exec(`UPDATE series SET since = ? WHERE target = ? AND since < ?;`, before, target, before); err != nil {
+			return err
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil {
+			deleted += rowsAffected
+		}
+	}
+
+	logger.Debug(fmt.Sprintf("Deleted %d series values on purge.", deleted))
+
+	return nil
+}
+
+// queryStrings returns the first column of all rows of a query.
+func queryStrings(db querier, query string, args ...any) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Close()
+}
+
+// readMaxDatabaseSize parses the maximum database size from the configuration. Zero disables the limit.
+func readMaxDatabaseSize() (int64, error) {
+	return config.ParseSize(config.GetConfig().String("data.max_database_size"))
+}
+
+// getUsedSize returns the size of all database pages in use. Pages freed by deletions don't count until they are
+// released by a vacuum.
+func getUsedSize(db *sql.DB) (int64, error) {
+	var pageCount, freePages, pageSize int64
+
+	for pragma, target := range map[string]*int64{"page_count": &pageCount, "freelist_count": &freePages, "page_size": &pageSize} {
+		if err := db.QueryRow(fmt.Sprintf(`PRAGMA %s`, pragma)).Scan(target); err != nil {
+			return 0, err
+		}
+	}
+
+	return (pageCount - freePages) * pageSize, nil
+}
+
+// enforceMaxSize deletes the oldest history with its series, rollups and events until the database uses at most maxSize bytes.
+func enforceMaxSize(db *sql.DB, maxSize int64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	var step time.Duration
+
+	for {
+		used, err := getUsedSize(db)
+		if err != nil {
+			return err
+		}
+
+		if used <= maxSize {
+			return nil
+		}
+
+		oldest, newest, ok, err := readStoredSpan(db)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			logger.Warn(fmt.Sprintf("Database uses %d bytes without any data left to delete, which exceeds the maximum size of %d bytes!", used, maxSize))
+			return nil
+		}
+
+		// The step is fixed on the first iteration so that the whole span is deleted after sizeEnforcementSteps at most.
+		if step == 0 {
+			step = newest.Sub(oldest) / sizeEnforcementSteps
+			if step < time.Minute {
+				step = time.Minute
+			}
+		}
+
+		before := oldest.Add(step).UTC()
+
+		logger.Debug(fmt.Sprintf("Database uses %d of %d bytes, deleting all data before %s.", used, maxSize, before))
+
+		for _, table := range []string{"history", "rollups", "events"} {
+			if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE time < ?`, table), before); err != nil {
+				return err
+			}
+		}
+
+		if _, err := db.Exec(`DELETE FROM series_values WHERE time < ?`, before.UnixNano()); err != nil {
+			return err
+		}
+
+		if _, err := db.Exec(`UPDATE series SET since = ? WHERE since < ?`, before, before); err != nil {
+			return err
+		}
+	}
+}
+
+// readStoredSpan returns the time of the oldest and the newest entry of the history, the rollups and the events.
+// It returns false if there are no entries.
+func readStoredSpan(db *sql.DB) (time.Time, time.Time, bool, error) {
+	var oldest, newest time.Time
+	found := false
+
+	for _, table := range []string{"history", "rollups", "events"} {
+		for _, order := range []string{"ASC", "DESC"} {
+			var timestamp time.Time
+			err := db.QueryRow(fmt.Sprintf(`SELECT time FROM %s ORDER BY time %s LIMIT 1;`, table, order)).Scan(&timestamp)
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+
+			if err != nil {
+				return time.Time{}, time.Time{}, false, err
+			}
+
+			if !found || timestamp.Before(oldest) {
+				oldest = timestamp
+			}
+
+			if !found || timestamp.After(newest) {
+				newest = timestamp
+			}
+
+			found = true
+		}
+	}
+
+	return oldest, newest, found, nil
+}
+
+// incrementalVacuum releases the pages freed by deletions to the file system.
+func incrementalVacuum(db *sql.DB) error {
+	// The pragma frees a page per step, so all of its rows need to be read.
+	rows, err := db.Query(`PRAGMA incremental_vacuum`)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+	}
+
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+
+	return rows.Close()
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStorageTimeOf(t *testing.T) {
+	policies := []RetentionPolicy{
+		{Monitor: "CPU.CpuInfo", StorageTime: 24 * time.Hour},
+		{Monitor: "CPU.*", StorageTime: 90 * 24 * time.Hour},
+		{Monitor: "Checks.#", StorageTime: 365 * 24 * time.Hour},
+	}
+
+	day := 24 * time.Hour
+
+	assert.Equal(t, day, storageTimeOf("CPU.CpuInfo", 0, 2*day, policies))
+	assert.Equal(t, 90*day, storageTimeOf("CPU.Usage", 0, 2*day, policies))
+	assert.Equal(t, 365*day, storageTimeOf("Checks.HTTP.Example", 0, 2*day, policies))
+	assert.Equal(t, 2*day, storageTimeOf("Memory.RAM", 0, 2*day, policies))
+
+	// Policies only shorten the storage time of rollups.
+	assert.Equal(t, day, storageTimeOf("CPU.CpuInfo", 1, 30*day, policies))
+	assert.Equal(t, 30*day, storageTimeOf("CPU.Usage", 1, 30*day, policies))
+	assert.Equal(t, 90*day, storageTimeOf("CPU.Usage", 2, 365*day, policies))
+	assert.Equal(t, 30*day, storageTimeOf("Checks.HTTP.Example", 1, 30*day, policies))
+}
+
+func TestReadRetentionPolicies(t *testing.T) {
+	setPolicies := func(policies []map[string]interface{}) {
+		restore, err := config.Override(map[string]interface{}{
+			"data.retention_policies": policies,
+		})
+		require.NoError(t, err)
+		t.Cleanup(restore)
+	}
+
+	setPolicies([]map[string]interface{}{
+		{"monitor": "CPU.CpuInfo", "storage_time": "1d"},
+		{"monitor": "Checks.#", "storage_time": "1y"},
+	})
+
+	policies, err := readRetentionPolicies()
+	require.NoError(t, err)
+	assert.Equal(t, []RetentionPolicy{
+		{Monitor: "CPU.CpuInfo", StorageTime: 24 * time.Hour},
+		{Monitor: "Checks.#", StorageTime: 365 * 24 * time.Hour},
+	}, policies)
+
+	for _, invalid := range []map[string]interface{}{
+		{"monitor": "CPU.#.Usage", "storage_time": "1d"},
+		{"monitor": "CPU.Usage", "storage_time": "forever"},
+		{"monitor": "CPU.Usage", "storage_time": "-1d"},
+	} {
+		setPolicies([]map[string]interface{}{invalid})
+
+		_, err := readRetentionPolicies()
+		assert.True(t, errors.Is(err, ErrInvalidRetentionPolicy), invalid)
+	}
+}
+
+func TestPurgeTier(t *testing.T) {
+	base := time.Now().Add(-5 * time.Hour).Truncate(time.Hour).UTC()
+	insertRollupTestData(t, base)
+
+	db := GetWriter().db
+	now := time.Now()
+
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(-48 * time.Hour), Target: "CPU.CpuInfo", Content: `{"model": "Some CPU"}`},
+		{Timestamp: now.Add(-time.Hour), Target: "CPU.CpuInfo", Content: `{"model": "Some CPU"}`},
+		{Timestamp: now.Add(-48 * time.Hour), Target: "Memory.RAM", Content: `{"used": 1024}`},
+	}))
+
+	policies := []RetentionPolicy{{Monitor: "CPU.*", StorageTime: 2 * time.Hour}}
+
+	require.NoError(t, compact(db, now))
+	require.NoError(t, purgeTier(db, 0, policies, now))
+
+	cpuInfo, err := GetReader().GetHistoryEntries("CPU.CpuInfo")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(cpuInfo))
+	assert.True(t, now.Add(-time.Hour).Equal(cpuInfo[0].Timestamp))
+
+	usage, err := GetReader().GetHistoryEntries("CPU.Usage")
+	require.NoError(t, err)
+	assert.Empty(t, usage)
+
+	memory, err := GetReader().GetHistoryEntries("Memory.RAM")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(memory))
+
+	require.NoError(t, purgeTier(db, 1, policies, now))
+
+	minutes, err := readTier(db, 1, "CPU.Usage", base, now)
+	require.NoError(t, err)
+	assert.Empty(t, minutes)
+
+	minutes, err = readTier(db, 1, "Memory.RAM", base, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(minutes))
+}
+
+func TestPurgeTierUncompacted(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	db := GetWriter().db
+	now := time.Now()
+
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(-72 * time.Hour), Target: "CPU.Usage", Content: `{"usage": 10}`},
+		{Timestamp: now.Add(-36 * time.Hour), Target: "CPU.Usage", Content: `{"usage": 20}`},
+		{Timestamp: now.Add(-time.Hour), Target: "CPU.Usage", Content: `{"usage": 30}`},
+	}))
+
+	policies := []RetentionPolicy{{Monitor: "CPU.*", StorageTime: 2 * time.Hour}}
+
+	// Nothing has been rolled up, so the raw history is kept.
+	require.NoError(t, purgeTier(db, 0, policies, now))
+
+	history, err := GetReader().GetHistoryEntries("CPU.Usage")
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(history))
+
+	// Raw history is only purged as far as it has been rolled up.
+	require.NoError(t, compact(db, now.Add(-48*time.Hour)))
+	require.NoError(t, purgeTier(db, 0, policies, now))
+
+	history, err = GetReader().GetHistoryEntries("CPU.Usage")
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(history))
+
+	require.NoError(t, compact(db, now))
+	require.NoError(t, purgeTier(db, 0, policies, now))
+
+	history, err = GetReader().GetHistoryEntries("CPU.Usage")
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(history))
+}
+
+func TestEnforceMaxSize(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	db := GetWriter().db
+	start := time.Now().Add(-1000 * time.Minute).Truncate(time.Minute)
+
+	var entries []HistoryEntry
+	for i := 0; i < 1000; i++ {
+		content := make([]byte, 512)
+		_, err := rand.Read(content)
+		require.NoError(t, err)
+
+		entries = append(entries, HistoryEntry{
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+			Target:    "Some.Target",
+			Content:   hex.EncodeToString(content),
+		})
+	}
+
+	require.NoError(t, GetWriter().AddHistoryEntries(entries))
+
+	used, err := getUsedSize(db)
+	require.NoError(t, err)
+
+	require.NoError(t, enforceMaxSize(db, used/2))
+
+	remaining, err := getUsedSize(db)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, remaining, used/2)
+
+	history, err := GetReader().GetHistoryEntries("Some.Target")
+	require.NoError(t, err)
+	assert.Less(t, len(history), 600)
+	assert.Greater(t, len(history), 300)
+
+	// The newest entries are kept.
+	newest := start.Add(999 * time.Minute)
+	var kept bool
+	for _, entry := range history {
+		assert.True(t, entry.Timestamp.After(start))
+		kept = kept || entry.Timestamp.Equal(newest)
+	}
+
+	assert.True(t, kept)
+
+	require.NoError(t, incrementalVacuum(db))
+
+	var freePages int
+	require.NoError(t, db.QueryRow(`PRAGMA freelist_count`).Scan(&freePages))
+	assert.Equal(t, 0, freePages)
+
+	var autoVacuum int
+	require.NoError(t, db.QueryRow(`PRAGMA auto_vacuum`).Scan(&autoVacuum))
+	assert.Equal(t, 2, autoVacuum) // INCREMENTAL
+}
@@ -0,0 +1,689 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"math"
+	"time"
+)
+
+// tier is a level of the tiered history. The first tier is the raw history, every further tier is compacted from
+// the one before it.
+type tier struct {
+	label      string
+	resolution time.Duration
+	storageKey string
+}
+
+var tiers = []tier{
+	{label: "", resolution: 0, storageKey: "data.storage_time"},
+	{label: "1m", resolution: time.Minute, storageKey: "data.rollups.minute_storage_time"},
+	{label: "1h", resolution: time.Hour, storageKey: "data.rollups.hour_storage_time"},
+}
+
+// seconds returns the resolution of the tier as stored in the rollups table.
+func (tier tier) seconds() int64 {
+	return int64(tier.resolution / time.Second)
+}
+
+// compactionDelay is the time compaction waits for the messages of a bucket to be written by the recorder.
+const compactionDelay = time.Minute
+
+// compactionChunk is the range compacted within a single transaction when catching up.
+const compactionChunk = 6 * time.Hour
+
+// fieldRollup is the stored summary of a numeric field within a bucket.
+// It keeps the sum instead of the average so that buckets can be merged into coarser ones.
+type fieldRollup struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+	Last  float64 `json:"last"`
+	Count int     `json:"count"`
+}
+
+// merge adds the values summarized by a later rollup of the same field.
+func (field *fieldRollup) merge(later fieldRollup) {
+	if field.Count == 0 {
+		*field = later
+		return
+	}
+
+	field.Min = math.Min(field.Min, later.Min)
+	field.Max = math.Max(field.Max, later.Max)
+	field.Sum += later.Sum
+	field.Last = later.Last
+	field.Count += later.Count
+}
+
+// rollupEntry is an entry of a tier together with the summaries of its numeric fields.
+// Every message of the raw history is an entry of its own summarizing just its values.
+type rollupEntry struct {
+	message HistoryMessage
+	fields  map[string]fieldRollup
+}
+
+// rawEntry constructs the entry of a single message of the raw history.
+func rawEntry(message HistoryMessage) rollupEntry {
+	entry := rollupEntry{message: message, fields: map[string]fieldRollup{}}
+
+	// Messages that aren't JSON have no numeric fields, their buckets only keep the last message.
+	value, err := jsonfields.Parse(message.Message.Value)
+	if err != nil {
+		return entry
+	}
+
+	for path, number := range jsonfields.Numbers(value) {
+		entry.fields[path] = fieldRollup{Min: number, Max: number, Sum: number, Last: number, Count: 1}
+	}
+
+	return entry
+}
+
+// toHistoryMessage converts the entry to a HistoryMessage of the given tier.
+// Its value is the last message of the bucket with every numeric field replaced by its average.
+func (entry rollupEntry) toHistoryMessage(tier tier) HistoryMessage {
+	message := entry.message
+	message.Resolution = tier.label
+	message.Aggregates = map[string]Aggregate{}
+
+	for path, field := range entry.fields {
+		message.Aggregates[path] = Aggregate{
+			Min:   field.Min,
+			Max:   field.Max,
+			Avg:   field.Sum / float64(field.Count),
+			Last:  field.Last,
+			Count: field.Count,
+		}
+	}
+
+	value, err := jsonfields.Parse(message.Message.Value)
+	if err != nil {
+		return message
+	}
+
+	averaged, err := json.Marshal(jsonfields.Map(value, func(path string, leaf any) any {
+		if aggregate, ok := message.Aggregates[path]; ok {
+			return aggregate.Avg
+		}
+
+		return leaf
+	}))
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not encode rollup of target %s at %s! Reason: %s", message.Message.Target, message.Timestamp.UTC(), err))
+		return message
+	}
+
+	message.Message.Value = string(averaged)
+
+	return message
+}
+
+// bucketKey identifies a bucket of a tier.
+type bucketKey struct {
+	target string
+	start  int64
+}
+
+// querier is implemented by sql.DB and sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// GetTierHistory gets the history of target between from and until from the coarsest tier whose resolution doesn't
+// exceed maxResolution, or from a coarser one if the raw history of the range has already been purged.
+// A zero from reads the whole raw history.
+func (reader *Reader) GetTierHistory(target string, from time.Time, until time.Time, maxResolution time.Duration) (History, error) {
+	return reader.getTierHistory(target, selectTier(from, maxResolution, time.Now()), from, until)
+}
+
+// selectTier returns the index of the coarsest tier whose resolution doesn't exceed maxDensity, or of a coarser one
+// if the finer tiers don't reach back to from anymore.
+func selectTier(from time.Time, maxDensity time.Duration, now time.Time) int {
+	index := 0
+	for i := 1; i < len(tiers); i++ {
+		if maxDensity >= tiers[i].resolution {
+			index = i
+		}
+	}
+
+	if from.IsZero() {
+		return index
+	}
+
+	for index < len(tiers)-1 {
+		storageTime, err := config.ParseDuration(config.GetConfig().String(tiers[index].storageKey))
+		if err != nil || !from.Before(now.Add(-storageTime)) {
+			break
+		}
+
+		index++
+	}
+
+	return index
+}
+
+// getTierHistory gets the history of target between from and until from the tier with the given index.
+// The part of the range the tier hasn't been compacted for yet is read from the finer tiers.
+func (reader *Reader) getTierHistory(target string, index int, from time.Time, until time.Time) (History, error) {
+	if index == 0 {
+		return reader.GetHistoryEntriesFromUntil(target, from, until)
+	}
+
+	progress, ok, err := readProgress(reader.db, index)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return reader.getTierHistory(target, index-1, from, until)
+	}
+
+	rollupUntil := until
+	if progress.Before(until) {
+		rollupUntil = progress
+	}
+
+	entries, err := readTier(reader.db, index, target, from, rollupUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	history := History{}
+	for _, entry := range entries {
+		history = append(history, entry.toHistoryMessage(tiers[index]))
+	}
+
+	if !progress.Before(until) {
+		return history, nil
+	}
+
+	if progress.After(from) {
+		from = progress
+	}
+
+	recent, err := reader.getTierHistory(target, index-1, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(history, recent...), nil
+}
+
+// readTier reads the entries of the tier with the given index from "from" inclusively until "until" exclusively in
+// chronological order. An empty target reads the entries of all monitors.
+func readTier(q querier, index int, target string, from time.Time, until time.Time) ([]rollupEntry, error) {
+	if index == 0 {
+		query := `SELECT time, target, content, host, labels FROM history WHERE time >= ? AND time < ?`
+		args := []any{from.UTC(), until.UTC()}
+		if target != "" {
+			query += ` AND target = ?`
+			args = append(args, target)
+		}
+
+		rows, err := q.Query(query+` ORDER BY time;`, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		history, err := collectHistoryMessages(rows)
+		_ = rows.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		entries := make([]rollupEntry, len(history))
+		for i, message := range history {
+			entries[i] = rawEntry(message)
+		}
+
+		return entries, nil
+	}
+
+	query := `SELECT time, target, content, fields, host, labels FROM rollups WHERE resolution = ? AND time >= ? AND time < ?`
+	args := []any{tiers[index].seconds(), from.UTC(), until.UTC()}
+	if target != "" {
+		query += ` AND target = ?`
+		args = append(args, target)
+	}
+
+	rows, err := q.Query(query+` ORDER BY time;`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := collectRollups(rows)
+	_ = rows.Close()
+
+	return entries, err
+}
+
+// collectRollups constructs the entries of a rollup tier from database rows.
+func collectRollups(rows *sql.Rows) ([]rollupEntry, error) {
+	var entries []rollupEntry
+	for rows.Next() {
+		entry := rollupEntry{}
+		var fields, labels string
+		if err := rows.Scan(&entry.message.Timestamp, &entry.message.Message.Target, &entry.message.Message.Value, &fields, &entry.message.Host, &labels); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(fields), &entry.fields); err != nil {
+			logger.Error(fmt.Sprintf("Could not parse rollup fields of timestamp %s of target %s! Reason: %s", entry.message.Timestamp.UTC(), entry.message.Message.Target, err))
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(labels), &entry.message.Labels); err != nil {
+			logger.Error(fmt.Sprintf("Could not parse labels of timestamp %s of target %s! Reason: %s", entry.message.Timestamp.UTC(), entry.message.Message.Target, err))
+		}
+
+		content, err := decompress(entry.message.Message.Value)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Could not decompress rollup of timestamp %s of target %s! Reason: %s", entry.message.Timestamp.UTC(), entry.message.Message.Target, err))
+			continue
+		}
+
+		entry.message.Message.Value = content
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// readProgress returns the time up to which the tier with the given index has been compacted.
+// It returns false if the tier hasn't been compacted yet.
+func readProgress(q querier, index int) (time.Time, bool, error) {
+	var until time.Time
+	err := q.QueryRow(`SELECT until FROM rollup_progress WHERE resolution = ?;`, tiers[index].seconds()).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return until, true, nil
+}
+
+// readEarliest returns the time of the earliest entry of the tier with the given index.
+// It returns false if the tier is empty.
+func readEarliest(q querier, index int) (time.Time, bool, error) {
+	var row *sql.Row
+	if index == 0 {
+		row = q.QueryRow(`SELECT time FROM history ORDER BY time LIMIT 1;`)
+	} else {
+		row = q.QueryRow(`SELECT time FROM rollups WHERE resolution = ? ORDER BY time LIMIT 1;`, tiers[index].seconds())
+	}
+
+	var earliest time.Time
+	err := row.Scan(&earliest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return earliest, true, nil
+}
+
+// startCompactionCycle starts the recurring job of compacting the history into the rollup tiers.
+func startCompactionCycle(db *sql.DB, jobs *jobs) error {
+	compactionCycle, err := readCompactionCycle()
+	if err != nil {
+		return err
+	}
+
+	logger.Trace("Starting compaction cycle...")
+
+	jobs.start(func() {
+		for {
+			if err := compact(db, time.Now()); err != nil {
+				logger.Error("Could not compact history! Reason:", err.Error())
+			}
+
+			if !jobs.sleep(compactionCycle) {
+				return
+			}
+
+			// Re-read the compaction cycle so that configuration reloads take effect on the next cycle.
+			newCompactionCycle, err := readCompactionCycle()
+			if err != nil {
+				logger.Error(fmt.Sprintf("Could not parse compaction cycle from configuration. Keeping %s! Reason: %s", compactionCycle, err))
+				continue
+			}
+
+			compactionCycle = newCompactionCycle
+		}
+	})
+
+	return nil
+}
+
+// readCompactionCycle parses the compaction cycle from the configuration.
+func readCompactionCycle() (time.Duration, error) {
+	return time.ParseDuration(config.GetConfig().String("data.rollups.compaction_cycle"))
+}
+
+// compact compacts every rollup tier up to the last complete bucket before now.
+func compact(db *sql.DB, now time.Time) error {
+	for index := 1; index < len(tiers); index++ {
+		if err := compactTier(db, index, now); err != nil {
+			return fmt.Errorf("compacting %s rollups: %w", tiers[index].label, err)
+		}
+	}
+
+	return nil
+}
+
+// Recompact rolls up history written into the past from "from" on, if the storage keeps rollups.
+func Recompact(storage Storage, from time.Time) error {
+	rollupStorage, ok := storage.(RollupStorage)
+	if !ok {
+		return nil
+	}
+
+	return rollupStorage.Recompact(from)
+}
+
+// Recompact rewinds every rollup tier that has been compacted past from and compacts it again, so that history
+// written into the past, like an import, is rolled up as well. Buckets from "from" on are replaced by the ones
+// computed from the tier before, so from must not reach back into raw history that has been purged already.
+func (writer *Writer) Recompact(from time.Time) error {
+	for index := 1; index < len(tiers); index++ {
+		progress, ok, err := readProgress(writer.db, index)
+		if err != nil {
+			return err
+		}
+
+		rewound := from.Truncate(tiers[index].resolution)
+		if !ok || !progress.After(rewound) {
+			continue
+		}
+
+		if _, err := writer.db.Exec(`UPDATE rollup_progress SET until = ? WHERE resolution = ?;`, rewound.UTC(), tiers[index].seconds()); err != nil {
+			return err
+		}
+
+		logger.Debug(fmt.Sprintf("Rewound %s rollups to %s.", tiers[index].label, rewound.UTC()))
+	}
+
+	return compact(writer.db, time.Now())
+}
+
+// MergeRollups rolls up entries written into the past before the earliest entry of the raw history, like an import,
+// if the storage keeps rollups.
+func MergeRollups(storage Storage, entries []HistoryEntry) error {
+	rollupStorage, ok := storage.(RollupStorage)
+	if !ok || len(entries) == 0 {
+		return nil
+	}
+
+	return rollupStorage.MergeRollups(entries)
+}
+
+// MergeRollups merges entries into the buckets of every tier that has been compacted past them. Unlike Recompact, it
+// keeps what the buckets summarize already, so it rolls up entries older than the raw history, whose buckets can't be
+// computed again from the tier before. The entries need to be in the history already and mustn't have been rolled up.
+func (writer *Writer) MergeRollups(entries []HistoryEntry) error {
+	tx, err := writer.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for index := 1; index < len(tiers); index++ {
+		if err := mergeIntoTier(tx, index, entries); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("merging into %s rollups: %w", tiers[index].label, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mergeIntoTier merges the entries older than the progress of the tier with the given index into its buckets.
+// Existing buckets keep their message and are assumed to summarize later entries than the merged ones.
+func mergeIntoTier(tx *sql.Tx, index int, entries []HistoryEntry) error {
+	current := tiers[index]
+
+	progress, ok, err := readProgress(tx, index)
+	if err != nil || !ok {
+		return err
+	}
+
+	buckets := map[bucketKey]*rollupEntry{}
+	existed := map[bucketKey]bool{}
+	var order []bucketKey
+	merged := 0
+
+	for _, entry := range entries {
+		if !entry.Timestamp.Before(progress) {
+			continue
+		}
+
+		merged++
+
+		start := entry.Timestamp.Truncate(current.resolution)
+		key := bucketKey{target: entry.Target, start: start.UnixNano()}
+
+		bucket, ok := buckets[key]
+		if !ok {
+			existing, err := readTier(tx, index, entry.Target, start, start.Add(current.resolution))
+			if err != nil {
+				return err
+			}
+
+			bucket = &rollupEntry{fields: map[string]fieldRollup{}}
+			if len(existing) > 0 {
+				bucket = &existing[0]
+				existed[key] = true
+			}
+
+			buckets[key] = bucket
+			order = append(order, key)
+		}
+
+		message := HistoryMessage{Timestamp: start, Host: entry.Host, Labels: entry.Labels}
+		message.Message.Target = entry.Target
+		message.Message.Value = entry.Content
+
+		if !existed[key] {
+			bucket.message = message
+		}
+
+		for path, value := range rawEntry(message).fields {
+			field, ok := bucket.fields[path]
+			if ok && existed[key] {
+				value.merge(field)
+				field = value
+			} else {
+				field.merge(value)
+			}
+
+			bucket.fields[path] = field
+		}
+	}
+
+	if len(order) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO rollups (resolution, time, target, content, fields, host, labels) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range order {
+		if err := insertRollup(stmt, current, buckets[key]); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+
+	if err := stmt.Close(); err != nil {
+		logger.Error("Error on closing statement for merging rollups:", err)
+	}
+
+	logger.Debug(fmt.Sprintf("Merged %d entries into %d %s rollups.", merged, len(order), current.label))
+
+	return nil
+}
+
+// compactTier compacts the tier with the given index from the tier before it, continuing where it left off.
+func compactTier(db *sql.DB, index int, now time.Time) error {
+	current := tiers[index]
+	until := now.Add(-compactionDelay).Truncate(current.resolution)
+
+	// Rollup tiers can only be compacted as far as the tier they are compacted from.
+	if index > 1 {
+		sourceProgress, ok, err := readProgress(db, index-1)
+		if err != nil || !ok {
+			return err
+		}
+
+		if sourceProgress.Before(until) {
+			until = sourceProgress.Truncate(current.resolution)
+		}
+	}
+
+	progress, ok, err := readProgress(db, index)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		earliest, ok, err := readEarliest(db, index-1)
+		if err != nil || !ok {
+			return err
+		}
+
+		progress = earliest.Truncate(current.resolution)
+	}
+
+	for progress.Before(until) {
+		end := progress.Add(compactionChunk)
+		if end.After(until) {
+			end = until
+		}
+
+		if err := compactRange(db, index, progress, end); err != nil {
+			return err
+		}
+
+		progress = end
+	}
+
+	return nil
+}
+
+// compactRange compacts the entries of the tier before the one with the given index from "from" until "until" into
+// buckets and advances the tier's progress within a single transaction.
+func compactRange(db *sql.DB, index int, from time.Time, until time.Time) error {
+	current := tiers[index]
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	entries, err := readTier(tx, index-1, "", from, until)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	buckets := map[bucketKey]*rollupEntry{}
+	var order []bucketKey
+
+	for _, entry := range entries {
+		start := entry.message.Timestamp.Truncate(current.resolution)
+		key := bucketKey{target: entry.message.Message.Target, start: start.UnixNano()}
+
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &rollupEntry{fields: map[string]fieldRollup{}}
+			buckets[key] = bucket
+			order = append(order, key)
+		}
+
+		// Entries are read in chronological order, so the bucket ends up with the last message as its template.
+		bucket.message = entry.message
+		bucket.message.Timestamp = start
+
+		for path, later := range entry.fields {
+			field := bucket.fields[path]
+			field.merge(later)
+			bucket.fields[path] = field
+		}
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO rollups (resolution, time, target, content, fields, host, labels) VALUES (?, ?, ?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, key := range order {
+		if err := insertRollup(stmt, current, buckets[key]); err != nil {
+			_ = stmt.Close()
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if err := stmt.Close(); err != nil {
+		logger.Error("Error on closing statement for compaction:", err)
+	}
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO rollup_progress (resolution, until) VALUES (?, ?);`, current.seconds(), until.UTC()); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Trace(fmt.Sprintf("Compacted %d entries into %d %s rollups until %s.", len(entries), len(order), current.label, until.UTC()))
+
+	return nil
+}
+
+// insertRollup executes the prepared insert statement stmt for a single bucket of the given tier.
+func insertRollup(stmt *sql.Stmt, tier tier, bucket *rollupEntry) error {
+	labels := bucket.message.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	encodedLabels, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	encodedFields, err := json.Marshal(bucket.fields)
+	if err != nil {
+		return err
+	}
+
+	compressedValue, err := compress(bucket.message.Message.Value)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(tier.seconds(), bucket.message.Timestamp.UTC(), bucket.message.Message.Target, compressedValue, string(encodedFields), bucket.message.Host, string(encodedLabels))
+
+	return err
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// insertRollupTestData writes raw entries spread over two minutes and two hours starting at base and compacts them.
+// The entry at 2h59m30s is too recent to be compacted.
+func insertRollupTestData(t *testing.T, base time.Time) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	entries := []HistoryEntry{
+		{Timestamp: base.Add(10 * time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 10}`},
+		{Timestamp: base.Add(20 * time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 20}`},
+		{Timestamp: base.Add(70 * time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 30}`},
+		{Timestamp: base.Add(time.Hour + 5*time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 40}`},
+		{Timestamp: base.Add(2*time.Hour + 59*time.Minute + 30*time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 50}`},
+		{Timestamp: base.Add(30 * time.Second), Target: "Memory.RAM", Content: `not json`},
+	}
+
+	require.NoError(t, GetWriter().AddHistoryEntries(entries))
+	require.NoError(t, compact(GetWriter().db, base.Add(3*time.Hour)))
+}
+
+func TestCompact(t *testing.T) {
+	base := time.Now().Add(-5 * time.Hour).Truncate(time.Hour).UTC()
+	insertRollupTestData(t, base)
+
+	db := GetWriter().db
+
+	progress, ok, err := readProgress(db, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, base.Add(2*time.Hour+59*time.Minute).Equal(progress))
+
+	progress, ok, err = readProgress(db, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, base.Add(2*time.Hour).Equal(progress))
+
+	minutes, err := readTier(db, 1, "CPU.Usage", base, base.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(minutes))
+	assert.True(t, base.Equal(minutes[0].message.Timestamp))
+	assert.Equal(t, fieldRollup{Min: 10, Max: 20, Sum: 30, Last: 20, Count: 2}, minutes[0].fields["usage"])
+	assert.True(t, base.Add(time.Minute).Equal(minutes[1].message.Timestamp))
+	assert.True(t, base.Add(time.Hour).Equal(minutes[2].message.Timestamp))
+
+	hours, err := readTier(db, 2, "CPU.Usage", base, base.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(hours))
+	assert.Equal(t, fieldRollup{Min: 10, Max: 30, Sum: 60, Last: 30, Count: 3}, hours[0].fields["usage"])
+	assert.Equal(t, fieldRollup{Min: 40, Max: 40, Sum: 40, Last: 40, Count: 1}, hours[1].fields["usage"])
+
+	// Messages without numeric fields keep their last message.
+	memory, err := readTier(db, 1, "Memory.RAM", base, base.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(memory))
+	assert.Equal(t, "not json", memory[0].message.Message.Value)
+	assert.Empty(t, memory[0].fields)
+
+	// Compacting again doesn't change anything as the tiers continue where they left off.
+	require.NoError(t, compact(db, base.Add(3*time.Hour)))
+
+	hours, err = readTier(db, 2, "CPU.Usage", base, base.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(hours))
+	assert.Equal(t, 3, hours[0].fields["usage"].Count)
+}
+
+func TestGetHistory(t *testing.T) {
+	base := time.Now().Add(-5 * time.Hour).Truncate(time.Hour).UTC()
+	insertRollupTestData(t, base)
+
+	until := base.Add(3 * time.Hour)
+
+	raw, err := GetHistory(GetStorage(), "CPU.Usage", base, until, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 5, len(raw))
+	assert.Empty(t, raw[0].Resolution)
+
+	minutes, err := GetHistory(GetStorage(), "CPU.Usage", base, until, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(minutes))
+	assert.Equal(t, "1m", minutes[0].Resolution)
+	assert.JSONEq(t, `{"name": "Some CPU", "usage": 15}`, minutes[0].Message.Value)
+	assert.Equal(t, Aggregate{Min: 10, Max: 20, Avg: 15, Last: 20, Count: 2}, minutes[0].Aggregates["usage"])
+
+	// The range after the progress of the minute tier is read from the raw history.
+	assert.Empty(t, minutes[3].Resolution)
+	assert.JSONEq(t, `{"name": "Some CPU", "usage": 50}`, minutes[3].Message.Value)
+
+	hours, err := GetHistory(GetStorage(), "CPU.Usage", base, until, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(hours))
+	assert.Equal(t, "1h", hours[0].Resolution)
+	assert.Equal(t, Aggregate{Min: 10, Max: 30, Avg: 20, Last: 30, Count: 3}, hours[0].Aggregates["usage"])
+	assert.Equal(t, "1h", hours[1].Resolution)
+	assert.Empty(t, hours[2].Resolution)
+}
+
+func TestSelectTier(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, 0, selectTier(time.Time{}, 0, now))
+	assert.Equal(t, 0, selectTier(now.Add(-time.Hour), 30*time.Second, now))
+	assert.Equal(t, 1, selectTier(now.Add(-time.Hour), 5*time.Minute, now))
+	assert.Equal(t, 2, selectTier(now.Add(-time.Hour), 2*time.Hour, now))
+
+	// The raw history and the minute rollups are kept for 720h in the tests.
+	assert.Equal(t, 2, selectTier(now.Add(-1000*time.Hour), 0, now))
+	assert.Equal(t, 2, selectTier(now.Add(-1000*time.Hour), time.Minute, now))
+}
+
+func TestWriter_Recompact(t *testing.T) {
+	base := time.Now().Add(-5 * time.Hour).Truncate(time.Hour).UTC()
+	insertRollupTestData(t, base)
+
+	// Entries written behind the progress of the tiers are only rolled up after recompacting.
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(30 * time.Second), Target: "CPU.Usage", Content: `{"name": "Some CPU", "usage": 60}`},
+	}))
+
+	require.NoError(t, GetWriter().Recompact(base.Add(30*time.Second)))
+
+	db := GetWriter().db
+
+	minutes, err := readTier(db, 1, "CPU.Usage", base, base.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(minutes))
+	assert.Equal(t, fieldRollup{Min: 10, Max: 60, Sum: 90, Last: 60, Count: 3}, minutes[0].fields["usage"])
+
+	hours, err := readTier(db, 2, "CPU.Usage", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(hours))
+	assert.Equal(t, 4, hours[0].fields["usage"].Count)
+
+	// The progress is caught up again.
+	progress, ok, err := readProgress(db, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, progress.After(base.Add(4*time.Hour)))
+}
+
+func TestWriter_MergeRollups(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	restore, err := config.Override(map[string]interface{}{"data.storage_time": "48h"})
+	require.NoError(t, err)
+	t.Cleanup(restore)
+
+	db := GetWriter().db
+	now := time.Now()
+	base := now.Add(-10 * 24 * time.Hour).Truncate(time.Hour).UTC()
+
+	// The raw history of the bucket at base has been purged after it was rolled up, only recent raw history is left.
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(30 * time.Second), Target: "CPU.Usage", Content: `{"usage": 20}`},
+		{Timestamp: now.Add(-time.Hour), Target: "CPU.Usage", Content: `{"usage": 50}`},
+	}))
+	require.NoError(t, compact(db, now))
+	require.NoError(t, purgeOldEntries(db))
+
+	// Entries imported before the raw history left are merged into the buckets instead of replacing them.
+	older := []HistoryEntry{
+		{Timestamp: base, Target: "CPU.Usage", Content: `{"usage": 10}`},
+		{Timestamp: base.Add(10 * time.Second), Target: "CPU.Usage", Content: `{"usage": 30}`},
+	}
+
+	require.NoError(t, GetWriter().AddHistoryEntries(older))
+	require.NoError(t, GetWriter().MergeRollups(older))
+	require.NoError(t, purgeOldEntries(db))
+
+	history, err := GetReader().GetHistoryEntriesFromUntil("CPU.Usage", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	minutes, err := readTier(db, 1, "CPU.Usage", base, base.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(minutes))
+	assert.Equal(t, fieldRollup{Min: 10, Max: 30, Sum: 60, Last: 20, Count: 3}, minutes[0].fields["usage"])
+
+	hours, err := readTier(db, 2, "CPU.Usage", base, base.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(hours))
+	assert.Equal(t, fieldRollup{Min: 10, Max: 30, Sum: 60, Last: 20, Count: 3}, hours[0].fields["usage"])
+
+	// Entries past the progress of the tiers are left to the compaction.
+	recent := []HistoryEntry{{Timestamp: now, Target: "CPU.Usage", Content: `{"usage": 70}`}}
+	require.NoError(t, GetWriter().AddHistoryEntries(recent))
+	require.NoError(t, GetWriter().MergeRollups(recent))
+
+	minutes, err = readTier(db, 1, "CPU.Usage", now.Truncate(time.Minute), now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, minutes)
+}
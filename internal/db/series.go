@@ -0,0 +1,360 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"math"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaRegistry looks up the schemas of monitors, which declare the numeric fields stored as series.
+// It is implemented by pubsub.SchemaRegistry.
+type SchemaRegistry interface {
+	GetSchema(monitor string) (pubsub.Schema, bool)
+}
+
+var schemaRegistry SchemaRegistry
+var schemaRegistryLock sync.RWMutex
+
+// SetSchemaRegistry sets the registry the numeric fields of monitors are looked up in.
+// Without a registry, no new series are created and all field queries decode the history.
+func SetSchemaRegistry(registry SchemaRegistry) {
+	schemaRegistryLock.Lock()
+	defer schemaRegistryLock.Unlock()
+
+	schemaRegistry = registry
+}
+
+// declaredFields returns the numeric fields declared by the schema of a monitor.
+func declaredFields(target string) []string {
+	schemaRegistryLock.RLock()
+	defer schemaRegistryLock.RUnlock()
+
+	if schemaRegistry == nil {
+		return nil
+	}
+
+	schema, ok := schemaRegistry.GetSchema(target)
+	if !ok {
+		return nil
+	}
+
+	return schema.NumericFields
+}
+
+// covers reports whether every field matched by the field path field is matched by the declared field path as well.
+// Segments of field that are glob patterns need to equal the declared segment, so some coverage is missed, but none
+// is claimed that doesn't exist.
+func covers(declared string, field string) bool {
+	declaredSegments := strings.Split(declared, ".")
+	segments := strings.Split(field, ".")
+
+	if len(declaredSegments) != len(segments) {
+		return false
+	}
+
+	for i, segment := range segments {
+		if segment == declaredSegments[i] {
+			continue
+		}
+
+		if strings.ContainsAny(segment, `*?[\`) {
+			return false
+		}
+
+		if matched, err := path.Match(declaredSegments[i], segment); err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// loadSeries reads the ids of all series keyed by their monitor and field.
+func loadSeries(db *sql.DB) (map[string]map[string]int64, error) {
+	rows, err := db.Query(`SELECT id, target, field FROM series;`)
+	if err != nil {
+		return nil, err
+	}
+
+	series := map[string]map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var target, field string
+		if err := rows.Scan(&id, &target, &field); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		if series[target] == nil {
+			series[target] = map[string]int64{}
+		}
+
+		series[target][field] = id
+	}
+
+	return series, rows.Close()
+}
+
+// lookupSeries returns the id of the series of a field, looking at the series created by the current transaction
+// first.
+func (writer *Writer) lookupSeries(target string, field string, created map[string]map[string]int64) (int64, bool) {
+	if id, ok := created[target][field]; ok {
+		return id, true
+	}
+
+	writer.seriesLock.Lock()
+	defer writer.seriesLock.Unlock()
+
+	id, ok := writer.series[target][field]
+
+	return id, ok
+}
+
+// hasSeries reports whether any series of a monitor exists.
+func (writer *Writer) hasSeries(target string, created map[string]map[string]int64) bool {
+	if len(created[target]) > 0 {
+		return true
+	}
+
+	writer.seriesLock.Lock()
+	defer writer.seriesLock.Unlock()
+
+	return len(writer.series[target]) > 0
+}
+
+// insertSeriesValues inserts the numeric fields of an entry that are declared by the schema of its monitor or
+// already have a series, so that values written without a schema registry, i.e. by imports, aren't missing.
+// Series created within the transaction are added to created. It needs to be called before the entry is inserted
+// into the history.
+func (writer *Writer) insertSeriesValues(tx *sql.Tx, stmt *sql.Stmt, entry HistoryEntry, created map[string]map[string]int64) error {
+	declared := declaredFields(entry.Target)
+	if len(declared) == 0 && !writer.hasSeries(entry.Target, created) {
+		return nil
+	}
+
+	value, err := jsonfields.Parse(entry.Content)
+	if err != nil {
+		// Bodies that aren't JSON have no fields.
+		return nil
+	}
+
+	for field, number := range jsonfields.Numbers(value) {
+		id, ok := writer.lookupSeries(entry.Target, field, created)
+		if !ok {
+			if !matchesAny(declared, field) {
+				continue
+			}
+
+			if id, err = createSeries(tx, entry, field); err != nil {
+				return err
+			}
+
+			if created[entry.Target] == nil {
+				created[entry.Target] = map[string]int64{}
+			}
+
+			created[entry.Target][field] = id
+		}
+
+		if _, err := stmt.Exec(id, entry.Timestamp.UnixNano(), number); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether a field matches at least one of the field paths.
+func matchesAny(fieldPaths []string, field string) bool {
+	if field == "" {
+		return false
+	}
+
+	for _, fieldPath := range fieldPaths {
+		if jsonfields.MatchPath(fieldPath, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createSeries creates the series of a field and returns its id.
+// The series holds the values of all entries of the monitor from its since time on. The history written before
+// doesn't have values in the series, so since is set past the newest entry of the monitor if the entry is older.
+func createSeries(tx *sql.Tx, entry HistoryEntry, field string) (int64, error) {
+	since := entry.Timestamp.UTC()
+
+	var newest time.Time
+	err := tx.QueryRow(`SELECT time FROM history WHERE target = ? ORDER BY time DESC LIMIT 1;`, entry.Target).Scan(&newest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if err == nil && !newest.Before(since) {
+		since = newest.Add(time.Nanosecond).UTC()
+	}
+
+	// The series may have been created by another connection since the writer loaded the series.
+	_, err = tx.Exec(`INSERT INTO series (target, field, since) VALUES (?, ?, ?) ON CONFLICT (target, field) DO NOTHING;`, entry.Target, field, since)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM series WHERE target = ? AND field = ?;`, entry.Target, field).Scan(&id)
+
+	return id, err
+}
+
+// coveringSeries returns the ids of the series holding all values of the fields of target matching fieldPath from
+// "from" on. It returns nil if the values need to be decoded from the history instead, as fieldPath isn't covered by
+// a declared numeric field or not all of its series reach back to from.
+func (reader *Reader) coveringSeries(target string, fieldPath string, from time.Time) ([]int64, error) {
+	if from.IsZero() {
+		return nil, nil
+	}
+
+	covered := false
+	for _, declared := range declaredFields(target) {
+		if covers(declared, fieldPath) {
+			covered = true
+			break
+		}
+	}
+
+	if !covered {
+		return nil, nil
+	}
+
+	rows, err := reader.db.Query(`SELECT id, field, since FROM series WHERE target = ?;`, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int64
+	reachesBack := true
+
+	for rows.Next() {
+		var id int64
+		var field string
+		var since time.Time
+		if err := rows.Scan(&id, &field, &since); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		if !jsonfields.MatchPath(fieldPath, field) {
+			continue
+		}
+
+		reachesBack = reachesBack && !from.Before(since)
+		ids = append(ids, id)
+	}
+
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if !reachesBack {
+		return nil, nil
+	}
+
+	return ids, nil
+}
+
+// readSeries reads the samples of the field at fieldPath of target between from and until from its series. It returns
+// false if the series don't cover the field over the whole range, e.g. as their values before from have been purged.
+// Series are kept longer than the raw history, so they are checked before the query falls back to the rollups.
+func (reader *Reader) readSeries(target string, fieldPath string, from time.Time, until time.Time) ([]sample, bool, error) {
+	ids, err := reader.coveringSeries(target, fieldPath, from)
+	if err != nil || len(ids) == 0 {
+		return nil, false, err
+	}
+
+	samples, err := reader.readSeriesSamples(ids, from, until)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return samples, true, nil
+}
+
+// readSeriesSamples reads the values of the series between from and until and combines the values of the same time
+// into a sample, as they stem from the same history entry.
+func (reader *Reader) readSeriesSamples(ids []int64, from time.Time, until time.Time) ([]sample, error) {
+	args := make([]any, 0, len(ids)+2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	args = append(args, from.UnixNano(), until.UnixNano())
+
+	rows, err := reader.db.Query(fmt.Sprintf(`
+		SELECT time, value FROM series_values WHERE series_id IN (%s) AND time >= ? AND time <= ? ORDER BY time;
+	`, strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []sample
+	var timestamp time.Time
+	var values []float64
+
+	for rows.Next() {
+		var nanoseconds int64
+		var value float64
+		if err := rows.Scan(&nanoseconds, &value); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		current := time.Unix(0, nanoseconds).UTC()
+
+		if len(values) > 0 && !current.Equal(timestamp) {
+			samples = append(samples, newValueSample(timestamp, values))
+			values = nil
+		}
+
+		timestamp = current
+		values = append(values, value)
+	}
+
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if len(values) > 0 {
+		samples = append(samples, newValueSample(timestamp, values))
+	}
+
+	return samples, nil
+}
+
+// newValueSample creates the sample of the single values of the fields of a raw history entry.
+func newValueSample(timestamp time.Time, values []float64) sample {
+	result := sample{
+		timestamp: timestamp,
+		observed:  timestamp,
+		min:       math.Inf(1),
+		max:       math.Inf(-1),
+		count:     len(values),
+		values:    values,
+	}
+
+	for _, value := range values {
+		result.min = math.Min(result.min, value)
+		result.max = math.Max(result.max, value)
+		result.sum += value
+		result.total += value
+	}
+
+	return result
+}
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// schemaMap is a SchemaRegistry declaring the numeric fields of monitors.
+type schemaMap map[string][]string
+
+func (schemas schemaMap) GetSchema(monitor string) (pubsub.Schema, bool) {
+	fields, ok := schemas[monitor]
+
+	return pubsub.Schema{Monitor: monitor, NumericFields: fields}, ok
+}
+
+// openSeriesStorage opens an empty sqlite storage and declares the numeric fields of the given schemas.
+func openSeriesStorage(tb testing.TB, schemas schemaMap) *sqliteStorage {
+	// The logger is set up on initialization.
+	require.NoError(tb, OpenDatabase())
+
+	storage, err := openSQLite(filepath.Join(tb.TempDir(), "series.db"))
+	require.NoError(tb, err)
+
+	SetSchemaRegistry(schemas)
+
+	tb.Cleanup(func() {
+		SetSchemaRegistry(nil)
+		_ = storage.Close()
+	})
+
+	return storage
+}
+
+// usageContent returns the body of a CPU.Usage message of the given number of cores.
+func usageContent(cores int, usage int) string {
+	fields := make([]string, cores)
+	for i := range fields {
+		fields[i] = fmt.Sprintf(`"cpu%d": {"usage": %d, "name": "Core %d"}`, i, usage+i, i)
+	}
+
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// readSeriesValues returns all values of the series formatted as field=value in order of field and time.
+func readSeriesValues(t *testing.T, storage *sqliteStorage) []string {
+	values, err := queryStrings(storage.Writer.db, `
+		SELECT s.field || '=' || CAST(v.value AS INTEGER) FROM series s JOIN series_values v ON v.series_id = s.id ORDER BY s.field, v.time;
+	`)
+	require.NoError(t, err)
+
+	return values
+}
+
+func TestCovers(t *testing.T) {
+	for _, test := range []struct {
+		declared string
+		field    string
+		expected bool
+	}{
+		{"*.usage", "*.usage", true},
+		{"*.usage", "cpu0.usage", true},
+		{"cpu*.usage", "cpu0.usage", true},
+		{"cpu*.usage", "*.usage", false},
+		{"*.usage", "cpu0", false},
+		{"*.usage", "cpu0.idle", false},
+		{"mem_available", "mem_available", true},
+		{"mem_available", "mem_*", false},
+	} {
+		t.Run(test.declared+" "+test.field, func(t *testing.T) {
+			assert.Equal(t, test.expected, covers(test.declared, test.field))
+		})
+	}
+}
+
+func TestWriter_SeriesValues(t *testing.T) {
+	storage := openSeriesStorage(t, schemaMap{"CPU.Usage": {"*.usage"}})
+	now := time.Now()
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: usageContent(2, 10)},
+		{Timestamp: now.Add(time.Second), Target: "CPU.Usage", Content: usageContent(2, 20)},
+		{Timestamp: now, Target: "Memory.RAM", Content: `{"used": 1024}`},
+		{Timestamp: now.Add(2 * time.Second), Target: "CPU.Usage", Content: "not JSON"},
+	}))
+
+	assert.Equal(t, []string{"cpu0.usage=10", "cpu0.usage=20", "cpu1.usage=11", "cpu1.usage=21"}, readSeriesValues(t, storage))
+
+	// Values of existing series are written without declarations as well, i.e. on import.
+	SetSchemaRegistry(nil)
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(3 * time.Second), Target: "CPU.Usage", Content: usageContent(3, 30)},
+	}))
+
+	assert.Equal(t, []string{
+		"cpu0.usage=10", "cpu0.usage=20", "cpu0.usage=30",
+		"cpu1.usage=11", "cpu1.usage=21", "cpu1.usage=31",
+	}, readSeriesValues(t, storage))
+
+	// The series are known to writers opened later on.
+	reopened, err := newWriter(storage.Writer.db)
+	require.NoError(t, err)
+	defer func() { _ = reopened.close() }()
+
+	assert.Equal(t, 2, len(reopened.series["CPU.Usage"]))
+}
+
+func TestReader_AggregateSeries(t *testing.T) {
+	storage := openSeriesStorage(t, schemaMap{"CPU.Usage": {"*.usage"}})
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	var entries []HistoryEntry
+	for i := 0; i < 90; i++ {
+		entries = append(entries, HistoryEntry{
+			Timestamp: base.Add(time.Duration(i) * 20 * time.Second),
+			Target:    "CPU.Usage",
+			Content:   usageContent(2, i%13),
+		})
+	}
+
+	require.NoError(t, storage.AddHistoryEntries(entries))
+
+	for _, field := range []string{"*.usage", "cpu1.usage"} {
+		t.Run(field, func(t *testing.T) {
+			SetSchemaRegistry(schemaMap{"CPU.Usage": {"*.usage"}})
+
+			query := AggregateQuery{
+				Target:      "CPU.Usage",
+				Field:       field,
+				From:        base,
+				Until:       base.Add(30*time.Minute - time.Second),
+				Bucket:      10 * time.Minute,
+				Percentiles: []float64{50, 90},
+				Rate:        true,
+			}
+
+			series, err := AggregateHistory(storage, query)
+			require.NoError(t, err)
+			require.Equal(t, 3, len(series))
+
+			// Without declarations, the history is decoded instead.
+			SetSchemaRegistry(nil)
+
+			history, err := AggregateHistory(storage, query)
+			require.NoError(t, err)
+			assert.Equal(t, history, series)
+		})
+	}
+
+	// The series are read without touching the history.
+	SetSchemaRegistry(schemaMap{"CPU.Usage": {"*.usage"}})
+
+	_, err := storage.Writer.db.Exec(`DELETE FROM history;`)
+	require.NoError(t, err)
+
+	buckets, err := AggregateHistory(storage, AggregateQuery{Target: "CPU.Usage", Field: "cpu0.usage", From: base, Until: base.Add(time.Hour), Bucket: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(buckets))
+	assert.Equal(t, 90, buckets[0].Count)
+}
+
+func TestReader_AggregateSeriesSince(t *testing.T) {
+	storage := openSeriesStorage(t, nil)
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+
+	// History written before the fields were declared has no values in the series.
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base, Target: "CPU.Usage", Content: usageContent(1, 10)},
+		{Timestamp: base.Add(20 * time.Minute), Target: "CPU.Usage", Content: usageContent(1, 20)},
+	}))
+
+	SetSchemaRegistry(schemaMap{"CPU.Usage": {"*.usage"}})
+
+	// Entries older than the history written before don't move the series back.
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(10 * time.Minute), Target: "CPU.Usage", Content: usageContent(1, 30)},
+		{Timestamp: base.Add(30 * time.Minute), Target: "CPU.Usage", Content: usageContent(1, 40)},
+	}))
+
+	var since time.Time
+	require.NoError(t, storage.Writer.db.QueryRow(`SELECT since FROM series WHERE field = 'cpu0.usage';`).Scan(&since))
+	assert.True(t, since.After(base.Add(20*time.Minute)))
+
+	ids, err := storage.coveringSeries("CPU.Usage", "cpu0.usage", base)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	ids, err = storage.coveringSeries("CPU.Usage", "cpu0.usage", base.Add(25*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(ids))
+
+	ids, err = storage.coveringSeries("CPU.Usage", "cpu0.name", base.Add(25*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	buckets, err := AggregateHistory(storage, AggregateQuery{Target: "CPU.Usage", Field: "cpu0.usage", From: base, Until: base.Add(time.Hour), Bucket: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(buckets))
+	assert.Equal(t, 4, buckets[0].Count)
+}
+
+func TestPurgeSeries(t *testing.T) {
+	storage := openSeriesStorage(t, schemaMap{"CPU.Usage": {"*.usage"}})
+	now := time.Now()
+
+	restore, err := config.Override(map[string]interface{}{"data.storage_time": "24h"})
+	require.NoError(t, err)
+	t.Cleanup(restore)
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now.Add(-48 * time.Hour), Target: "CPU.Usage", Content: usageContent(1, 10)},
+		{Timestamp: now.Add(-time.Hour), Target: "CPU.Usage", Content: usageContent(1, 20)},
+	}))
+
+	db := storage.Writer.db
+
+	require.NoError(t, compact(db, now))
+	require.NoError(t, purgeTier(db, 0, nil, now))
+	require.NoError(t, purgeSeries(db, nil, now))
+
+	// Series are kept as long as the minute rollups, so they answer queries the raw history doesn't reach back to.
+	assert.Equal(t, []string{"cpu0.usage=10", "cpu0.usage=20"}, readSeriesValues(t, storage))
+
+	samples, found, err := storage.readSeries("CPU.Usage", "cpu0.usage", now.Add(-48*time.Hour), now)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, 2, len(samples))
+
+	// Retention policies shorten the storage time of the series like the one of the rollups.
+	require.NoError(t, purgeSeries(db, []RetentionPolicy{{Monitor: "CPU.*", StorageTime: 2 * time.Hour}}, now))
+	assert.Equal(t, []string{"cpu0.usage=20"}, readSeriesValues(t, storage))
+
+	// Queries reaching back past the purged values aren't answered from the series anymore.
+	_, found, err = storage.readSeries("CPU.Usage", "cpu0.usage", now.Add(-48*time.Hour), now)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, found, err = storage.readSeries("CPU.Usage", "cpu0.usage", now.Add(-90*time.Minute), now)
+	require.NoError(t, err)
+	assert.True(t, found)
+}
+
+func TestSeriesStorageTimeOf(t *testing.T) {
+	policies := []RetentionPolicy{
+		{Monitor: "CPU.CpuInfo", StorageTime: 24 * time.Hour},
+		{Monitor: "Checks.#", StorageTime: 365 * 24 * time.Hour},
+	}
+
+	day := 24 * time.Hour
+
+	assert.Equal(t, 30*day, seriesStorageTimeOf("Memory.RAM", 2*day, 30*day, policies))
+	assert.Equal(t, 60*day, seriesStorageTimeOf("Memory.RAM", 60*day, 30*day, policies))
+	assert.Equal(t, day, seriesStorageTimeOf("CPU.CpuInfo", 2*day, 30*day, policies))
+	assert.Equal(t, 365*day, seriesStorageTimeOf("Checks.HTTP.Example", 2*day, 30*day, policies))
+}
+
+// BenchmarkAggregateHistory compares aggregating a field over a month of history of eight cores written every minute
+// from the rollups with reading the series.
+func BenchmarkAggregateHistory(b *testing.B) {
+	schemas := schemaMap{"CPU.Usage": {"*.usage"}}
+	storage := openSeriesStorage(b, schemas)
+
+	// The shipped defaults keep the raw history for 48h and the minute rollups for 720h, so without series the month
+	// is read from the minute rollups.
+	restore, err := config.Override(map[string]interface{}{
+		"data.storage_time":                "48h",
+		"data.rollups.minute_storage_time": "720h",
+	})
+	require.NoError(b, err)
+	b.Cleanup(restore)
+
+	now := time.Now()
+	start := now.Add(-720 * time.Hour).Add(time.Hour)
+
+	var batch []HistoryEntry
+	for timestamp := start; timestamp.Before(now); timestamp = timestamp.Add(time.Minute) {
+		batch = append(batch, HistoryEntry{Timestamp: timestamp, Target: "CPU.Usage", Content: usageContent(8, timestamp.Minute())})
+
+		if len(batch) == 1000 {
+			require.NoError(b, storage.AddHistoryEntries(batch))
+			batch = batch[:0]
+		}
+	}
+
+	require.NoError(b, storage.AddHistoryEntries(batch))
+	require.NoError(b, compact(storage.Writer.db, now))
+
+	for _, source := range []struct {
+		name    string
+		schemas SchemaRegistry
+	}{
+		{"Rollups", nil},
+		{"Series", schemas},
+	} {
+		for _, field := range []string{"cpu0.usage", "*.usage"} {
+			b.Run(fmt.Sprintf("%s/%s", source.name, field), func(b *testing.B) {
+				SetSchemaRegistry(source.schemas)
+
+				query := AggregateQuery{Target: "CPU.Usage", Field: field, From: start, Until: now, Bucket: time.Hour}
+
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					if _, err := AggregateHistory(storage, query); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
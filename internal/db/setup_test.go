@@ -2,6 +2,7 @@ package db
 
 import (
 	"errors"
+	"fmt"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/knadh/koanf/providers/confmap"
@@ -11,11 +12,20 @@ import (
 
 func TestMain(m *testing.M) {
 	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
-		"logging.log_level":  "TRACE",
-		"logging.method":     "CONSOLE",
-		"data.storage_time":  "720h",
-		"data.purge_cycle":   "1h",
-		"data.database_file": "history_test.db",
+		"logging.log_level":                "TRACE",
+		"logging.method":                   "CONSOLE",
+		"data.storage_time":                "720h",
+		"data.purge_cycle":                 "1h",
+		"data.rollups.minute_storage_time": "720h",
+		"data.rollups.hour_storage_time":   "8760h",
+		"data.rollups.compaction_cycle":    "1h",
+		"data.events_storage_time":         "720h",
+		"data.max_database_size":           "0",
+		"data.backup.cycle":                "0",
+		"data.backup.keep":                 1,
+		"data.database_file":               "history_test.db",
+		"main.hostname":                    "test-host",
+		"main.labels":                      map[string]string{"env": "test"},
 	}, "."), nil)
 	if err != nil {
 		panic(err)
@@ -27,18 +37,28 @@ func TestMain(m *testing.M) {
 
 	code := m.Run()
 
-	if _, err := os.Stat("history_test.db"); !errors.Is(err, os.ErrNotExist) {
-		if err := os.Remove("history_test.db"); err != nil {
+	if writer != nil {
+		if err := Close(); err != nil {
 			panic(err)
 		}
 	}
 
+	for _, file := range []string{"history_test.db", "history_test.db-wal", "history_test.db-shm"} {
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			if err := os.Remove(file); err != nil {
+				panic(err)
+			}
+		}
+	}
+
 	os.Exit(code)
 }
 
 func clearDatabase() error {
-	if _, err := GetWriter().db.Exec("DELETE FROM history WHERE true"); err != nil {
-		return err
+	for _, table := range []string{"history", "series_values", "events", "rollups", "rollup_progress"} {
+		if _, err := GetWriter().db.Exec(fmt.Sprintf("DELETE FROM %s WHERE true", table)); err != nil {
+			return err
+		}
 	}
 
 	return nil
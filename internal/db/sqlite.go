@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// sqliteStorage is the default Storage. It keeps the history, its rollups and the events in a sqlite database file.
+type sqliteStorage struct {
+	*Reader
+	*Writer
+	// jobs are the recurring jobs on the database. It is nil if they haven't been started.
+	jobs *jobs
+}
+
+var _ Storage = (*sqliteStorage)(nil)
+var _ RollupStorage = (*sqliteStorage)(nil)
+var _ BackupStorage = (*sqliteStorage)(nil)
+var _ SizedStorage = (*sqliteStorage)(nil)
+var _ CountingStorage = (*sqliteStorage)(nil)
+var _ seriesStorage = (*sqliteStorage)(nil)
+
+// openSQLite opens the database file and migrates its layout.
+func openSQLite(file string) (*sqliteStorage, error) {
+	logger.Trace("Opening database connection!")
+
+	db, err := sql.Open("sqlite3", dataSourceName(file))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Trace("Migrating database layout...")
+	if err := migrate(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	writer, err := newWriter(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &sqliteStorage{Reader: &Reader{db}, Writer: writer}, nil
+}
+
+// openSQLiteFromConfig opens the database file configured in data.database_file. If startJobs is set, the database is
+// vacuumed and the purge, compaction and backup cycles are started.
+func openSQLiteFromConfig(startJobs bool) (Storage, error) {
+	opened, err := openSQLite(config.GetConfig().String("data.database_file"))
+	if err != nil {
+		return nil, err
+	}
+
+	if startJobs {
+		if err := startSQLiteJobs(opened); err != nil {
+			_ = opened.Close()
+			return nil, err
+		}
+	}
+
+	reader = opened.Reader
+	writer = opened.Writer
+
+	return opened, nil
+}
+
+// startSQLiteJobs vacuums the database, catches up on compaction and starts the purge, compaction and backup cycles.
+func startSQLiteJobs(opened *sqliteStorage) error {
+	db := opened.Writer.db
+
+	if err := vacuumDB(db); err != nil {
+		return err
+	}
+
+	// Fail on startup instead of on the first purge if the retention configuration is invalid.
+	if _, err := readRetentionPolicies(); err != nil {
+		return err
+	}
+
+	if _, err := readMaxDatabaseSize(); err != nil {
+		return err
+	}
+
+	// Roll up the history written since the last compaction, i.e. before an upgrade or while stopped, so that the
+	// first purge doesn't have to keep it.
+	logger.Debug("Catching up on compaction...")
+	if err := compact(db, time.Now()); err != nil {
+		return err
+	}
+
+	opened.jobs = newJobs()
+
+	if err := startCompactionCycle(db, opened.jobs); err != nil {
+		return err
+	}
+
+	if err := startPurgeCycle(db, opened.jobs); err != nil {
+		return err
+	}
+
+	return startBackupCycle(opened, opened.jobs)
+}
+
+// GetSize returns the size of the database file in bytes.
+func (storage *sqliteStorage) GetSize() (int64, error) {
+	var pageCount, pageSize int64
+
+	if err := storage.Writer.db.QueryRow(`PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return 0, err
+	}
+
+	if err := storage.Writer.db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+
+	return pageCount * pageSize, nil
+}
+
+// Backup writes a snapshot of the database to file with VACUUM INTO. It reads the database within a single read
+// transaction, so the snapshot is consistent while the writer keeps committing, unlike a copy of the file.
+// The snapshot is written next to file and only renamed to it once it is complete.
+func (storage *sqliteStorage) Backup(file string) error {
+	temporary := file + ".tmp"
+	if err := os.Remove(temporary); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if _, err := storage.Writer.db.Exec(`VACUUM INTO ?`, temporary); err != nil {
+		_ = os.Remove(temporary)
+		return err
+	}
+
+	return os.Rename(temporary, file)
+}
+
+// Close stops the recurring jobs, writes back the write-ahead log and closes the database connection.
+func (storage *sqliteStorage) Close() error {
+	logger.Debug("Closing database...")
+
+	if storage.jobs != nil {
+		storage.jobs.shutdown()
+	}
+
+	if err := storage.Writer.close(); err != nil {
+		return err
+	}
+
+	return storage.Writer.db.Close()
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"sync"
+	"time"
+)
+
+var ErrUnknownBackend = errors.New("unknown storage backend")
+var ErrBackendNotPersistent = errors.New("storage backend is not persistent")
+
+// Storage keeps the history of the monitors and the event stream. The backend is selected by data.backend.
+// Queries like aggregations are package functions on top of it. Capabilities not every backend has, like rollups or
+// backups, are separate interfaces the package functions check for.
+type Storage interface {
+	// AddHistoryEntries adds multiple entries to the history. Either all entries are written or none of them.
+	AddHistoryEntries(entries []HistoryEntry) error
+	// GetHistoryEntriesFromUntil gets the raw history of target between from and until in chronological order.
+	GetHistoryEntriesFromUntil(target string, from time.Time, until time.Time) (History, error)
+	// GetLatestHistoryEntries gets the n newest entries of the raw history of target up to until in chronological
+	// order, without reading older ones.
+	GetLatestHistoryEntries(target string, until time.Time, n int) (History, error)
+	// GetTargets returns the targets matching at least one of the patterns in alphabetical order, all without patterns.
+	GetTargets(patterns []string) ([]string, error)
+	// WalkHistory calls fn for every entry of the targets matching the patterns between from and until in
+	// chronological order until fn returns an error.
+	WalkHistory(patterns []string, from time.Time, until time.Time, fn func(message HistoryMessage) error) error
+	// GetEarliest returns the time of the earliest entry of the history. It returns false if the history is empty.
+	GetEarliest() (time.Time, bool, error)
+
+	// AddEvent adds an event to the event stream.
+	AddEvent(event events.Event) error
+	// GetEvents gets all events between "from" and "until" ordered by time.
+	GetEvents(from time.Time, until time.Time) ([]events.Event, error)
+
+	// Close closes the storage. Pending messages of the recorder need to be flushed before.
+	Close() error
+}
+
+// RollupStorage is implemented by storages keeping rollups of the history in tiers of coarser resolution.
+type RollupStorage interface {
+	// GetTierHistory gets the history of target between from and until from the coarsest tier whose resolution doesn't
+	// exceed maxResolution, or from a coarser one if the finer tiers don't reach back to from anymore.
+	// A zero from reads the whole history of the tier.
+	GetTierHistory(target string, from time.Time, until time.Time, maxResolution time.Duration) (History, error)
+	// Recompact rolls up history written into the past from "from" on.
+	Recompact(from time.Time) error
+	// MergeRollups rolls up entries written into the past before the earliest entry of the raw history.
+	MergeRollups(entries []HistoryEntry) error
+}
+
+// BackupStorage is implemented by storages that can write a consistent snapshot of themselves to a file while they
+// keep being written to.
+type BackupStorage interface {
+	Backup(file string) error
+}
+
+// SizedStorage is implemented by storages that can tell the number of bytes they take up.
+type SizedStorage interface {
+	GetSize() (int64, error)
+}
+
+// CountingStorage is implemented by storages counting the history entries written since they have been opened.
+type CountingStorage interface {
+	GetRowsWritten() uint64
+}
+
+// Backend is a storage backend selectable by data.backend.
+type Backend struct {
+	// Open opens the storage from the configuration. Recurring jobs like purges are only started if startJobs is set.
+	Open func(startJobs bool) (Storage, error)
+	// Persistent backends keep their data beyond the process, so commands like export and import can use them.
+	Persistent bool
+}
+
+var backends = map[string]Backend{
+	"sqlite": {Open: openSQLiteFromConfig, Persistent: true},
+	"memory": {Open: openMemoryFromConfig},
+}
+
+var backendsLock sync.Mutex
+
+// singleton variable
+var storage Storage
+
+// RegisterBackend makes a storage backend selectable by data.backend. It needs to be called before the database is
+// initialized and replaces any backend registered under the same name.
+func RegisterBackend(name string, backend Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	backends[name] = backend
+}
+
+// lookupBackend returns the storage backend registered under name.
+func lookupBackend(name string) (Backend, bool) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	backend, ok := backends[name]
+
+	return backend, ok
+}
+
+// GetStorage returns the storage opened by InitDatabase or OpenDatabase.
+func GetStorage() Storage {
+	return storage
+}
@@ -0,0 +1,344 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// conformanceTests are the tests every Storage needs to pass. Each test gets an empty storage.
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, storage Storage)
+}{
+	{"History", testStorageHistory},
+	{"Identity", testStorageIdentity},
+	{"Targets", testStorageTargets},
+	{"Walk", testStorageWalk},
+	{"Earliest", testStorageEarliest},
+	{"Aggregate", testStorageAggregate},
+	{"Downsampled", testStorageDownsampled},
+	{"Events", testStorageEvents},
+	{"Statistics", testStorageStatistics},
+}
+
+// runConformanceTests runs the conformance tests against the storages created by newStorage.
+func runConformanceTests(t *testing.T, newStorage func(t *testing.T) Storage) {
+	for _, conformanceTest := range conformanceTests {
+		test := conformanceTest.test
+		t.Run(conformanceTest.name, func(t *testing.T) {
+			test(t, newStorage(t))
+		})
+	}
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	// The logger is set up on initialization.
+	require.NoError(t, OpenDatabase())
+
+	runConformanceTests(t, func(t *testing.T) Storage {
+		storage, err := openSQLite(filepath.Join(t.TempDir(), "storage.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = storage.Close() })
+
+		return storage
+	})
+}
+
+func TestOpenSQLiteFromConfigFailure(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	previous := GetWriter()
+
+	restore, err := config.Override(map[string]interface{}{
+		"data.database_file":     filepath.Join(t.TempDir(), "failure.db"),
+		"data.max_database_size": "a lot",
+	})
+	require.NoError(t, err)
+	t.Cleanup(restore)
+
+	_, err = openSQLiteFromConfig(true)
+	require.Error(t, err)
+
+	// The storage that failed to start is closed and doesn't replace the open one.
+	assert.Same(t, previous, GetWriter())
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{{Timestamp: time.Now(), Target: "CPU.Usage", Content: "{}"}}))
+}
+
+func TestMemoryStorage(t *testing.T) {
+	runConformanceTests(t, func(t *testing.T) Storage {
+		return newMemoryStorage(1000)
+	})
+}
+
+func TestRegisterBackend(t *testing.T) {
+	RegisterBackend("test", Backend{Open: func(bool) (Storage, error) { return newMemoryStorage(1), nil }})
+
+	backend, ok := lookupBackend("test")
+	require.True(t, ok)
+
+	opened, err := backend.Open(false)
+	require.NoError(t, err)
+	assert.NotNil(t, opened)
+
+	_, ok = lookupBackend("unknown")
+	assert.False(t, ok)
+}
+
+// conformanceZone is a zone west of UTC the conformance tests take their times in, so that a storage comparing
+// times in another zone than they are stored in fails them.
+var conformanceZone = time.FixedZone("UTC-5", -5*60*60)
+
+// zonedNow returns the current time in the conformance zone.
+func zonedNow() time.Time {
+	return time.Now().In(conformanceZone)
+}
+
+// values returns the values of the history entries.
+func values(history History) []string {
+	result := make([]string, len(history))
+	for i, entry := range history {
+		result[i] = entry.Message.Value
+	}
+
+	return result
+}
+
+func testStorageHistory(t *testing.T, storage Storage) {
+	base := zonedNow().Add(-time.Hour).Truncate(time.Second)
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(2 * time.Second), Target: "CPU.Usage", Content: "Third"},
+		{Timestamp: base, Target: "CPU.Usage", Content: "First"},
+		{Timestamp: base.Add(time.Second), Target: "CPU.Usage", Content: "Second"},
+		{Timestamp: base.Add(time.Second), Target: "Memory.RAM", Content: "Other"},
+	}))
+
+	// Messages of a monitor with the same timestamp are both kept.
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(10 * time.Second), Target: "CPU.Usage", Content: "Fourth"},
+		{Timestamp: base.Add(10 * time.Second), Target: "CPU.Usage", Content: "Fourth"},
+	}))
+
+	history, err := storage.GetHistoryEntriesFromUntil("CPU.Usage", base, base.Add(2*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"First", "Second", "Third"}, values(history))
+	assert.True(t, base.Equal(history[0].Timestamp))
+
+	history, err = GetHistory(storage, "CPU.Usage", time.Time{}, zonedNow(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"First", "Second", "Third", "Fourth", "Fourth"}, values(history))
+
+	history, err = GetHistory(storage, "CPU.Usage", base, zonedNow(), 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"First", "Third", "Fourth"}, values(history))
+
+	history, err = storage.GetLatestHistoryEntries("CPU.Usage", base.Add(2*time.Second), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Second", "Third"}, values(history))
+
+	history, err = storage.GetLatestHistoryEntries("CPU.Usage", zonedNow(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"First", "Second", "Third", "Fourth", "Fourth"}, values(history))
+
+	history, err = GetHistory(storage, "Unknown.Target", base, zonedNow(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func testStorageIdentity(t *testing.T, storage Storage) {
+	now := zonedNow()
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: "{}", Host: "some-host", Labels: map[string]string{"env": "prod"}},
+		{Timestamp: now.Add(time.Second), Target: "CPU.Usage", Content: "{}"},
+	}))
+
+	history, err := GetHistory(storage, "CPU.Usage", now.Add(-time.Minute), now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(history))
+
+	assert.Equal(t, "some-host", history[0].Host)
+	assert.Equal(t, map[string]string{"env": "prod"}, history[0].Labels)
+	assert.Empty(t, history[1].Host)
+	assert.Equal(t, map[string]string{}, history[1].Labels)
+}
+
+func testStorageTargets(t *testing.T, storage Storage) {
+	now := zonedNow()
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "Memory.RAM", Content: "{}"},
+		{Timestamp: now, Target: "CPU.Usage", Content: "{}"},
+		{Timestamp: now, Target: "CPU.Clock", Content: "{}"},
+	}))
+
+	targets, err := storage.GetTargets(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CPU.Clock", "CPU.Usage", "Memory.RAM"}, targets)
+
+	targets, err = storage.GetTargets([]string{"CPU.*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CPU.Clock", "CPU.Usage"}, targets)
+
+	_, err = storage.GetTargets([]string{"#.Usage"})
+	assert.Error(t, err)
+}
+
+func testStorageWalk(t *testing.T, storage Storage) {
+	base := zonedNow().Add(-time.Hour).Truncate(time.Second)
+
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(2 * time.Second), Target: "CPU.Usage", Content: "Third"},
+		{Timestamp: base, Target: "CPU.Usage", Content: "First"},
+		{Timestamp: base.Add(time.Second), Target: "CPU.Clock", Content: "Second"},
+		{Timestamp: base.Add(time.Second), Target: "Memory.RAM", Content: "Other"},
+		{Timestamp: base.Add(time.Hour), Target: "CPU.Usage", Content: "Later"},
+	}))
+
+	var walked []string
+	require.NoError(t, storage.WalkHistory([]string{"CPU.*"}, base, base.Add(time.Minute), func(message HistoryMessage) error {
+		walked = append(walked, message.Message.Value)
+		return nil
+	}))
+	assert.Equal(t, []string{"First", "Second", "Third"}, walked)
+
+	stop := errors.New("stop")
+	walked = nil
+	err := storage.WalkHistory(nil, base, base.Add(time.Minute), func(message HistoryMessage) error {
+		walked = append(walked, message.Message.Value)
+		return stop
+	})
+	assert.True(t, errors.Is(err, stop))
+	assert.Equal(t, []string{"First"}, walked)
+}
+
+func testStorageEarliest(t *testing.T, storage Storage) {
+	_, ok, err := storage.GetEarliest()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	base := zonedNow().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(time.Minute), Target: "CPU.Usage", Content: "{}"},
+		{Timestamp: base, Target: "Memory.RAM", Content: "{}"},
+	}))
+
+	earliest, ok, err := storage.GetEarliest()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, base.Equal(earliest))
+
+	assert.NoError(t, Recompact(storage, base))
+}
+
+func testStorageAggregate(t *testing.T, storage Storage) {
+	base := zonedNow().Add(-time.Hour).Truncate(time.Minute)
+
+	var entries []HistoryEntry
+	for i, load := range []int{10, 20, 30, 40, 50} {
+		entries = append(entries, HistoryEntry{
+			Timestamp: base.Add(time.Duration(i) * 20 * time.Second),
+			Target:    "CPU.Usage",
+			Content:   fmt.Sprintf(`{"load": %d}`, load),
+		})
+	}
+
+	require.NoError(t, storage.AddHistoryEntries(entries))
+
+	buckets, err := AggregateHistory(storage, AggregateQuery{
+		Target:      "CPU.Usage",
+		Field:       "load",
+		From:        base,
+		Until:       base.Add(2 * time.Minute),
+		Bucket:      time.Minute,
+		Percentiles: []float64{50},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(buckets))
+	assert.Equal(t, 3, buckets[0].Count)
+	assert.Equal(t, 20.0, buckets[0].Avg)
+	assert.Equal(t, 20.0, buckets[0].Percentiles["50"])
+	assert.Equal(t, 45.0, buckets[1].Avg)
+
+	_, err = AggregateHistory(storage, AggregateQuery{Target: "CPU.Usage", From: base, Until: base.Add(time.Minute)})
+	assert.True(t, errors.Is(err, ErrInvalidAggregateQuery))
+}
+
+func testStorageDownsampled(t *testing.T, storage Storage) {
+	start := zonedNow().Add(-time.Hour).Truncate(time.Second)
+
+	var entries []HistoryEntry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, HistoryEntry{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Target:    "CPU.Usage",
+			Content:   fmt.Sprintf(`{"usage": %d}`, i%7),
+		})
+	}
+
+	require.NoError(t, storage.AddHistoryEntries(entries))
+
+	history, err := GetHistoryDownsampled(storage, "CPU.Usage", start, start.Add(99*time.Second), "usage", 10)
+	require.NoError(t, err)
+	assert.Equal(t, 10, len(history))
+
+	_, err = GetHistoryDownsampled(storage, "CPU.Usage", start, start.Add(time.Minute), "usage", 2)
+	assert.True(t, errors.Is(err, ErrInvalidMaxPoints))
+}
+
+func testStorageEvents(t *testing.T, storage Storage) {
+	base := zonedNow().Add(-time.Hour).Truncate(time.Second)
+
+	for i, message := range []string{"Second", "First", "Third"} {
+		offset := time.Duration(i) * time.Second
+		if message == "First" {
+			offset = -time.Second
+		}
+
+		require.NoError(t, storage.AddEvent(events.Event{
+			Timestamp: base.Add(offset),
+			Type:      events.TypeStarted,
+			Severity:  events.SeverityInfo,
+			Source:    "Excubitor",
+			Message:   message,
+			Host:      "some-host",
+		}))
+	}
+
+	result, err := storage.GetEvents(base.Add(-time.Minute), base.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(result))
+	assert.Equal(t, "First", result[0].Message)
+	assert.Equal(t, "Second", result[1].Message)
+	assert.Equal(t, "some-host", result[1].Host)
+	assert.Equal(t, map[string]string{}, result[1].Attributes)
+	assert.True(t, base.Equal(result[1].Timestamp))
+}
+
+func testStorageStatistics(t *testing.T, storage Storage) {
+	counting, ok := storage.(CountingStorage)
+	require.True(t, ok)
+
+	sized, ok := storage.(SizedStorage)
+	require.True(t, ok)
+
+	assert.Equal(t, uint64(0), counting.GetRowsWritten())
+
+	now := zonedNow()
+	require.NoError(t, storage.AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: `{"usage": 10}`},
+		{Timestamp: now.Add(time.Second), Target: "CPU.Usage", Content: `{"usage": 20}`},
+	}))
+
+	assert.Equal(t, uint64(2), counting.GetRowsWritten())
+
+	size, err := sized.GetSize()
+	require.NoError(t, err)
+	assert.Greater(t, size, int64(0))
+}
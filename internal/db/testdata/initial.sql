@@ -0,0 +1,12 @@
+-- Layout of the first releases: the history table only, without identity columns.
+CREATE TABLE history (
+		time DATETIME NOT NULL,
+		target TEXT NOT NULL,
+		content TEXT NOT NULL,
+		PRIMARY KEY (time, target)
+);
+
+INSERT INTO history (time, target, content) VALUES
+	('2023-01-01 12:00:00+00:00', 'CPU.Usage', X'789CAB562A2D4E4C4F55B2523034A805001EAB040D'),
+	('2023-01-01 12:00:01+00:00', 'CPU.Usage', X'789CAB562A2D4E4C4F55B2523032A805001EAE040E'),
+	('2023-01-01 12:00:00+00:00', 'Memory.RAM', X'789CAB562AC92F49CC51B252303433B630A905002BFB04C1');
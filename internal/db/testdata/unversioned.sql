@@ -0,0 +1,46 @@
+-- Layout of the last release before migrations: identity columns added to an existing history, events and rollups,
+-- but no schema_migrations table.
+CREATE TABLE history (
+		time DATETIME NOT NULL,
+		target TEXT NOT NULL,
+		content TEXT NOT NULL,
+		PRIMARY KEY (time, target)
+);
+ALTER TABLE history ADD COLUMN host TEXT NOT NULL DEFAULT '';
+ALTER TABLE history ADD COLUMN labels TEXT NOT NULL DEFAULT '{}';
+
+CREATE TABLE events (
+		time DATETIME NOT NULL,
+		type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		source TEXT NOT NULL,
+		message TEXT NOT NULL,
+		attributes TEXT NOT NULL DEFAULT '{}',
+		host TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX events_time ON events (time);
+
+CREATE TABLE rollups (
+		resolution INTEGER NOT NULL,
+		time DATETIME NOT NULL,
+		target TEXT NOT NULL,
+		content TEXT NOT NULL,
+		fields TEXT NOT NULL DEFAULT '{}',
+		host TEXT NOT NULL DEFAULT '',
+		labels TEXT NOT NULL DEFAULT '{}',
+		PRIMARY KEY (resolution, target, time)
+);
+CREATE TABLE rollup_progress (
+		resolution INTEGER PRIMARY KEY,
+		until DATETIME NOT NULL
+);
+
+INSERT INTO history (time, target, content, host, labels) VALUES
+	('2023-01-01 12:00:00+00:00', 'CPU.Usage', X'789CAB562A2D4E4C4F55B2523034A805001EAB040D', 'some-host', '{"env":"prod"}'),
+	('2023-01-01 12:00:01+00:00', 'CPU.Usage', X'789CAB562A2D4E4C4F55B2523032A805001EAE040E', 'some-host', '{"env":"prod"}'),
+	('2023-01-01 12:00:00+00:00', 'Memory.RAM', X'789CAB562AC92F49CC51B252303433B630A905002BFB04C1', 'some-host', '{"env":"prod"}');
+
+INSERT INTO events (time, type, severity, source, message, host) VALUES
+	('2023-01-01 12:00:00+00:00', 'lifecycle', 'INFO', 'main', 'Started', 'some-host');
+
+INSERT INTO rollup_progress (resolution, until) VALUES (60, '2023-01-01 12:00:00+00:00');
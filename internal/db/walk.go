@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"strings"
+	"time"
+)
+
+// GetTargets returns the targets in the history matching at least one of the given patterns in alphabetical order.
+// Without patterns, all targets are returned.
+func (reader *Reader) GetTargets(patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if err := pubsub.ValidatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	targets, err := queryStrings(reader.db, `SELECT DISTINCT target FROM history ORDER BY target;`)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		return targets, nil
+	}
+
+	var matching []string
+	for _, target := range targets {
+		for _, pattern := range patterns {
+			if pubsub.MatchTopic(pattern, target) {
+				matching = append(matching, target)
+				break
+			}
+		}
+	}
+
+	return matching, nil
+}
+
+// WalkHistory calls fn for every history entry of the targets matching the given patterns between from and until in
+// chronological order. Entries are read one at a time, so the history doesn't need to fit into memory.
+// Walking stops at the first error returned by fn.
+func (reader *Reader) WalkHistory(patterns []string, from time.Time, until time.Time, fn func(message HistoryMessage) error) error {
+	targets, err := reader.GetTargets(patterns)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	args := []any{from.UTC(), until.UTC()}
+	for _, target := range targets {
+		args = append(args, target)
+	}
+
+	rows, err := reader.db.Query(fmt.Sprintf(`
+		SELECT time, target, content, host, labels FROM history WHERE time >= ? AND time <= ? AND target IN (%s) ORDER BY time;
+	`, strings.TrimSuffix(strings.Repeat("?, ", len(targets)), ", ")), args...)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		message, ok, err := scanHistoryMessage(rows)
+		if err != nil {
+			_ = rows.Close()
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := fn(message); err != nil {
+			_ = rows.Close()
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+
+	return rows.Close()
+}
+
+// GetEarliest returns the time of the earliest entry of the raw history. It returns false if the history is empty.
+func (reader *Reader) GetEarliest() (time.Time, bool, error) {
+	return readEarliest(reader.db, 0)
+}
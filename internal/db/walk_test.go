@@ -0,0 +1,63 @@
+package db
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestReader_GetTargets(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	now := time.Now()
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: now, Target: "CPU.Usage", Content: "{}"},
+		{Timestamp: now, Target: "CPU.Clock", Content: "{}"},
+		{Timestamp: now, Target: "Memory.RAM", Content: "{}"},
+	}))
+
+	targets, err := GetReader().GetTargets(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CPU.Clock", "CPU.Usage", "Memory.RAM"}, targets)
+
+	targets, err = GetReader().GetTargets([]string{"CPU.*", "Memory.Swap"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CPU.Clock", "CPU.Usage"}, targets)
+
+	_, err = GetReader().GetTargets([]string{"#.Usage"})
+	assert.Error(t, err)
+}
+
+func TestReader_WalkHistory(t *testing.T) {
+	require.NoError(t, OpenDatabase())
+	require.NoError(t, clearDatabase())
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{
+		{Timestamp: base.Add(2 * time.Second), Target: "CPU.Usage", Content: "Third"},
+		{Timestamp: base, Target: "CPU.Usage", Content: "First"},
+		{Timestamp: base.Add(time.Second), Target: "CPU.Clock", Content: "Second"},
+		{Timestamp: base.Add(time.Second), Target: "Memory.RAM", Content: "Other"},
+		{Timestamp: base.Add(time.Hour), Target: "CPU.Usage", Content: "Later"},
+	}))
+
+	var values []string
+	require.NoError(t, GetReader().WalkHistory([]string{"CPU.*"}, base, base.Add(time.Minute), func(message HistoryMessage) error {
+		values = append(values, message.Message.Value)
+		return nil
+	}))
+	assert.Equal(t, []string{"First", "Second", "Third"}, values)
+
+	// Walking stops at the first error.
+	stop := errors.New("stop")
+	values = nil
+	err := GetReader().WalkHistory(nil, base, base.Add(time.Minute), func(message HistoryMessage) error {
+		values = append(values, message.Message.Value)
+		return stop
+	})
+	assert.True(t, errors.Is(err, stop))
+	assert.Equal(t, []string{"First"}, values)
+}
@@ -2,39 +2,174 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Writer struct {
-	db *sql.DB
+	db          *sql.DB
+	insert      *sql.Stmt
+	insertValue *sql.Stmt
+	rowsWritten atomic.Uint64
+	// series holds the ids of the numeric series keyed by their monitor and field.
+	series     map[string]map[string]int64
+	seriesLock sync.Mutex
+}
+
+// newWriter constructs a Writer and prepares the statements it reuses for every batch.
+func newWriter(db *sql.DB) (*Writer, error) {
+	insert, err := db.Prepare(`
+		INSERT INTO history (time, target, content, host, labels) VALUES (?, ?, ?, ?, ?);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	insertValue, err := db.Prepare(`
+		INSERT INTO series_values (series_id, time, value) VALUES (?, ?, ?);
+	`)
+	if err != nil {
+		_ = insert.Close()
+		return nil, err
+	}
+
+	series, err := loadSeries(db)
+	if err != nil {
+		_ = insert.Close()
+		_ = insertValue.Close()
+		return nil, err
+	}
+
+	return &Writer{db: db, insert: insert, insertValue: insertValue, series: series}, nil
+}
+
+// close closes the prepared statements of the writer.
+func (writer *Writer) close() error {
+	if err := writer.insertValue.Close(); err != nil {
+		return err
+	}
+
+	return writer.insert.Close()
 }
 
 func GetWriter() *Writer {
 	return writer
 }
 
+// GetRowsWritten returns the number of history entries written since the database was initialized.
+func (writer *Writer) GetRowsWritten() uint64 {
+	return writer.rowsWritten.Load()
+}
+
+// HistoryEntry describes a single entry to be written to the history table.
+type HistoryEntry struct {
+	Timestamp time.Time
+	Target    string
+	Content   string
+	Host      string
+	Labels    map[string]string
+}
+
 // AddHistoryEntry adds an entry to the history table.
+// The entry is stamped with the current time and the hostname and labels of this Excubitor instance.
+// Every call commits a transaction of its own, so messages published on the broker are written by the recorder,
+// which collects them to batches for AddHistoryEntries.
 func (writer *Writer) AddHistoryEntry(target string, content string) error {
-	stmt, err := writer.db.Prepare(`
-		INSERT INTO history (time, target, content) VALUES (?, ?, ?);
-	`)
-	if err != nil {
-		return err
+	host := identity.Get()
+
+	return writer.AddHistoryEntries([]HistoryEntry{
+		{
+			Timestamp: time.Now(),
+			Target:    target,
+			Content:   content,
+			Host:      host.Hostname,
+			Labels:    host.Labels,
+		},
+	})
+}
+
+// AddHistoryEntries adds multiple entries to the history table within a single transaction.
+// Either all entries are written or none of them. Numeric fields declared by the schemas of their monitors are
+// written to their series as well.
+func (writer *Writer) AddHistoryEntries(entries []HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
 	}
 
-	compressedValue, err := compress(content)
+	tx, err := writer.db.Begin()
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(time.Now().UTC(), target, compressedValue)
-	if err != nil {
-		return err
+	stmt := tx.Stmt(writer.insert)
+	valueStmt := tx.Stmt(writer.insertValue)
+	created := map[string]map[string]int64{}
+
+	for _, entry := range entries {
+		err := writer.insertSeriesValues(tx, valueStmt, entry, created)
+		if err == nil {
+			err = insertHistoryEntry(stmt, entry)
+		}
+
+		if err != nil {
+			_ = stmt.Close()
+			_ = valueStmt.Close()
+			_ = tx.Rollback()
+			return err
+		}
 	}
 
 	if err := stmt.Close(); err != nil {
 		logger.Error("Error on closing statement for writer:", err)
 	}
 
+	if err := valueStmt.Close(); err != nil {
+		logger.Error("Error on closing statement for writer:", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	writer.rowsWritten.Add(uint64(len(entries)))
+
+	// Series created within a rolled back transaction don't exist, so they are only remembered after the commit.
+	writer.seriesLock.Lock()
+	for target, fields := range created {
+		if writer.series[target] == nil {
+			writer.series[target] = map[string]int64{}
+		}
+
+		for field, id := range fields {
+			writer.series[target][field] = id
+		}
+	}
+	writer.seriesLock.Unlock()
+
 	return nil
 }
+
+// insertHistoryEntry executes the prepared insert statement stmt for a single entry.
+func insertHistoryEntry(stmt *sql.Stmt, entry HistoryEntry) error {
+	labels := entry.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	encodedLabels, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	compressedValue, err := compress(entry.Content)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(entry.Timestamp.UTC(), entry.Target, compressedValue, entry.Host, string(encodedLabels))
+
+	return err
+}
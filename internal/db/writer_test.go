@@ -3,10 +3,38 @@ package db
 import (
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+func TestWriter_AddHistoryEntryIdentity(t *testing.T) {
+	if err := OpenDatabase(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := clearDatabase(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := GetWriter().AddHistoryEntry("SomeTarget", "SomeContent"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	history, err := GetReader().GetHistoryEntries("SomeTarget")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, "test-host", history[0].Host)
+	assert.Equal(t, map[string]string{"env": "test"}, history[0].Labels)
+}
+
 func TestWriter_AddHistoryEntry(t *testing.T) {
-	if err := InitDatabase(); err != nil {
+	if err := OpenDatabase(); err != nil {
 		t.Error(err)
 		return
 	}
@@ -64,3 +92,107 @@ func TestWriter_AddHistoryEntry(t *testing.T) {
 
 	assert.Equal(t, 1, count)
 }
+
+func TestWriter_AddHistoryEntries(t *testing.T) {
+	if err := OpenDatabase(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := clearDatabase(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	timestamp := time.Now()
+	entries := []HistoryEntry{
+		{Timestamp: timestamp, Target: "SomeTarget", Content: "First"},
+		{Timestamp: timestamp.Add(time.Second), Target: "SomeTarget", Content: "Second"},
+	}
+
+	if !assert.NoError(t, GetWriter().AddHistoryEntries(entries)) {
+		return
+	}
+
+	// Messages of a monitor with the same timestamp are both kept.
+	if !assert.NoError(t, GetWriter().AddHistoryEntries([]HistoryEntry{{Timestamp: timestamp, Target: "SomeTarget", Content: "Duplicate"}})) {
+		return
+	}
+
+	// The second entry is rejected by a trigger, so the whole batch is rolled back.
+	if _, err := GetWriter().db.Exec(`
+		CREATE TRIGGER reject_target BEFORE INSERT ON history WHEN NEW.target = 'RejectedTarget'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`); err != nil {
+		t.Error(err)
+		return
+	}
+
+	defer func() {
+		if _, err := GetWriter().db.Exec(`DROP TRIGGER reject_target;`); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	rejected := []HistoryEntry{
+		{Timestamp: timestamp.Add(2 * time.Second), Target: "SomeTarget", Content: "Third"},
+		{Timestamp: timestamp, Target: "RejectedTarget", Content: "Rejected"},
+	}
+
+	assert.Error(t, GetWriter().AddHistoryEntries(rejected))
+
+	history, err := GetReader().GetHistoryEntries("SomeTarget")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if assert.Equal(t, 3, len(history)) {
+		assert.ElementsMatch(t, []string{"First", "Duplicate"}, []string{history[0].Message.Value, history[1].Message.Value})
+		assert.Equal(t, "Second", history[2].Message.Value)
+	}
+}
+
+// benchmarkAddHistoryEntries writes b.N entries to the history in transactions of batchSize entries.
+func benchmarkAddHistoryEntries(b *testing.B, batchSize int) {
+	if err := OpenDatabase(); err != nil {
+		b.Fatal(err)
+	}
+
+	if err := clearDatabase(); err != nil {
+		b.Fatal(err)
+	}
+
+	start := time.Now()
+	content := `{"usage": 42.5, "cores": [12.5, 37.5, 50, 70]}`
+
+	b.ResetTimer()
+
+	for written := 0; written < b.N; written += batchSize {
+		batch := make([]HistoryEntry, 0, batchSize)
+		for i := written; i < written+batchSize && i < b.N; i++ {
+			batch = append(batch, HistoryEntry{
+				Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+				Target:    "CPU.Usage",
+				Content:   content,
+				Host:      "test-host",
+			})
+		}
+
+		if err := GetWriter().AddHistoryEntries(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriter_AddHistoryEntries_Single(b *testing.B) {
+	benchmarkAddHistoryEntries(b, 1)
+}
+
+func BenchmarkWriter_AddHistoryEntries_Batch100(b *testing.B) {
+	benchmarkAddHistoryEntries(b, 100)
+}
+
+func BenchmarkWriter_AddHistoryEntries_Batch1000(b *testing.B) {
+	benchmarkAddHistoryEntries(b, 1000)
+}
@@ -0,0 +1,223 @@
+// Package derived implements virtual monitors whose values are computed from the messages of other monitors.
+package derived
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"strings"
+	"sync"
+)
+
+var logger logging.Logger
+
+var ErrInvalidDefinition = errors.New("invalid derived monitor definition")
+
+// Definition describes a derived monitor as configured in the derived section of the configuration.
+type Definition struct {
+	Name       string `koanf:"name"`
+	Expression string `koanf:"expression"`
+}
+
+// Value is the body published on derived monitors.
+type Value struct {
+	Value float64 `json:"value"`
+}
+
+type monitor struct {
+	name       string
+	expression *Expression
+}
+
+// Engine evaluates derived monitors whenever one of their inputs publishes a message.
+type Engine struct {
+	broker     pubsub.Broker
+	subscriber *pubsub.Subscriber
+	dependents map[string][]*monitor
+	latest     map[string]any
+	lock       sync.Mutex
+}
+
+// Start loads the derived monitors from the configuration and starts evaluating them on the context's broker.
+func Start() error {
+	logger = logging.GetLogger()
+
+	var definitions []Definition
+	if err := config.GetConfig().Unmarshal("derived", &definitions); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDefinition, err)
+	}
+
+	if len(definitions) == 0 {
+		return nil
+	}
+
+	engine, err := NewEngine(ctx.GetContext().GetBroker(), definitions)
+	if err != nil {
+		return err
+	}
+
+	engine.Start()
+
+	return nil
+}
+
+// NewEngine parses the given definitions and constructs an Engine publishing on the given broker.
+func NewEngine(broker pubsub.Broker, definitions []Definition) (*Engine, error) {
+	logger = logging.GetLogger()
+
+	engine := &Engine{
+		broker:     broker,
+		dependents: map[string][]*monitor{},
+		latest:     map[string]any{},
+	}
+
+	names := map[string]bool{}
+	inputs := map[string][]string{}
+
+	for _, definition := range definitions {
+		if definition.Name == "" {
+			return nil, fmt.Errorf("%w: name is not set", ErrInvalidDefinition)
+		}
+
+		if names[definition.Name] {
+			return nil, fmt.Errorf("%w: %s is defined more than once", ErrInvalidDefinition, definition.Name)
+		}
+
+		names[definition.Name] = true
+
+		expression, err := ParseExpression(definition.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidDefinition, definition.Name, err)
+		}
+
+		m := &monitor{definition.Name, expression}
+		inputs[definition.Name] = expression.Inputs()
+
+		for _, input := range expression.Inputs() {
+			if pubsub.IsPattern(input) || pubsub.ValidatePattern(input) != nil {
+				return nil, fmt.Errorf("%w: %s references invalid monitor %s", ErrInvalidDefinition, definition.Name, input)
+			}
+
+			if input == definition.Name {
+				return nil, fmt.Errorf("%w: %s references itself", ErrInvalidDefinition, definition.Name)
+			}
+
+			engine.dependents[input] = append(engine.dependents[input], m)
+		}
+	}
+
+	// Derived monitors referencing each other would trigger each other's evaluation forever.
+	acyclic := map[string]bool{}
+	for _, definition := range definitions {
+		if cycle := findCycle(definition.Name, inputs, nil, acyclic); cycle != nil {
+			return nil, fmt.Errorf("%w: %s reference each other", ErrInvalidDefinition, strings.Join(cycle, " -> "))
+		}
+	}
+
+	return engine, nil
+}
+
+// findCycle follows the inputs of the derived monitor name and returns the path of monitors leading back to a monitor
+// on the path, which is nil if there is no such cycle. Monitors found not to lead into a cycle are added to acyclic,
+// so that they are followed only once.
+func findCycle(name string, inputs map[string][]string, path []string, acyclic map[string]bool) []string {
+	if acyclic[name] {
+		return nil
+	}
+
+	for i, visited := range path {
+		if visited == name {
+			return append(path[i:], name)
+		}
+	}
+
+	path = append(path, name)
+
+	for _, input := range inputs[name] {
+		if cycle := findCycle(input, inputs, path, acyclic); cycle != nil {
+			return cycle
+		}
+	}
+
+	acyclic[name] = true
+
+	return nil
+}
+
+// Start registers the schemas of the derived monitors and subscribes to all of their inputs.
+func (engine *Engine) Start() {
+	registered := map[string]bool{}
+	for _, monitors := range engine.dependents {
+		for _, m := range monitors {
+			if registered[m.name] {
+				continue
+			}
+
+			registered[m.name] = true
+
+			err := engine.broker.RegisterSchema(pubsub.Schema{
+				Monitor: m.name,
+				Source:  "Derived",
+				Version: 1,
+				Schema:  pubsub.SchemaFromValue(Value{}),
+			})
+			if err != nil {
+				logger.Error(fmt.Sprintf("Could not register schema of derived monitor %s! Reason: %s", m.name, err))
+			}
+		}
+	}
+
+	engine.subscriber = engine.broker.AddSubscriber()
+
+	go engine.subscriber.Listen(engine.handle)
+
+	for input := range engine.dependents {
+		if err := engine.broker.Subscribe(engine.subscriber, input); err != nil {
+			logger.Error(fmt.Sprintf("Could not subscribe to input %s of derived monitors! Reason: %s", input, err))
+		}
+	}
+}
+
+// Stop stops evaluating the derived monitors.
+func (engine *Engine) Stop() {
+	engine.subscriber.Destruct()
+}
+
+// handle stores the message of an input and re-evaluates all derived monitors depending on it.
+func (engine *Engine) handle(message *pubsub.Message) {
+	value, err := jsonfields.Parse(message.GetMessageBody())
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Could not decode message of %s for derived monitors! Reason: %s", message.GetMonitor(), err))
+		return
+	}
+
+	engine.lock.Lock()
+	engine.latest[message.GetMonitor()] = value
+
+	results := map[string]float64{}
+	for _, m := range engine.dependents[message.GetMonitor()] {
+		result, err := m.expression.Evaluate(engine.latest)
+		if err != nil {
+			logger.Debug(fmt.Sprintf("Could not evaluate derived monitor %s! Reason: %s", m.name, err))
+			continue
+		}
+
+		results[m.name] = result
+	}
+	engine.lock.Unlock()
+
+	for name, result := range results {
+		jsonOutput, err := json.Marshal(Value{result})
+		if err != nil {
+			logger.Error(fmt.Sprintf("Couldn't encode value of derived monitor %s! Reason: %s", name, err))
+			continue
+		}
+
+		engine.broker.Publish(name, string(jsonOutput))
+	}
+}
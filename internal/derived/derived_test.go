@@ -0,0 +1,72 @@
+package derived
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEngine(t *testing.T) {
+	broker := pubsub.NewBroker()
+
+	engine, err := NewEngine(broker, []Definition{
+		{
+			Name:       "Derived.MemoryUsedPercent",
+			Expression: "(Memory.MemInfo:mem_total - Memory.MemInfo:mem_available) / Memory.MemInfo:mem_total * 100",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	engine.Start()
+	defer engine.Stop()
+
+	subscriber := broker.AddSubscriber()
+	defer subscriber.Destruct()
+
+	received := make(chan *pubsub.Message, 1)
+	go subscriber.Listen(func(message *pubsub.Message) {
+		received <- message
+	})
+
+	broker.Subscribe(subscriber, "Derived.MemoryUsedPercent")
+	broker.Publish("Memory.MemInfo", `{"mem_total": 1000, "mem_free": 200, "mem_available": 250}`)
+
+	select {
+	case message := <-received:
+		assert.Equal(t, "Derived.MemoryUsedPercent", message.GetMonitor())
+		assert.JSONEq(t, `{"value": 75}`, message.GetMessageBody())
+	case <-time.After(time.Second):
+		t.Fatal("Derived monitor wasn't published in time...")
+	}
+}
+
+func TestNewEngineNegative(t *testing.T) {
+	for description, definitions := range map[string][]Definition{
+		"Missing name":       {{Name: "", Expression: "1"}},
+		"Duplicate name":     {{Name: "Derived.A", Expression: "1"}, {Name: "Derived.A", Expression: "2"}},
+		"Invalid expression": {{Name: "Derived.A", Expression: "1 +"}},
+		"Self reference":     {{Name: "Derived.A", Expression: "Derived.A:value + 1"}},
+		"Indirect reference": {{Name: "Derived.A", Expression: "Derived.B:value + 1"}, {Name: "Derived.B", Expression: "Derived.C:value * 2"}, {Name: "Derived.C", Expression: "Derived.A:value - 1"}},
+		"Mutual reference":   {{Name: "Derived.A", Expression: "Derived.B:value + 1"}, {Name: "Derived.B", Expression: "Derived.A:value * 2"}},
+		"Wildcard monitor":   {{Name: "Derived.A", Expression: "{CPU.*:value} + 1"}},
+	} {
+		t.Run(description, func(t *testing.T) {
+			_, err := NewEngine(pubsub.NewBroker(), definitions)
+			assert.ErrorIs(t, err, ErrInvalidDefinition)
+		})
+	}
+}
+
+func TestNewEngineChain(t *testing.T) {
+	// Derived monitors may build on each other as long as they don't reference each other.
+	_, err := NewEngine(pubsub.NewBroker(), []Definition{
+		{Name: "Derived.A", Expression: "Derived.B:value + Derived.C:value"},
+		{Name: "Derived.B", Expression: "Derived.C:value * 2"},
+		{Name: "Derived.C", Expression: "Memory.RAM:used / 1024"},
+	})
+	assert.NoError(t, err)
+}
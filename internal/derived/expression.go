@@ -0,0 +1,457 @@
+package derived
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidExpression = errors.New("invalid expression")
+var ErrEvaluation = errors.New("could not evaluate expression")
+
+// Expression is a parsed arithmetic expression over fields of monitor messages.
+//
+// Fields are referenced as <monitor>:<field path>, i.e. "Memory.MemInfo:mem_total". The field path may contain glob
+// patterns as understood by the jsonfields package. As these collide with the arithmetic operators, such references
+// have to be enclosed in braces, i.e. "{CPU.Usage:cpu?*.usage}". References matching more than one value have to be
+// reduced with one of the functions avg, sum, min, max or count.
+type Expression struct {
+	source string
+	root   node
+	inputs []string
+}
+
+// Inputs returns the names of all monitors the expression references.
+func (expression *Expression) Inputs() []string {
+	return expression.inputs
+}
+
+// String returns the source of the expression.
+func (expression *Expression) String() string {
+	return expression.source
+}
+
+// Evaluate evaluates the expression with the given decoded message bodies keyed by monitor name.
+func (expression *Expression) Evaluate(inputs map[string]any) (float64, error) {
+	values, err := expression.root.eval(inputs)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := scalar(values, expression.source)
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("%w: result of %s is not a finite number", ErrEvaluation, expression.source)
+	}
+
+	return result, nil
+}
+
+// ParseExpression parses the source of an expression.
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, inputs: map[string]bool{}}
+
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.position != len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidExpression, p.tokens[p.position].text)
+	}
+
+	var inputs []string
+	for input := range p.inputs {
+		inputs = append(inputs, input)
+	}
+
+	sort.Strings(inputs)
+
+	return &Expression{source: source, root: root, inputs: inputs}, nil
+}
+
+// TOKENIZER
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	identifierToken
+	operatorToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.:", r)
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/(),", r):
+			tokens = append(tokens, token{operatorToken, string(r)})
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{numberToken, string(runes[start:i])})
+		case r == '{':
+			end := i + 1
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated reference", ErrInvalidExpression)
+			}
+
+			reference := strings.TrimSpace(string(runes[i+1 : end]))
+			if !strings.Contains(reference, ":") {
+				return nil, fmt.Errorf("%w: invalid reference %q", ErrInvalidExpression, reference)
+			}
+
+			tokens = append(tokens, token{identifierToken, reference})
+			i = end + 1
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && isIdentifierRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{identifierToken, string(runes[start:i])})
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidExpression, r)
+		}
+	}
+
+	return tokens, nil
+}
+
+// PARSER
+
+type parser struct {
+	tokens   []token
+	position int
+	inputs   map[string]bool
+}
+
+func (p *parser) peek() *token {
+	if p.position >= len(p.tokens) {
+		return nil
+	}
+
+	return &p.tokens[p.position]
+}
+
+func (p *parser) acceptOperator(operators string) (string, bool) {
+	next := p.peek()
+	if next == nil || next.kind != operatorToken || !strings.Contains(operators, next.text) {
+		return "", false
+	}
+
+	p.position++
+	return next.text, true
+}
+
+func (p *parser) expectOperator(operator string) error {
+	if _, ok := p.acceptOperator(operator); !ok {
+		return fmt.Errorf("%w: expected %q", ErrInvalidExpression, operator)
+	}
+
+	return nil
+}
+
+// parseSum parses additions and subtractions.
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator, ok := p.acceptOperator("+-")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryNode{operator, left, right}
+	}
+}
+
+// parseProduct parses multiplications and divisions.
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		operator, ok := p.acceptOperator("*/")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryNode{operator, left, right}
+	}
+}
+
+// parseUnary parses negations.
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.acceptOperator("-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return binaryNode{"-", numberNode(0), operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses numbers, references, function calls and parenthesized expressions.
+func (p *parser) parsePrimary() (node, error) {
+	next := p.peek()
+	if next == nil {
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidExpression)
+	}
+
+	switch next.kind {
+	case numberToken:
+		p.position++
+
+		number, err := strconv.ParseFloat(next.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q", ErrInvalidExpression, next.text)
+		}
+
+		return numberNode(number), nil
+	case identifierToken:
+		p.position++
+
+		if monitor, field, ok := strings.Cut(next.text, ":"); ok {
+			if monitor == "" || field == "" || strings.Contains(field, ":") {
+				return nil, fmt.Errorf("%w: invalid reference %q", ErrInvalidExpression, next.text)
+			}
+
+			p.inputs[monitor] = true
+			return referenceNode{monitor, field}, nil
+		}
+
+		return p.parseCall(next.text)
+	default:
+		if _, ok := p.acceptOperator("("); ok {
+			inner, err := p.parseSum()
+			if err != nil {
+				return nil, err
+			}
+
+			return inner, p.expectOperator(")")
+		}
+
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidExpression, next.text)
+	}
+}
+
+// parseCall parses the arguments of a function call.
+func (p *parser) parseCall(name string) (node, error) {
+	function, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function or reference without monitor %q", ErrInvalidExpression, name)
+	}
+
+	if err := p.expectOperator("("); err != nil {
+		return nil, err
+	}
+
+	var arguments []node
+	for {
+		argument, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = append(arguments, argument)
+
+		if _, ok := p.acceptOperator(","); !ok {
+			break
+		}
+	}
+
+	if err := p.expectOperator(")"); err != nil {
+		return nil, err
+	}
+
+	return callNode{name, function, arguments}, nil
+}
+
+// NODES
+
+type node interface {
+	eval(inputs map[string]any) ([]float64, error)
+}
+
+type numberNode float64
+
+func (n numberNode) eval(_ map[string]any) ([]float64, error) {
+	return []float64{float64(n)}, nil
+}
+
+type referenceNode struct {
+	monitor string
+	field   string
+}
+
+func (n referenceNode) eval(inputs map[string]any) ([]float64, error) {
+	input, ok := inputs[n.monitor]
+	if !ok {
+		return nil, fmt.Errorf("%w: no message received on %s yet", ErrEvaluation, n.monitor)
+	}
+
+	values := jsonfields.LookupNumbers(input, n.field)
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: %s:%s doesn't match any numeric field", ErrEvaluation, n.monitor, n.field)
+	}
+
+	return values, nil
+}
+
+type binaryNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n binaryNode) eval(inputs map[string]any) ([]float64, error) {
+	leftValues, err := n.left.eval(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	rightValues, err := n.right.eval(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := scalar(leftValues, "left operand of "+n.operator)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := scalar(rightValues, "right operand of "+n.operator)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "+":
+		return []float64{left + right}, nil
+	case "-":
+		return []float64{left - right}, nil
+	case "*":
+		return []float64{left * right}, nil
+	default:
+		return []float64{left / right}, nil
+	}
+}
+
+type callNode struct {
+	name      string
+	function  func(values []float64) float64
+	arguments []node
+}
+
+func (n callNode) eval(inputs map[string]any) ([]float64, error) {
+	var values []float64
+	for _, argument := range n.arguments {
+		argumentValues, err := argument.eval(inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, argumentValues...)
+	}
+
+	return []float64{n.function(values)}, nil
+}
+
+// scalar ensures that values contains exactly one value and returns it.
+func scalar(values []float64, description string) (float64, error) {
+	if len(values) != 1 {
+		return 0, fmt.Errorf("%w: %s matches %d values, reduce them with avg, sum, min, max or count", ErrEvaluation, description, len(values))
+	}
+
+	return values[0], nil
+}
+
+// FUNCTIONS
+
+var functions = map[string]func(values []float64) float64{
+	"avg": func(values []float64) float64 {
+		return sum(values) / float64(len(values))
+	},
+	"sum": sum,
+	"min": func(values []float64) float64 {
+		result := math.Inf(1)
+		for _, value := range values {
+			result = math.Min(result, value)
+		}
+
+		return result
+	},
+	"max": func(values []float64) float64 {
+		result := math.Inf(-1)
+		for _, value := range values {
+			result = math.Max(result, value)
+		}
+
+		return result
+	},
+	"count": func(values []float64) float64 {
+		return float64(len(values))
+	},
+}
+
+func sum(values []float64) float64 {
+	result := float64(0)
+	for _, value := range values {
+		result += value
+	}
+
+	return result
+}
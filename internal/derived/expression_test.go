@@ -0,0 +1,116 @@
+package derived
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExpression_Evaluate(t *testing.T) {
+	memInfo, err := jsonfields.Parse(`{"mem_total": 1000, "mem_free": 200, "mem_available": 250}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	usage, err := jsonfields.Parse(`{"cpu": {"usage": 50}, "cpu0": {"usage": 20}, "cpu1": {"usage": 40}}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	inputs := map[string]any{
+		"Memory.MemInfo": memInfo,
+		"CPU.Usage":      usage,
+	}
+
+	type testParameters struct {
+		expression string
+		expected   float64
+	}
+
+	for _, params := range []testParameters{
+		{"(Memory.MemInfo:mem_total - Memory.MemInfo:mem_available) / Memory.MemInfo:mem_total", 0.75},
+		{"(Memory.MemInfo:mem_total - Memory.MemInfo:mem_available)/Memory.MemInfo:mem_total*100", 75},
+		{"avg({CPU.Usage:cpu?*.usage})", 30},
+		{"max({CPU.Usage:cpu*.usage})", 50},
+		{"count({CPU.Usage:cpu*.usage})", 3},
+		{"sum({CPU.Usage:cpu?*.usage}, 10)", 70},
+		{"min({CPU.Usage:cpu*.usage}) * -2 + 1.5", -38.5},
+		{"-CPU.Usage:cpu.usage", -50},
+	} {
+		t.Run(params.expression, func(t *testing.T) {
+			expression, err := ParseExpression(params.expression)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			result, err := expression.Evaluate(inputs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			assert.InDelta(t, params.expected, result, 0.000001)
+		})
+	}
+}
+
+func TestExpression_Inputs(t *testing.T) {
+	expression, err := ParseExpression("Memory.MemInfo:mem_free / Memory.MemInfo:mem_total + avg({CPU.Usage:cpu*.usage})")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, []string{"CPU.Usage", "Memory.MemInfo"}, expression.Inputs())
+}
+
+func TestParseExpressionNegative(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"mem_total",
+		"median(Memory.MemInfo:mem_total)",
+		"{CPU.Usage:cpu*.usage",
+		"{cpu*.usage}",
+		"1 $ 2",
+		"1 2",
+	} {
+		t.Run(fmt.Sprintf("Source %q", source), func(t *testing.T) {
+			_, err := ParseExpression(source)
+			assert.ErrorIs(t, err, ErrInvalidExpression)
+		})
+	}
+}
+
+func TestExpression_EvaluateNegative(t *testing.T) {
+	usage, err := jsonfields.Parse(`{"cpu0": {"usage": 20}, "cpu1": {"usage": 40}}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	inputs := map[string]any{"CPU.Usage": usage}
+
+	for _, source := range []string{
+		"{CPU.Usage:cpu*.usage} * 2",
+		"Memory.MemInfo:mem_total",
+		"CPU.Usage:cpu2.usage",
+		"CPU.Usage:cpu0.usage / 0",
+	} {
+		t.Run(source, func(t *testing.T) {
+			expression, err := ParseExpression(source)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			_, err = expression.Evaluate(inputs)
+			assert.ErrorIs(t, err, ErrEvaluation)
+		})
+	}
+}
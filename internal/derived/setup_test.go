@@ -0,0 +1,27 @@
+package derived
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/knadh/koanf/providers/confmap"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
+		"logging.log_level": "TRACE",
+		"logging.method":    "CONSOLE",
+	}, "."), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := logging.InitLogging(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	os.Exit(code)
+}
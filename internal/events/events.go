@@ -0,0 +1,231 @@
+// Package events provides a stream of discrete, non-metric occurrences like plugin crashes, logins or configuration reloads.
+// Events are persisted in their own table and published on a broker separate from the one carrying the monitor samples.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"sync"
+	"time"
+)
+
+var logger logging.Logger
+
+var ErrInvalidEvent = errors.New("invalid event")
+var ErrInvalidSeverity = errors.New("invalid severity")
+
+// Severity classifies how important an event is.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// severityLevels orders the severities from least to most important.
+var severityLevels = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityError:    2,
+	SeverityCritical: 3,
+}
+
+// ParseSeverity parses a Severity from its string representation.
+func ParseSeverity(severity string) (Severity, error) {
+	if _, ok := severityLevels[Severity(severity)]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSeverity, severity)
+	}
+
+	return Severity(severity), nil
+}
+
+// AtLeast returns whether the severity is at least as important as min.
+func (severity Severity) AtLeast(min Severity) bool {
+	return severityLevels[severity] >= severityLevels[min]
+}
+
+// Types of the events emitted by Excubitor itself.
+const (
+	TypeStarted            = "Excubitor.Started"
+	TypeConfigReloaded     = "Config.Reloaded"
+	TypeConfigReloadFailed = "Config.ReloadFailed"
+	TypeModuleFailed       = "Module.Failed"
+	TypePluginStarted      = "Plugin.Started"
+	TypePluginCrashed      = "Plugin.Crashed"
+	TypeLoginSucceeded     = "Auth.LoginSucceeded"
+	TypeLoginFailed        = "Auth.LoginFailed"
+)
+
+// Event describes a single occurrence.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Type names the kind of occurrence, e.g. Plugin.Crashed. It is the topic the event is published on.
+	Type     string   `json:"type"`
+	Severity Severity `json:"severity"`
+	// Source is the name of the module emitting the event.
+	Source     string            `json:"source"`
+	Message    string            `json:"message"`
+	Attributes map[string]string `json:"attributes"`
+	Host       string            `json:"host"`
+}
+
+// Writer persists events. It is implemented by db.Storage.
+type Writer interface {
+	AddEvent(event Event) error
+}
+
+// Reader retrieves persisted events ordered by time. It is implemented by db.Storage.
+type Reader interface {
+	GetEvents(from time.Time, until time.Time) ([]Event, error)
+}
+
+// Query selects events from the timeline.
+type Query struct {
+	From  time.Time
+	Until time.Time
+	// Pattern selects the event types, using the same wildcards as monitor subscriptions. Defaults to all types.
+	Pattern string
+	// MinSeverity selects only events at least this severe. Defaults to all severities.
+	MinSeverity Severity
+}
+
+// Stream persists emitted events and publishes them to its subscribers.
+type Stream struct {
+	broker *pubsub.MemoryBroker
+	writer Writer
+	reader Reader
+}
+
+var stream *Stream
+var streamLock sync.RWMutex
+
+// Init creates the event stream used by Emit and GetStream.
+func Init(writer Writer, reader Reader) *Stream {
+	streamLock.Lock()
+	defer streamLock.Unlock()
+
+	stream = NewStream(writer, reader)
+
+	return stream
+}
+
+// GetStream returns the event stream created by Init or nil if Init has not been called yet.
+func GetStream() *Stream {
+	streamLock.RLock()
+	defer streamLock.RUnlock()
+
+	return stream
+}
+
+// Emit emits an event on the stream created by Init.
+// Events emitted before Init are only logged, so callers don't need to care whether the stream is set up.
+func Emit(event Event) {
+	current := GetStream()
+	if current == nil {
+		logging.GetLogger().Debug(fmt.Sprintf("Event stream is not initialized, dropping %s event.", event.Type))
+		return
+	}
+
+	if err := current.Emit(event); err != nil {
+		logger.Error(fmt.Sprintf("Could not emit %s event! Reason: %s", event.Type, err))
+	}
+}
+
+// NewStream constructs a Stream persisting events with writer and reading them with reader.
+func NewStream(writer Writer, reader Reader) *Stream {
+	logger = logging.GetLogger()
+
+	return &Stream{
+		broker: pubsub.NewBroker(),
+		writer: writer,
+		reader: reader,
+	}
+}
+
+// GetBroker returns the broker the events are published on. Each event is published on its type as JSON.
+func (stream *Stream) GetBroker() pubsub.Broker {
+	return stream.broker
+}
+
+// Emit validates, persists and publishes an event.
+// The timestamp and host are filled in if they are not set.
+func (stream *Stream) Emit(event Event) error {
+	if err := pubsub.ValidatePattern(event.Type); err != nil || pubsub.IsPattern(event.Type) {
+		return fmt.Errorf("%w: %s is not a valid type", ErrInvalidEvent, event.Type)
+	}
+
+	if _, err := ParseSeverity(string(event.Severity)); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidEvent, err)
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	if event.Host == "" {
+		event.Host = identity.GetHostname()
+	}
+
+	if event.Attributes == nil {
+		event.Attributes = map[string]string{}
+	}
+
+	logger.Debug(fmt.Sprintf("Emitting %s event %s from %s.", event.Severity, event.Type, event.Source))
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// The event is published even if it could not be persisted, so that live subscribers still learn about it.
+	stream.broker.Publish(event.Type, string(encoded))
+
+	if err := stream.writer.AddEvent(event); err != nil {
+		return fmt.Errorf("persisting event: %w", err)
+	}
+
+	return nil
+}
+
+// Query returns the persisted events matching the query ordered by time, i.e. the timeline of this host.
+func (stream *Stream) Query(query Query) ([]Event, error) {
+	if query.Pattern == "" {
+		query.Pattern = pubsub.MultiLevelWildcard
+	}
+
+	if err := pubsub.ValidatePattern(query.Pattern); err != nil {
+		return nil, err
+	}
+
+	if query.MinSeverity == "" {
+		query.MinSeverity = SeverityInfo
+	}
+
+	if _, err := ParseSeverity(string(query.MinSeverity)); err != nil {
+		return nil, err
+	}
+
+	if query.Until.IsZero() {
+		query.Until = time.Now()
+	}
+
+	events, err := stream.reader.GetEvents(query.From, query.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []Event{}
+	for _, event := range events {
+		if pubsub.MatchTopic(query.Pattern, event.Type) && event.Severity.AtLeast(query.MinSeverity) {
+			selected = append(selected, event)
+		}
+	}
+
+	return selected, nil
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps events in memory instead of the database.
+type memoryStore struct {
+	events []Event
+	err    error
+	lock   sync.Mutex
+}
+
+func (store *memoryStore) AddEvent(event Event) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if store.err != nil {
+		return store.err
+	}
+
+	store.events = append(store.events, event)
+	return nil
+}
+
+func (store *memoryStore) GetEvents(from time.Time, until time.Time) ([]Event, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	var result []Event
+	for _, event := range store.events {
+		if !event.Timestamp.Before(from) && !event.Timestamp.After(until) {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("warning")
+	assert.NoError(t, err)
+	assert.Equal(t, SeverityWarning, severity)
+
+	_, err = ParseSeverity("fatal")
+	assert.ErrorIs(t, err, ErrInvalidSeverity)
+
+	assert.True(t, SeverityCritical.AtLeast(SeverityError))
+	assert.True(t, SeverityError.AtLeast(SeverityError))
+	assert.False(t, SeverityInfo.AtLeast(SeverityWarning))
+}
+
+func TestEmit(t *testing.T) {
+	store := &memoryStore{}
+	stream := NewStream(store, store)
+
+	subscriber := stream.GetBroker().AddSubscriber()
+	defer subscriber.Destruct()
+	require.NoError(t, stream.GetBroker().Subscribe(subscriber, "Plugin.*"))
+
+	require.NoError(t, stream.Emit(Event{
+		Type:       TypePluginCrashed,
+		Severity:   SeverityError,
+		Source:     "Plugins",
+		Message:    "Plugin exited.",
+		Attributes: map[string]string{"plugin": "demo"},
+	}))
+
+	require.Len(t, store.events, 1)
+	persisted := store.events[0]
+	assert.False(t, persisted.Timestamp.IsZero())
+	assert.Equal(t, "test-host", persisted.Host)
+
+	retained, ok := stream.GetBroker().GetRetained(TypePluginCrashed)
+	require.True(t, ok)
+
+	var published Event
+	require.NoError(t, json.Unmarshal([]byte(retained.GetMessageBody()), &published))
+	assert.Equal(t, TypePluginCrashed, published.Type)
+	assert.Equal(t, SeverityError, published.Severity)
+	assert.Equal(t, map[string]string{"plugin": "demo"}, published.Attributes)
+	assert.Equal(t, "test-host", published.Host)
+}
+
+func TestEmitNegative(t *testing.T) {
+	store := &memoryStore{}
+	stream := NewStream(store, store)
+
+	for description, event := range map[string]Event{
+		"Missing type":     {Severity: SeverityInfo},
+		"Wildcard type":    {Type: "Plugin.*", Severity: SeverityInfo},
+		"Missing severity": {Type: TypeStarted},
+		"Invalid severity": {Type: TypeStarted, Severity: "fatal"},
+	} {
+		t.Run(description, func(t *testing.T) {
+			assert.ErrorIs(t, stream.Emit(event), ErrInvalidEvent)
+		})
+	}
+
+	assert.Empty(t, store.events)
+}
+
+func TestEmitStoreFailure(t *testing.T) {
+	store := &memoryStore{err: errors.New("disk full")}
+	stream := NewStream(store, store)
+
+	assert.Error(t, stream.Emit(Event{Type: TypeStarted, Severity: SeverityInfo}))
+
+	// Live subscribers still receive the event.
+	_, ok := stream.GetBroker().GetRetained(TypeStarted)
+	assert.True(t, ok)
+}
+
+func TestQuery(t *testing.T) {
+	store := &memoryStore{}
+	stream := NewStream(store, store)
+
+	now := time.Now()
+	for _, event := range []Event{
+		{Timestamp: now.Add(-3 * time.Hour), Type: TypeStarted, Severity: SeverityInfo},
+		{Timestamp: now.Add(-2 * time.Hour), Type: TypePluginStarted, Severity: SeverityInfo},
+		{Timestamp: now.Add(-time.Hour), Type: TypePluginCrashed, Severity: SeverityError},
+		{Timestamp: now.Add(-time.Minute), Type: TypeLoginFailed, Severity: SeverityWarning},
+	} {
+		require.NoError(t, stream.Emit(event))
+	}
+
+	types := func(events []Event) []string {
+		var result []string
+		for _, event := range events {
+			result = append(result, event.Type)
+		}
+
+		return result
+	}
+
+	result, err := stream.Query(Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypeStarted, TypePluginStarted, TypePluginCrashed, TypeLoginFailed}, types(result))
+
+	result, err = stream.Query(Query{Pattern: "Plugin.*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypePluginStarted, TypePluginCrashed}, types(result))
+
+	result, err = stream.Query(Query{MinSeverity: SeverityWarning})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypePluginCrashed, TypeLoginFailed}, types(result))
+
+	result, err = stream.Query(Query{From: now.Add(-150 * time.Minute), Until: now.Add(-30 * time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{TypePluginStarted, TypePluginCrashed}, types(result))
+
+	_, err = stream.Query(Query{Pattern: "Plugin.#.Crashed"})
+	assert.ErrorIs(t, err, pubsub.ErrInvalidPattern)
+
+	_, err = stream.Query(Query{MinSeverity: "fatal"})
+	assert.ErrorIs(t, err, ErrInvalidSeverity)
+}
+
+func TestEmitWithoutStream(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Emit(Event{Type: TypeStarted, Severity: SeverityInfo})
+	})
+}
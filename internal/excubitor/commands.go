@@ -0,0 +1,163 @@
+package excubitor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/export"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	flags "github.com/spf13/pflag"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrMissingFlag = errors.New("missing flag")
+
+// commands are the subcommands of Excubitor by name. Without a subcommand, Excubitor runs the server.
+var commands = map[string]func(args []string) error{
+	"export": exportCommand,
+	"import": importCommand,
+	"backup": backupCommand,
+}
+
+// exportCommand exports history from the database file to a CSV or NDJSON file.
+func exportCommand(args []string) error {
+	f := config.NewFlagSet("export")
+	targets := f.StringSlice("target", []string{}, "Targets to export, patterns like CPU.* are supported. Exports all targets if not set.")
+	from := f.String("from", "", "Export history from this RFC 3339 timestamp on. Only the raw history is exported, history older than data.storage_time that is only kept as rollups is left out.")
+	until := f.String("until", "", "Export history until this RFC 3339 timestamp. Defaults to now.")
+	formatName := f.String("format", string(export.FormatNDJSON), "Format of the export, csv or ndjson.")
+	output := f.String("output", "", "File the history is exported to.")
+
+	if err := initCommand(f, args); err != nil {
+		return err
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return fmt.Errorf("%w: --output is required", ErrMissingFlag)
+	}
+
+	query := export.Query{Targets: *targets, Until: time.Now()}
+	if err := parseTimestampFlag("from", *from, &query.From); err != nil {
+		return err
+	}
+
+	if err := parseTimestampFlag("until", *until, &query.Until); err != nil {
+		return err
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+
+	if err := export.Export(file, db.GetStorage(), format, query); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	logging.GetLogger().Info(fmt.Sprintf("Exported history to %s.", *output))
+
+	return db.Close()
+}
+
+// importCommand imports history from an NDJSON file into the database file. Records already in the history are skipped.
+func importCommand(args []string) error {
+	f := config.NewFlagSet("import")
+	input := f.String("input", "-", "NDJSON file the history is imported from, - reads from standard input.")
+
+	if err := initCommand(f, args); err != nil {
+		return err
+	}
+
+	var source io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = file.Close() }()
+
+		source = file
+	}
+
+	imported, skipped, err := export.Import(source, db.GetStorage())
+	if err != nil {
+		return fmt.Errorf("import stopped after %d records: %w", imported, err)
+	}
+
+	logging.GetLogger().Info(fmt.Sprintf("Imported %d records, skipped %d already in the history.", imported, skipped))
+
+	return db.Close()
+}
+
+// backupCommand writes a consistent snapshot of the database file, also while a server is writing to it.
+func backupCommand(args []string) error {
+	f := config.NewFlagSet("backup")
+	output := f.String("output", "", "File the snapshot is written to. Defaults to a timestamped file in data.backup.directory.")
+
+	if err := initCommand(f, args); err != nil {
+		return err
+	}
+
+	file := *output
+	if file == "" {
+		directory := config.GetConfig().String("data.backup.directory")
+		if err := os.MkdirAll(directory, 0o750); err != nil {
+			return err
+		}
+
+		file = filepath.Join(directory, db.BackupFileName(time.Now()))
+	}
+
+	if err := db.Backup(db.GetStorage(), file); err != nil {
+		return err
+	}
+
+	logging.GetLogger().Info(fmt.Sprintf("Backed up database to %s.", file))
+
+	return db.Close()
+}
+
+// initCommand loads the configuration with the flags of a subcommand and opens the database without starting the
+// recurring jobs, as a server may be running on the same database file.
+func initCommand(f *flags.FlagSet, args []string) error {
+	if err := config.InitConfigWithFlags(f, args); err != nil {
+		return err
+	}
+
+	if err := logging.InitLogging(); err != nil {
+		return err
+	}
+
+	return db.OpenDatabase()
+}
+
+// parseTimestampFlag parses the RFC 3339 timestamp of a flag into target. Empty values keep target as it is.
+func parseTimestampFlag(name string, value string, target *time.Time) error {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("--%s is not a valid RFC 3339 timestamp: %w", name, err)
+	}
+
+	*target = parsed
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package excubitor
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/fsnotify/fsnotify"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloadDebounce is the time to wait for further file events before reloading the configuration.
+// Editors tend to write files in multiple steps, which would otherwise trigger multiple reloads.
+const reloadDebounce = 500 * time.Millisecond
+
+// watchConfig reloads the configuration whenever the process receives SIGHUP or the configuration file changes.
+func watchConfig() error {
+	logger := logging.GetLogger()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating config file watcher: %w", err)
+	}
+
+	configFile, err := filepath.Abs(config.GetConfigFile())
+	if err != nil {
+		return fmt.Errorf("resolving config file path: %w", err)
+	}
+
+	// Watch the directory instead of the file as editors often replace files instead of writing to them.
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		return fmt.Errorf("watching config file: %w", err)
+	}
+
+	logger.Debug(fmt.Sprintf("Watching %s for configuration changes.", configFile))
+
+	go func() {
+		var debounce <-chan time.Time
+
+		for {
+			select {
+			case <-hangups:
+				logger.Info("Received SIGHUP, reloading configuration...")
+				reloadConfig()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(event.Name) != configFile || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+
+				debounce = time.After(reloadDebounce)
+			case <-debounce:
+				logger.Info("Configuration file changed, reloading configuration...")
+				reloadConfig()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logger.Error(fmt.Sprintf("Error while watching configuration file! Reason: %s", err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadConfig reloads the configuration and logs which changes have been applied.
+func reloadConfig() {
+	logger := logging.GetLogger()
+
+	result, err := config.Reload()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not reload configuration, keeping the current one! Reason: %s", err))
+
+		events.Emit(events.Event{
+			Type:     events.TypeConfigReloadFailed,
+			Severity: events.SeverityError,
+			Source:   "Excubitor",
+			Message:  err.Error(),
+		})
+
+		return
+	}
+
+	events.Emit(events.Event{
+		Type:     events.TypeConfigReloaded,
+		Severity: events.SeverityInfo,
+		Source:   "Excubitor",
+		Message:  "Configuration has been reloaded.",
+		Attributes: map[string]string{
+			"applied":          strings.Join(result.Applied, ","),
+			"restart_required": strings.Join(result.RestartRequired, ","),
+		},
+	})
+
+	for _, key := range result.Applied {
+		logger.Info(fmt.Sprintf("Applied changed configuration parameter %s.", key))
+	}
+
+	for _, key := range result.RestartRequired {
+		logger.Warn(fmt.Sprintf("Configuration parameter %s has changed but can only be applied by restarting Excubitor.", key))
+	}
+
+	if len(result.Applied) == 0 && len(result.RestartRequired) == 0 {
+		logger.Info("Configuration reloaded without changes.")
+	}
+}
@@ -6,24 +6,39 @@ import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/derived"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/integrated_modules/cpu"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/integrated_modules/memory"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/integrated_modules/self"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/mqtt"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/plugins"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/recorder"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
+	"os"
 )
 
 func Execute() error {
 	var err error
 
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			return command(os.Args[2:])
+		}
+	}
+
 	if err := config.InitConfig(); err != nil {
 		return err
 	}
 	if err := logging.InitLogging(); err != nil {
 		return err
 	}
+	if err := watchConfig(); err != nil {
+		return err
+	}
 
 	if config.GetConfig().Bool("main.print_startup_banner") {
 		printBanner()
@@ -43,8 +58,20 @@ func Execute() error {
 		return err
 	}
 
+	logger.Debug("Starting event stream...")
+	events.Init(db.GetStorage(), db.GetStorage())
+
 	logger.Debug("Loading context...")
 	context := ctx.GetContext()
+
+	logger.Debug("Registering broker...")
+	broker, err := pubsub.NewFilteringBrokerFromConfig(pubsub.NewBroker())
+	if err != nil {
+		return err
+	}
+	context.RegisterBroker(broker)
+	db.SetSchemaRegistry(broker)
+
 	context.RegisterModule(
 		modules.NewModule(
 			"main",
@@ -80,7 +107,7 @@ func Execute() error {
 					Tag:     "cpu-usage-history",
 				},
 			},
-			cpu.Tick,
+			func() { cpu.Tick(broker) },
 		),
 	)
 
@@ -110,20 +137,59 @@ func Execute() error {
 					Tag:     "swap-usage-history",
 				},
 			},
-			memory.Tick,
+			func() { memory.Tick(broker) },
 		),
 	)
 
-	logger.Debug("Registering broker...")
-	context.RegisterBroker(pubsub.NewBroker())
+	context.RegisterModule(
+		modules.NewModule(
+			"Excubitor",
+			modules.NewVersion(0, 0, 1),
+			[]modules.Component{},
+			func() { self.Tick(broker) },
+		),
+	)
+
+	logger.Debug("Registering schemas of integrated modules...")
+	for _, registerSchemas := range []func(pubsub.Broker) error{cpu.RegisterSchemas, memory.RegisterSchemas, self.RegisterSchemas} {
+		if err := registerSchemas(broker); err != nil {
+			return err
+		}
+	}
+
+	logger.Debug("Starting recorder...")
+	rec, err := recorder.Start()
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Starting MQTT bridge...")
+	bridge, err := mqtt.Start()
+	if err != nil {
+		return err
+	}
+
+	handleShutdown(rec, bridge)
+
+	logger.Debug("Starting derived monitors...")
+	if err := derived.Start(); err != nil {
+		return err
+	}
 
 	if err := plugins.LoadPlugins(); err != nil {
 		return err
 	}
-	if err := plugins.InitPlugins(); err != nil {
+	if err := plugins.InitPlugins(broker); err != nil {
 		return err
 	}
 
+	events.Emit(events.Event{
+		Type:     events.TypeStarted,
+		Severity: events.SeverityInfo,
+		Source:   "Excubitor",
+		Message:  "Excubitor has been started.",
+	})
+
 	logger.Debug("Starting HTTP Server!")
 
 	err = http_server.Start()
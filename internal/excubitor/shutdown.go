@@ -0,0 +1,39 @@
+package excubitor
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/mqtt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/recorder"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleShutdown stops the MQTT bridge, if enabled, writes all pending messages of the recorder and closes the database
+// once the process receives SIGINT or SIGTERM, so that no recorded history is lost on shutdown.
+func handleShutdown(rec *recorder.Recorder, bridge *mqtt.Bridge) {
+	logger := logging.GetLogger()
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		received := <-shutdown
+		logger.Info(fmt.Sprintf("Received %s, shutting down...", received))
+
+		if bridge != nil {
+			bridge.Stop()
+		}
+
+		rec.Stop()
+
+		if err := db.Close(); err != nil {
+			logger.Error(fmt.Sprintf("Could not close database! Reason: %s", err))
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}()
+}
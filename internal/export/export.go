@@ -0,0 +1,390 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+var ErrInvalidRecord = errors.New("invalid record")
+
+// Format is a file format the history can be exported to.
+type Format string
+
+const (
+	// FormatCSV writes a row per entry with the flattened fields of all entries as columns.
+	FormatCSV Format = "csv"
+	// FormatNDJSON writes a Record per line. It's the only format that can be imported again.
+	FormatNDJSON Format = "ndjson"
+)
+
+// importBatchSize is the number of records written to the database within a single transaction on import.
+const importBatchSize = 1000
+
+// maxRecordSize is the size of the longest line an NDJSON import accepts.
+const maxRecordSize = 16 * 1024 * 1024
+
+// csvColumns are the columns every CSV export starts with. The flattened fields of the values follow them.
+var csvColumns = []string{"timestamp", "target", "host", "labels"}
+
+// valueColumn is the column of values that aren't JSON objects or arrays, as they have no fields.
+const valueColumn = "value"
+
+// ParseFormat parses the name of an export format.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatCSV, FormatNDJSON:
+		return Format(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s. Supported formats are %s and %s", ErrUnknownFormat, name, FormatCSV, FormatNDJSON)
+	}
+}
+
+// ContentType returns the MIME type of the format.
+func (format Format) ContentType() string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+
+	return "application/x-ndjson"
+}
+
+// Query selects the history to export. Targets are patterns as used by subscriptions; without targets, the history
+// of all monitors is exported.
+type Query struct {
+	Targets []string
+	From    time.Time
+	Until   time.Time
+}
+
+// Record is a history entry as it is exported to and imported from NDJSON.
+// JSON objects and arrays are embedded into Value as they are, all other values are embedded as a JSON string.
+type Record struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Target    string            `json:"target"`
+	Host      string            `json:"host"`
+	Labels    map[string]string `json:"labels"`
+	Value     json.RawMessage   `json:"value"`
+}
+
+// Export writes the history selected by the query to w in the given format. The history is streamed, so nothing but
+// the column names of a CSV export is held in memory.
+// Only the raw history is exported. History that has been purged from it and is only kept in the rollup tiers
+// anymore, i.e. history older than data.storage_time, is left out.
+func Export(w io.Writer, storage db.Storage, format Format, query Query) error {
+	switch format {
+	case FormatCSV:
+		return exportCSV(w, storage, query)
+	case FormatNDJSON:
+		return exportNDJSON(w, storage, query)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// exportNDJSON writes a Record per history entry.
+func exportNDJSON(w io.Writer, storage db.Storage, query Query) error {
+	encoder := json.NewEncoder(w)
+
+	return storage.WalkHistory(query.Targets, query.From, query.Until, func(message db.HistoryMessage) error {
+		value := []byte(message.Message.Value)
+		if !embeddable(value) {
+			var err error
+			if value, err = json.Marshal(message.Message.Value); err != nil {
+				return err
+			}
+		}
+
+		return encoder.Encode(Record{
+			Timestamp: message.Timestamp.UTC(),
+			Target:    message.Message.Target,
+			Host:      message.Host,
+			Labels:    message.Labels,
+			Value:     value,
+		})
+	})
+}
+
+// exportCSV writes a row per history entry. The header needs to know the fields of all entries in advance, so the
+// history is read twice.
+func exportCSV(w io.Writer, storage db.Storage, query Query) error {
+	fieldSet := map[string]bool{}
+	err := storage.WalkHistory(query.Targets, query.From, query.Until, func(message db.HistoryMessage) error {
+		for field := range fields(message.Message.Value) {
+			fieldSet[field] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, 0, len(fieldSet))
+	for field := range fieldSet {
+		columns = append(columns, field)
+	}
+
+	sort.Strings(columns)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(append([]string{}, csvColumns...), columns...)); err != nil {
+		return err
+	}
+
+	err = storage.WalkHistory(query.Targets, query.From, query.Until, func(message db.HistoryMessage) error {
+		labels, err := json.Marshal(message.Labels)
+		if err != nil {
+			return err
+		}
+
+		row := append(make([]string, 0, len(csvColumns)+len(columns)),
+			message.Timestamp.UTC().Format(time.RFC3339Nano), message.Message.Target, message.Host, string(labels))
+
+		values := fields(message.Message.Value)
+		for _, column := range columns {
+			row = append(row, values[column])
+		}
+
+		return writer.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// fields returns the flattened fields of a value formatted for CSV. Values that aren't JSON objects or arrays are
+// returned as the valueColumn.
+func fields(value string) map[string]string {
+	parsed, err := jsonfields.Parse(value)
+	if err != nil {
+		return map[string]string{valueColumn: value}
+	}
+
+	formatted := map[string]string{}
+	for path, leaf := range jsonfields.Flatten(parsed) {
+		if path == "" {
+			path = valueColumn
+		}
+
+		switch v := leaf.(type) {
+		case nil:
+			formatted[path] = ""
+		case float64:
+			formatted[path] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			formatted[path] = strconv.FormatBool(v)
+		case string:
+			formatted[path] = v
+		default:
+			formatted[path] = fmt.Sprint(v)
+		}
+	}
+
+	return formatted
+}
+
+// embeddable reports whether a value is a JSON object or array, which is embedded into a Record as it is.
+func embeddable(value []byte) bool {
+	trimmed := bytes.TrimSpace(value)
+
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed)
+}
+
+// Import reads Records from NDJSON and writes them to the history with their original timestamps, hosts and labels.
+// Records of which the history already has an entry with the same timestamp, target and host are skipped, so that
+// importing a file twice doesn't duplicate its history.
+// Records older than the earliest entry the history had before are merged into the rollups, as their buckets can't be
+// computed again from the raw history left. Afterwards, the rollups are compacted again from the earliest of the other
+// imported records on.
+// It returns the number of imported and of skipped records.
+func Import(r io.Reader, storage db.Storage) (int, int, error) {
+	earliestBefore, hadHistory, err := storage.GetEarliest()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+
+	var batch []db.HistoryEntry
+	var earliest time.Time
+	imported := 0
+	skipped := 0
+	line := 0
+
+	// write writes the batch, leaving out the entries already in the history.
+	write := func() error {
+		entries, err := withoutExisting(storage, batch)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.AddHistoryEntries(entries); err != nil {
+			return err
+		}
+
+		var older []db.HistoryEntry
+		for _, entry := range entries {
+			if !hadHistory || entry.Timestamp.Before(earliestBefore) {
+				older = append(older, entry)
+				continue
+			}
+
+			if earliest.IsZero() || entry.Timestamp.Before(earliest) {
+				earliest = entry.Timestamp
+			}
+		}
+
+		if err := db.MergeRollups(storage, older); err != nil {
+			return err
+		}
+
+		imported += len(entries)
+		skipped += len(batch) - len(entries)
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		line++
+
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		entry, err := parseRecord(scanner.Bytes())
+		if err != nil {
+			return imported, skipped, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		batch = append(batch, entry)
+		if len(batch) < importBatchSize {
+			continue
+		}
+
+		if err := write(); err != nil {
+			return imported, skipped, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return imported, skipped, err
+	}
+
+	if err := write(); err != nil {
+		return imported, skipped, err
+	}
+
+	if imported == 0 {
+		return 0, skipped, nil
+	}
+
+	// Without records to compact again, the rollups are still compacted up to now.
+	if earliest.IsZero() {
+		earliest = time.Now()
+	}
+
+	return imported, skipped, db.Recompact(storage, earliest)
+}
+
+// entryKey identifies an entry of the history on import.
+type entryKey struct {
+	timestamp int64
+	target    string
+	host      string
+}
+
+// withoutExisting returns the entries of batch the history of storage doesn't have an entry with the same timestamp,
+// target and host of yet. Of multiple such entries within the batch, only the first one is kept.
+func withoutExisting(storage db.Storage, batch []db.HistoryEntry) ([]db.HistoryEntry, error) {
+	type timeRange struct {
+		from  time.Time
+		until time.Time
+	}
+
+	ranges := map[string]timeRange{}
+	for _, entry := range batch {
+		r, ok := ranges[entry.Target]
+		if !ok {
+			r = timeRange{from: entry.Timestamp, until: entry.Timestamp}
+		}
+
+		if entry.Timestamp.Before(r.from) {
+			r.from = entry.Timestamp
+		}
+
+		if entry.Timestamp.After(r.until) {
+			r.until = entry.Timestamp
+		}
+
+		ranges[entry.Target] = r
+	}
+
+	existing := map[entryKey]bool{}
+	for target, r := range ranges {
+		history, err := storage.GetHistoryEntriesFromUntil(target, r.from, r.until)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range history {
+			existing[entryKey{message.Timestamp.UnixNano(), target, message.Host}] = true
+		}
+	}
+
+	entries := make([]db.HistoryEntry, 0, len(batch))
+	for _, entry := range batch {
+		key := entryKey{entry.Timestamp.UnixNano(), entry.Target, entry.Host}
+		if existing[key] {
+			continue
+		}
+
+		existing[key] = true
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// parseRecord parses a single line of an NDJSON import.
+func parseRecord(line []byte) (db.HistoryEntry, error) {
+	var record Record
+	if err := json.Unmarshal(line, &record); err != nil {
+		return db.HistoryEntry{}, fmt.Errorf("%w: %s", ErrInvalidRecord, err)
+	}
+
+	if record.Target == "" || record.Timestamp.IsZero() || len(record.Value) == 0 {
+		return db.HistoryEntry{}, fmt.Errorf("%w: timestamp, target and value are required", ErrInvalidRecord)
+	}
+
+	content := string(record.Value)
+
+	var text string
+	if err := json.Unmarshal(record.Value, &text); err == nil {
+		content = text
+	}
+
+	return db.HistoryEntry{
+		Timestamp: record.Timestamp,
+		Target:    record.Target,
+		Content:   content,
+		Host:      record.Host,
+		Labels:    record.Labels,
+	}, nil
+}
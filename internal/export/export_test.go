@@ -0,0 +1,229 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestHistory writes entries of two CPU targets and a non-JSON target starting at base. The targets start with
+// a prefix unique to every run, as all tests share the database. The prefix is returned.
+func writeTestHistory(t *testing.T, name string, base time.Time) string {
+	prefix := fmt.Sprintf("%s%d", name, time.Now().UnixNano())
+
+	require.NoError(t, db.OpenDatabase())
+	require.NoError(t, db.GetWriter().AddHistoryEntries([]db.HistoryEntry{
+		{Timestamp: base, Target: prefix + ".CPU.Usage", Content: `{"usage": 10, "cores": [5, 15]}`, Host: "some-host", Labels: map[string]string{"env": "prod"}},
+		{Timestamp: base.Add(time.Second), Target: prefix + ".CPU.Clock", Content: `{"clock": 2400}`, Host: "some-host"},
+		{Timestamp: base.Add(2 * time.Second), Target: prefix + ".CPU.Usage", Content: `{"usage": 20.5, "name": "Some CPU"}`, Host: "some-host"},
+		{Timestamp: base.Add(3 * time.Second), Target: prefix + ".Memory.RAM", Content: `not json`, Host: "some-host"},
+	}))
+
+	return prefix
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("csv")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	_, err = ParseFormat("parquet")
+	assert.True(t, errors.Is(err, ErrUnknownFormat))
+}
+
+func TestExportNDJSON(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	prefix := writeTestHistory(t, "NDJSON", base)
+
+	var buffer bytes.Buffer
+	require.NoError(t, Export(&buffer, db.GetStorage(), FormatNDJSON, Query{
+		Targets: []string{prefix + ".CPU.*", prefix + ".Memory.RAM"},
+		From:    base,
+		Until:   base.Add(time.Minute),
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Equal(t, 4, len(lines))
+
+	assert.JSONEq(t, fmt.Sprintf(`{"timestamp": "%s", "target": "%s.CPU.Usage", "host": "some-host", "labels": {"env": "prod"}, "value": {"usage": 10, "cores": [5, 15]}}`, base.Format(time.RFC3339Nano), prefix), lines[0])
+	assert.Contains(t, lines[1], fmt.Sprintf(`"target":"%s.CPU.Clock"`, prefix))
+	assert.Contains(t, lines[3], `"value":"not json"`)
+
+	// The range is applied to all targets.
+	buffer.Reset()
+	require.NoError(t, Export(&buffer, db.GetStorage(), FormatNDJSON, Query{
+		Targets: []string{prefix + ".CPU.Usage"},
+		From:    base.Add(time.Second),
+		Until:   base.Add(time.Minute),
+	}))
+
+	lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Equal(t, 1, len(lines))
+	assert.Contains(t, lines[0], `"usage":20.5`)
+}
+
+func TestExportCSV(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	prefix := writeTestHistory(t, "CSV", base)
+
+	var buffer bytes.Buffer
+	require.NoError(t, Export(&buffer, db.GetStorage(), FormatCSV, Query{
+		Targets: []string{prefix + ".#"},
+		From:    base,
+		Until:   base.Add(time.Minute),
+	}))
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, 5, len(records))
+
+	assert.Equal(t, []string{"timestamp", "target", "host", "labels", "clock", "cores.0", "cores.1", "name", "usage", "value"}, records[0])
+	assert.Equal(t, []string{base.Format(time.RFC3339Nano), prefix + ".CPU.Usage", "some-host", `{"env":"prod"}`, "", "5", "15", "", "10", ""}, records[1])
+	assert.Equal(t, []string{base.Add(time.Second).Format(time.RFC3339Nano), prefix + ".CPU.Clock", "some-host", `{}`, "2400", "", "", "", "", ""}, records[2])
+	assert.Equal(t, "Some CPU", records[3][7])
+	assert.Equal(t, "20.5", records[3][8])
+	assert.Equal(t, "not json", records[4][9])
+}
+
+func TestImport(t *testing.T) {
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Minute).UTC()
+	source := writeTestHistory(t, "Source", base)
+	imported := fmt.Sprintf("Imported%d", time.Now().UnixNano())
+
+	// Compact the source so that the import lies before the progress of the rollups.
+	require.NoError(t, db.GetWriter().Recompact(time.Now()))
+
+	var buffer bytes.Buffer
+	require.NoError(t, Export(&buffer, db.GetStorage(), FormatNDJSON, Query{
+		Targets: []string{source + ".#"},
+		From:    base,
+		Until:   base.Add(time.Minute),
+	}))
+
+	records := strings.ReplaceAll(buffer.String(), `"target":"`+source, `"target":"`+imported)
+
+	count, skipped, err := Import(strings.NewReader(records), db.GetStorage())
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.Equal(t, 0, skipped)
+
+	// Importing the same records again doesn't duplicate them, also not within a single import.
+	count, skipped, err = Import(strings.NewReader(records+records), db.GetStorage())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 8, skipped)
+
+	for _, target := range []string{"CPU.Usage", "CPU.Clock", "Memory.RAM"} {
+		original, err := db.GetReader().GetHistoryEntries(source + "." + target)
+		require.NoError(t, err)
+
+		history, err := db.GetReader().GetHistoryEntries(imported + "." + target)
+		require.NoError(t, err)
+		require.Equal(t, len(original), len(history))
+
+		for i := range history {
+			assert.True(t, original[i].Timestamp.Equal(history[i].Timestamp))
+			assert.Equal(t, original[i].Host, history[i].Host)
+			assert.Equal(t, original[i].Labels, history[i].Labels)
+
+			if target == "Memory.RAM" {
+				assert.Equal(t, original[i].Message.Value, history[i].Message.Value)
+			} else {
+				assert.JSONEq(t, original[i].Message.Value, history[i].Message.Value)
+			}
+		}
+	}
+
+	// The imported history has been rolled up.
+	minutes, err := db.GetHistory(db.GetStorage(), imported+".CPU.Usage", base, base.Add(time.Hour), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(minutes))
+	assert.Equal(t, "1m", minutes[0].Resolution)
+	assert.Equal(t, db.Aggregate{Min: 10, Max: 20.5, Avg: 15.25, Last: 20.5, Count: 2}, minutes[0].Aggregates["usage"])
+}
+
+func TestImportOlder(t *testing.T) {
+	// The history already reaches back an hour, like the one of a new host taking over from a replaced one.
+	writeTestHistory(t, "Existing", time.Now().Add(-time.Hour).UTC())
+	require.NoError(t, db.GetWriter().Recompact(time.Now()))
+
+	target := fmt.Sprintf("Older%d.CPU.Usage", time.Now().UnixNano())
+	base := time.Now().Add(-10 * 24 * time.Hour).Truncate(time.Hour).UTC()
+
+	var records strings.Builder
+	for i, usage := range []int{10, 30} {
+		timestamp := base.Add(time.Duration(i) * 10 * time.Second).Format(time.RFC3339Nano)
+		records.WriteString(fmt.Sprintf(`{"timestamp": "%s", "target": "%s", "value": {"usage": %d}}`+"\n", timestamp, target, usage))
+	}
+
+	count, _, err := Import(strings.NewReader(records.String()), db.GetStorage())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// The imported history is rolled up although the rollups have been compacted past it, so it outlasts the purge of
+	// the raw history.
+	for _, resolution := range []time.Duration{time.Minute, time.Hour} {
+		rollups, err := db.GetHistory(db.GetStorage(), target, base, base.Add(time.Hour), resolution)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(rollups), resolution)
+		assert.Equal(t, db.Aggregate{Min: 10, Max: 30, Avg: 20, Last: 30, Count: 2}, rollups[0].Aggregates["usage"])
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	require.NoError(t, db.OpenDatabase())
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	for description, input := range map[string]string{
+		"JSON":      "{\n",
+		"Target":    fmt.Sprintf(`{"timestamp": "%s", "value": "some value"}`, timestamp),
+		"Timestamp": `{"target": "Invalid.Target", "value": "some value"}`,
+		"Value":     fmt.Sprintf(`{"timestamp": "%s", "target": "Invalid.Target"}`, timestamp),
+	} {
+		t.Run(description, func(t *testing.T) {
+			input := fmt.Sprintf("\n%s\n", input)
+
+			imported, _, err := Import(strings.NewReader(input), db.GetStorage())
+			assert.True(t, errors.Is(err, ErrInvalidRecord))
+			assert.ErrorContains(t, err, "line 2")
+			assert.Equal(t, 0, imported)
+		})
+	}
+
+	history, err := db.GetReader().GetHistoryEntries("Invalid.Target")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestImportDuplicates(t *testing.T) {
+	require.NoError(t, db.OpenDatabase())
+
+	target := fmt.Sprintf("Duplicates%d.CPU.Usage", time.Now().UnixNano())
+	timestamp := time.Now().Add(-time.Hour).UTC()
+
+	record := func(host string, usage int) string {
+		return fmt.Sprintf(`{"timestamp": "%s", "target": "%s", "host": "%s", "value": {"usage": %d}}`+"\n", timestamp.Format(time.RFC3339Nano), target, host, usage)
+	}
+
+	// Entries of another host at the same time are no duplicates.
+	imported, skipped, err := Import(strings.NewReader(record("host-a", 10)+record("host-b", 20)+record("host-a", 30)), db.GetStorage())
+	require.NoError(t, err)
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, 1, skipped)
+
+	history, err := db.GetReader().GetHistoryEntries(target)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(history))
+
+	for _, message := range history {
+		assert.NotContains(t, message.Message.Value, "30")
+	}
+}
@@ -0,0 +1,53 @@
+package export
+
+import (
+	"errors"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/knadh/koanf/providers/confmap"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
+		"logging.log_level":                "TRACE",
+		"logging.method":                   "CONSOLE",
+		"data.storage_time":                "720h",
+		"data.purge_cycle":                 "1h",
+		"data.rollups.minute_storage_time": "720h",
+		"data.rollups.hour_storage_time":   "8760h",
+		"data.rollups.compaction_cycle":    "1h",
+		"data.events_storage_time":         "720h",
+		"data.max_database_size":           "0",
+		"data.backup.cycle":                "0",
+		"data.backup.keep":                 1,
+		"data.database_file":               "history_test.db",
+	}, "."), nil)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := logging.InitLogging(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	if db.GetWriter() != nil {
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, file := range []string{"history_test.db", "history_test.db-wal", "history_test.db-shm"} {
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			if err := os.Remove(file); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	os.Exit(code)
+}
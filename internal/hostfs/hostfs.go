@@ -0,0 +1,41 @@
+// Package hostfs provides access to the pseudo file systems of the monitored host.
+// Integrated modules read procfs and sysfs exclusively through this package so that their roots can be
+// relocated, i.e. to /host/proc when Excubitor runs inside a container, or to a fake tree in tests.
+package hostfs
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Proc returns the procfs of the monitored host as configured in data.procfs_root.
+func Proc() fs.FS {
+	return os.DirFS(config.GetConfig().String("data.procfs_root"))
+}
+
+// Sys returns the sysfs of the monitored host as configured in data.sysfs_root.
+func Sys() fs.FS {
+	return os.DirFS(config.GetConfig().String("data.sysfs_root"))
+}
+
+// ReadProcFile reads the file with the given name relative to the procfs root, i.e. "meminfo".
+func ReadProcFile(name string) ([]byte, error) {
+	return fs.ReadFile(Proc(), name)
+}
+
+// ReadSysFile reads the file with the given name relative to the sysfs root, i.e. "class/net/eth0/speed".
+func ReadSysFile(name string) ([]byte, error) {
+	return fs.ReadFile(Sys(), name)
+}
+
+// ProcPath returns the absolute path of a file inside the procfs root. It is meant for log messages.
+func ProcPath(name string) string {
+	return path.Join(config.GetConfig().String("data.procfs_root"), name)
+}
+
+// SysPath returns the absolute path of a file inside the sysfs root. It is meant for log messages.
+func SysPath(name string) string {
+	return path.Join(config.GetConfig().String("data.sysfs_root"), name)
+}
@@ -0,0 +1,55 @@
+package hostfs
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFiles(t *testing.T) {
+	procRoot := t.TempDir()
+	sysRoot := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(procRoot, "meminfo"), []byte("MemTotal: 1 kB\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(sysRoot, "class", "net", "eth0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sysRoot, "class", "net", "eth0", "speed"), []byte("1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
+		"data.procfs_root": procRoot,
+		"data.sysfs_root":  sysRoot,
+	}, "."), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meminfo, err := ReadProcFile("meminfo")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	speed, err := ReadSysFile("class/net/eth0/speed")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, "MemTotal: 1 kB\n", string(meminfo))
+	assert.Equal(t, "1000\n", string(speed))
+	assert.Equal(t, filepath.Join(procRoot, "meminfo"), ProcPath("meminfo"))
+	assert.Equal(t, filepath.Join(sysRoot, "class/net/eth0/speed"), SysPath("class/net/eth0/speed"))
+
+	_, err = ReadProcFile("stat")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
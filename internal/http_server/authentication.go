@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/helper"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pam"
 	"github.com/golang-jwt/jwt/v5"
@@ -81,6 +83,14 @@ func handleAuthRequest(w http.ResponseWriter, r *http.Request) {
 			pamCredentials := pam.PAMPasswordCredentials{Username: username, Password: password}
 
 			if pamCredentials.Authenticate() {
+				events.Emit(events.Event{
+					Type:       events.TypeLoginSucceeded,
+					Severity:   events.SeverityInfo,
+					Source:     "HTTP",
+					Message:    fmt.Sprintf("User %s logged in.", username),
+					Attributes: map[string]string{"user": username, "remote_address": r.RemoteAddr},
+				})
+
 				accessTokenClaims := jwt.MapClaims{
 					"iss": "excubitor-backend",
 					"sub": username,
@@ -125,6 +135,14 @@ func handleAuthRequest(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 			} else {
+				events.Emit(events.Event{
+					Type:       events.TypeLoginFailed,
+					Severity:   events.SeverityWarning,
+					Source:     "HTTP",
+					Message:    fmt.Sprintf("Failed login attempt for user %s.", username),
+					Attributes: map[string]string{"user": username, "remote_address": r.RemoteAddr},
+				})
+
 				helper.ReturnError(w, r, http.StatusUnauthorized, "Invalid username or password!")
 				return
 			}
@@ -154,7 +172,7 @@ func handleRefreshRequest(w http.ResponseWriter, r *http.Request) {
 	token := strings.Split(authorization, "Bearer ")[1]
 
 	jwtToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(k.String("http.auth.jwt.refresh_token_secret")), nil
+		return []byte(config.GetConfig().String("http.auth.jwt.refresh_token_secret")), nil
 	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithIssuer("excubitor-backend"))
 
 	if err != nil {
@@ -245,7 +263,7 @@ func queryAuth(next http.Handler) http.Handler {
 
 func checkToken(w http.ResponseWriter, r *http.Request, token string) bool {
 	jwtToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(k.String("http.auth.jwt.access_token_secret")), nil
+		return []byte(config.GetConfig().String("http.auth.jwt.access_token_secret")), nil
 	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithIssuer("excubitor-backend"))
 
 	if err != nil {
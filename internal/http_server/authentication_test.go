@@ -3,9 +3,9 @@ package http_server
 import (
 	"bytes"
 	"encoding/json"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/knadh/koanf/providers/confmap"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
@@ -358,15 +358,17 @@ func TestHandleRefreshRequestInvalidTokenExpired(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signRefreshToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -415,15 +417,17 @@ func TestHandleRefreshRequestInvalidTokenInvalidSignature(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -473,15 +477,17 @@ func TestHandleRefreshRequest(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signRefreshToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -522,7 +528,7 @@ func TestHandleRefreshRequest(t *testing.T) {
 	}
 
 	parsedToken, err := jwt.Parse(responseObject.AccessToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(k.String("http.auth.jwt.access_token_secret")), nil
+		return []byte(config.GetConfig().String("http.auth.jwt.access_token_secret")), nil
 	})
 	if err != nil {
 		t.Error(err)
@@ -632,15 +638,17 @@ func TestBearerAuthTokenExpired(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signAccessToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -690,15 +698,17 @@ func TestBearerAuthInvalidSignature(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -749,15 +759,17 @@ func TestBearerAuth(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signAccessToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -839,15 +851,17 @@ func TestQueryAuthTokenExpired(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signAccessToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -896,15 +910,17 @@ func TestQueryAuthInvalidSignature(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
@@ -954,15 +970,17 @@ func TestQueryAuth(t *testing.T) {
 		return
 	}
 
-	err = k.Load(confmap.Provider(map[string]interface{}{
+	restore, err := config.Override(map[string]interface{}{
 		"http.auth.jwt.access_token_secret":  "123456",
 		"http.auth.jwt.refresh_token_secret": "abcdef",
-	}, "."), nil)
+	})
 	if err != nil {
 		t.Error(err)
 		return
 	}
 
+	t.Cleanup(restore)
+
 	token, err := signAccessToken(jwt.MapClaims{
 		"iss": "excubitor-backend",
 		"sub": "testuser",
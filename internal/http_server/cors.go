@@ -1,14 +1,47 @@
 package http_server
 
 import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"github.com/rs/cors"
+	"net/http"
+	"sync"
 )
 
+// reloadableCORS wraps a CORS handler that is rebuilt whenever the configuration is reloaded.
+type reloadableCORS struct {
+	cors *cors.Cors
+	lock sync.RWMutex
+}
+
+func newReloadableCORS() *reloadableCORS {
+	handler := &reloadableCORS{cors: getCORSHandler()}
+
+	config.RegisterReloadHook(func() {
+		handler.lock.Lock()
+		defer handler.lock.Unlock()
+
+		handler.cors = getCORSHandler()
+	})
+
+	return handler
+}
+
+// Handler applies the current CORS configuration to the given handler.
+func (handler *reloadableCORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.lock.RLock()
+		current := handler.cors
+		handler.lock.RUnlock()
+
+		current.Handler(next).ServeHTTP(w, r)
+	})
+}
+
 func getCORSHandler() *cors.Cors {
 
-	allowedOrigins := k.Strings("http.cors.allowed_origins")
-	allowedMethods := k.Strings("http.cors.allowed_methods")
-	allowedHeaders := k.Strings("http.cors.allowed_headers")
+	allowedOrigins := config.GetConfig().Strings("http.cors.allowed_origins")
+	allowedMethods := config.GetConfig().Strings("http.cors.allowed_methods")
+	allowedHeaders := config.GetConfig().Strings("http.cors.allowed_headers")
 
 	return cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
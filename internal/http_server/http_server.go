@@ -2,29 +2,38 @@ package http_server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/export"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/helper"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/models"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/websocket"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/gobwas/ws"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var logger logging.Logger
-var k = config.GetConfig()
 
 func Start() error {
-	host := k.String("http.host")
-	port := k.Int("http.port")
+	host := config.GetConfig().String("http.host")
+	port := config.GetConfig().Int("http.port")
 
 	logger = logging.GetLogger()
 
 	logger.Info(fmt.Sprintf("Starting HTTP Server on port %d", port))
 
-	cors := getCORSHandler()
+	cors := newReloadableCORS()
 
 	err := http.ListenAndServe(fmt.Sprintf("%s:%d", host, port), cors.Handler(http.HandlerFunc(Serve)))
 	if err != nil {
@@ -55,6 +64,268 @@ func info(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// schemas answers with all registered monitor schemas or, if a monitor is given in the path, with the schema of that monitor.
+func schemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		helper.ReturnError(w, r, http.StatusMethodNotAllowed, "Only HTTP method GET is supported on /schemas.")
+		return
+	}
+
+	broker := ctx.GetContext().GetBroker()
+
+	var result any = broker.GetSchemas()
+
+	if monitor := strings.Trim(strings.TrimPrefix(strings.Trim(r.URL.Path, "/"), "schemas"), "/"); monitor != "" {
+		schema, ok := broker.GetSchema(monitor)
+		if !ok {
+			helper.ReturnError(w, r, http.StatusNotFound, fmt.Sprintf("No schema registered for monitor %s!", monitor))
+			return
+		}
+
+		result = schema
+	}
+
+	writeJSON(w, r, result, "schemas")
+}
+
+// timeline answers with the events of this host, optionally filtered by the query parameters
+// from and until (RFC 3339), type (an event type pattern) and min_severity.
+func timeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		helper.ReturnError(w, r, http.StatusMethodNotAllowed, "Only HTTP method GET is supported on /events.")
+		return
+	}
+
+	stream := events.GetStream()
+	if stream == nil {
+		helper.ReturnError(w, r, http.StatusServiceUnavailable, "Events are not available!")
+		return
+	}
+
+	parameters := r.URL.Query()
+	query := events.Query{
+		Pattern:     parameters.Get("type"),
+		MinSeverity: events.Severity(parameters.Get("min_severity")),
+	}
+
+	if !parseTimeRange(w, r, &query.From, &query.Until) {
+		return
+	}
+
+	result, err := stream.Query(query)
+	if err != nil {
+		if errors.Is(err, pubsub.ErrInvalidPattern) || errors.Is(err, events.ErrInvalidSeverity) {
+			helper.ReturnError(w, r, http.StatusBadRequest, fmt.Sprintf("Bad parameters: %s", err))
+			return
+		}
+
+		logger.Error(fmt.Sprintf("Could not retrieve events. Reason: %s", err))
+		helper.ReturnError(w, r, 500, "Internal server error!")
+		return
+	}
+
+	writeJSON(w, r, result, "events")
+}
+
+// aggregate answers with the aggregation of a numeric field of a monitor's history. It takes the query parameters
+// target, field, bucket (a duration), from and until (RFC 3339), percentiles (a comma-separated list) and rate.
+func aggregate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		helper.ReturnError(w, r, http.StatusMethodNotAllowed, "Only HTTP method GET is supported on /history/aggregate.")
+		return
+	}
+
+	parameters := r.URL.Query()
+	query := db.AggregateQuery{
+		Target: parameters.Get("target"),
+		Field:  parameters.Get("field"),
+		Until:  time.Now(),
+	}
+
+	if query.Target == "" {
+		helper.ReturnError(w, r, http.StatusBadRequest, "Parameter target is required!")
+		return
+	}
+
+	bucket, err := time.ParseDuration(parameters.Get("bucket"))
+	if err != nil {
+		helper.ReturnError(w, r, http.StatusBadRequest, "Parameter bucket is not a valid duration!")
+		return
+	}
+
+	query.Bucket = bucket
+
+	if !parseTimeRange(w, r, &query.From, &query.Until) {
+		return
+	}
+
+	if parameters.Get("percentiles") != "" {
+		for _, value := range strings.Split(parameters.Get("percentiles"), ",") {
+			percentile, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				helper.ReturnError(w, r, http.StatusBadRequest, "Parameter percentiles needs to be a comma-separated list of numbers!")
+				return
+			}
+
+			query.Percentiles = append(query.Percentiles, percentile)
+		}
+	}
+
+	if parameters.Get("rate") != "" {
+		query.Rate, err = strconv.ParseBool(parameters.Get("rate"))
+		if err != nil {
+			helper.ReturnError(w, r, http.StatusBadRequest, "Parameter rate needs to be a boolean!")
+			return
+		}
+	}
+
+	result, err := db.AggregateHistory(db.GetStorage(), query)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidAggregateQuery) {
+			helper.ReturnError(w, r, http.StatusBadRequest, fmt.Sprintf("Bad parameters: %s", err))
+			return
+		}
+
+		logger.Error(fmt.Sprintf("Could not aggregate history of %s. Reason: %s", query.Target, err))
+		helper.ReturnError(w, r, 500, "Internal server error!")
+		return
+	}
+
+	writeJSON(w, r, result, "aggregated history")
+}
+
+// exportHistory streams the history of one or more monitors as CSV or NDJSON. It takes the query parameters target
+// (repeated or a comma-separated list of patterns), from and until (RFC 3339) and format (csv or ndjson).
+// Only the raw history is exported, history older than data.storage_time that is only kept as rollups is left out.
+func exportHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		helper.ReturnError(w, r, http.StatusMethodNotAllowed, "Only HTTP method GET is supported on /history/export.")
+		return
+	}
+
+	parameters := r.URL.Query()
+	query := export.Query{Until: time.Now()}
+
+	for _, value := range parameters["target"] {
+		for _, target := range strings.Split(value, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				query.Targets = append(query.Targets, target)
+			}
+		}
+	}
+
+	for _, target := range query.Targets {
+		if err := pubsub.ValidatePattern(target); err != nil {
+			helper.ReturnError(w, r, http.StatusBadRequest, fmt.Sprintf("Bad parameters: %s", err))
+			return
+		}
+	}
+
+	if !parseTimeRange(w, r, &query.From, &query.Until) {
+		return
+	}
+
+	format := export.FormatNDJSON
+	if parameters.Get("format") != "" {
+		var err error
+		format, err = export.ParseFormat(parameters.Get("format"))
+		if err != nil {
+			helper.ReturnError(w, r, http.StatusBadRequest, fmt.Sprintf("Bad parameters: %s", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="history.%s"`, format))
+
+	// The status has been sent with the first bytes of the export, so errors can only be logged.
+	if err := export.Export(w, db.GetStorage(), format, query); err != nil {
+		logger.Error(fmt.Sprintf("Could not export history. Reason: %s", err))
+	}
+}
+
+// backupHistory downloads a consistent snapshot of the database file. The snapshot is taken into a temporary
+// directory and deleted once it has been sent.
+func backupHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		helper.ReturnError(w, r, http.StatusMethodNotAllowed, "Only HTTP method GET is supported on /history/backup.")
+		return
+	}
+
+	directory, err := os.MkdirTemp("", "excubitor-backup-")
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not create directory for backup. Reason: %s", err))
+		helper.ReturnError(w, r, http.StatusInternalServerError, "Could not back up database!")
+		return
+	}
+
+	defer func() { _ = os.RemoveAll(directory) }()
+
+	now := time.Now()
+	name := db.BackupFileName(now)
+	file := filepath.Join(directory, name)
+
+	if err := db.Backup(db.GetStorage(), file); err != nil {
+		if errors.Is(err, db.ErrBackupNotSupported) {
+			helper.ReturnError(w, r, http.StatusNotImplemented, "The storage backend does not support backups.")
+			return
+		}
+
+		logger.Error(fmt.Sprintf("Could not back up database. Reason: %s", err))
+		helper.ReturnError(w, r, http.StatusInternalServerError, "Could not back up database!")
+		return
+	}
+
+	snapshot, err := os.Open(file)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not open backup. Reason: %s", err))
+		helper.ReturnError(w, r, http.StatusInternalServerError, "Could not back up database!")
+		return
+	}
+
+	defer func() { _ = snapshot.Close() }()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+
+	http.ServeContent(w, r, name, now, snapshot)
+}
+
+// parseTimeRange reads the query parameters from and until as RFC 3339 timestamps into from and until, which are left
+// as they are if the parameter isn't given. It answers with an error and returns false if a timestamp is invalid.
+func parseTimeRange(w http.ResponseWriter, r *http.Request, from *time.Time, until *time.Time) bool {
+	parameters := r.URL.Query()
+
+	for name, target := range map[string]*time.Time{"from": from, "until": until} {
+		if parameters.Get(name) == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, parameters.Get(name))
+		if err != nil {
+			helper.ReturnError(w, r, http.StatusBadRequest, fmt.Sprintf("Parameter %s is not a valid RFC 3339 timestamp!", name))
+			return false
+		}
+
+		*target = parsed
+	}
+
+	return true
+}
+
+// writeJSON answers with result encoded as JSON. The description names the result in the log if it can't be encoded.
+func writeJSON(w http.ResponseWriter, r *http.Request, result any, description string) {
+	jsonResult, err := json.Marshal(result)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not marshal %s. Reason: %s", description, err))
+		helper.ReturnError(w, r, 500, "Internal server error!")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(jsonResult)
+}
+
 func wsInit(w http.ResponseWriter, r *http.Request) {
 	conn, _, _, err := ws.UpgradeHTTP(r, w)
 	if err != nil {
@@ -2,14 +2,21 @@ package http_server
 
 import (
 	"encoding/json"
+	"fmt"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/helper"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -46,7 +53,7 @@ func TestInfo(t *testing.T) {
 	}
 
 	assert.Equal(t, 200, res.StatusCode)
-	assert.JSONEq(t, `{"authentication": { "method": "PAM" }, "modules": [ { "name": "TestModule", "version":"0.0.1", "components": [] } ] }`, string(body))
+	assert.JSONEq(t, `{"authentication": { "method": "PAM" }, "modules": [ { "name": "TestModule", "version":"0.0.1", "components": [] } ], "host": { "hostname": "test-host", "labels": { "env": "test" } } }`, string(body))
 }
 
 func TestInfoMethodNotAllowed(t *testing.T) {
@@ -117,3 +124,245 @@ func parseHTTPError(jsonInput []byte) helper.Error {
 
 	return *output
 }
+
+func TestSchemas(t *testing.T) {
+	broker := pubsub.NewBroker()
+	ctx.GetContext().RegisterBroker(broker)
+
+	type value struct {
+		Usage float64 `json:"usage"`
+	}
+
+	err := broker.RegisterSchema(pubsub.Schema{
+		Monitor: "Test.Usage",
+		Source:  "Test",
+		Version: 1,
+		Schema:  pubsub.SchemaFromValue(value{}),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := `{"monitor": "Test.Usage", "source": "Test", "content_type": "application/json", "version": 1, "schema": {"type": "object", "properties": {"usage": {"type": "number"}}}}`
+
+	for path, expectedBody := range map[string]string{
+		"/schemas":            "[" + expected + "]",
+		"/schemas/Test.Usage": expected,
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			schemas(w, req)
+
+			res := w.Result()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.JSONEq(t, expectedBody, string(body))
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/schemas/Test.Unknown", nil)
+	w := httptest.NewRecorder()
+
+	schemas(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+// eventStore keeps events in memory instead of the database.
+type eventStore struct {
+	events []events.Event
+}
+
+func (store *eventStore) AddEvent(event events.Event) error {
+	store.events = append(store.events, event)
+	return nil
+}
+
+func (store *eventStore) GetEvents(from time.Time, until time.Time) ([]events.Event, error) {
+	var result []events.Event
+	for _, event := range store.events {
+		if !event.Timestamp.Before(from) && !event.Timestamp.After(until) {
+			result = append(result, event)
+		}
+	}
+
+	return result, nil
+}
+
+func TestTimeline(t *testing.T) {
+	store := &eventStore{}
+	stream := events.Init(store, store)
+
+	now := time.Now().Truncate(time.Second)
+	for _, event := range []events.Event{
+		{Timestamp: now.Add(-2 * time.Hour), Type: events.TypeStarted, Severity: events.SeverityInfo, Source: "Excubitor"},
+		{Timestamp: now.Add(-time.Hour), Type: events.TypePluginCrashed, Severity: events.SeverityError, Source: "Plugins"},
+		{Timestamp: now.Add(-time.Minute), Type: events.TypeLoginFailed, Severity: events.SeverityWarning, Source: "HTTP"},
+	} {
+		require.NoError(t, stream.Emit(event))
+	}
+
+	for query, expected := range map[string][]string{
+		"":                      {events.TypeStarted, events.TypePluginCrashed, events.TypeLoginFailed},
+		"?type=Plugin.*":        {events.TypePluginCrashed},
+		"?min_severity=warning": {events.TypePluginCrashed, events.TypeLoginFailed},
+		"?from=" + url.QueryEscape(now.Add(-90*time.Minute).Format(time.RFC3339)) + "&until=" + url.QueryEscape(now.Add(-30*time.Minute).Format(time.RFC3339)): {events.TypePluginCrashed},
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			timeline(w, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+
+			res := w.Result()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			var result []events.Event
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+
+			var types []string
+			for _, event := range result {
+				types = append(types, event.Type)
+			}
+
+			assert.Equal(t, expected, types)
+		})
+	}
+
+	for _, query := range []string{"?from=yesterday", "?type=Plugin.%23.Crashed", "?min_severity=fatal"} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			timeline(w, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	logger = logging.GetLogger()
+	require.NoError(t, db.OpenDatabase())
+
+	target := fmt.Sprintf("Aggregate%d.CPU.Usage", time.Now().UnixNano())
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	require.NoError(t, db.GetWriter().AddHistoryEntries([]db.HistoryEntry{
+		{Timestamp: base.Add(10 * time.Second), Target: target, Content: `{"usage": 10}`},
+		{Timestamp: base.Add(20 * time.Second), Target: target, Content: `{"usage": 30}`},
+		{Timestamp: base.Add(70 * time.Second), Target: target, Content: `{"usage": 50}`},
+	}))
+
+	parameters := url.Values{
+		"target":      {target},
+		"field":       {"usage"},
+		"bucket":      {"1m"},
+		"from":        {base.Format(time.RFC3339)},
+		"until":       {base.Add(2 * time.Minute).Format(time.RFC3339)},
+		"percentiles": {"50,100"},
+	}
+
+	w := httptest.NewRecorder()
+	aggregate(w, httptest.NewRequest(http.MethodGet, "/history/aggregate?"+parameters.Encode(), nil))
+
+	res := w.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var result []db.AggregateBucket
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+	require.Equal(t, 2, len(result))
+
+	assert.Equal(t, 2, result[0].Count)
+	assert.Equal(t, 10.0, result[0].Min)
+	assert.Equal(t, 30.0, result[0].Max)
+	assert.Equal(t, 20.0, result[0].Avg)
+	assert.Equal(t, map[string]float64{"50": 20, "100": 30}, result[0].Percentiles)
+	assert.Equal(t, 1, result[1].Count)
+
+	for _, query := range []string{
+		"?bucket=1m",
+		"?target=CPU.Usage",
+		"?target=CPU.Usage&bucket=1m&percentiles=median",
+		"?target=CPU.Usage&bucket=1m&percentiles=120",
+		"?target=CPU.Usage&bucket=1m&rate=sometimes",
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			aggregate(w, httptest.NewRequest(http.MethodGet, "/history/aggregate"+query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	logger = logging.GetLogger()
+	require.NoError(t, db.OpenDatabase())
+
+	target := fmt.Sprintf("Export%d.CPU.Usage", time.Now().UnixNano())
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, db.GetWriter().AddHistoryEntries([]db.HistoryEntry{
+		{Timestamp: base, Target: target, Content: `{"usage": 10}`},
+		{Timestamp: base.Add(time.Second), Target: target, Content: `{"usage": 30}`},
+	}))
+
+	parameters := url.Values{
+		"target": {target},
+		"from":   {base.Format(time.RFC3339)},
+		"format": {"csv"},
+	}
+
+	w := httptest.NewRecorder()
+	exportHistory(w, httptest.NewRequest(http.MethodGet, "/history/export?"+parameters.Encode(), nil))
+
+	res := w.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.Equal(t, "timestamp,target,host,labels,usage", lines[0])
+	assert.True(t, strings.HasSuffix(lines[2], ",30"))
+
+	for _, query := range []string{
+		"?format=parquet",
+		"?target=CPU.%23.Usage",
+		"?from=yesterday",
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			exportHistory(w, httptest.NewRequest(http.MethodGet, "/history/export"+query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestBackupHistory(t *testing.T) {
+	logger = logging.GetLogger()
+	require.NoError(t, db.OpenDatabase())
+
+	w := httptest.NewRecorder()
+	backupHistory(w, httptest.NewRequest(http.MethodGet, "/history/backup", nil))
+
+	res := w.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/vnd.sqlite3", res.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(res.Header.Get("Content-Disposition"), `attachment; filename="history-`))
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "SQLite format 3\x00"))
+
+	w = httptest.NewRecorder()
+	backupHistory(w, httptest.NewRequest(http.MethodPost, "/history/backup", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+}
@@ -1,12 +1,14 @@
 package models
 
 import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
 )
 
 type InfoResponse struct {
-	Authentication Authentication   `json:"authentication"`
-	Modules        []modules.Module `json:"modules"`
+	Authentication Authentication    `json:"authentication"`
+	Modules        []modules.Module  `json:"modules"`
+	Host           identity.Identity `json:"host"`
 }
 
 type Authentication struct {
@@ -19,5 +21,6 @@ func NewInfoResponse(authenticationMethod string, modules []modules.Module) Info
 			Method: authenticationMethod,
 		},
 		Modules: modules,
+		Host:    identity.Get(),
 	}
 }
@@ -27,6 +27,21 @@ func Serve(w http.ResponseWriter, r *http.Request) {
 	case length == 1 && path[0] == "info" && r.Method == "GET":
 		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> info endpoint", remoteAddress, r.URL.Path, remoteAddress))
 		handler = http.HandlerFunc(info)
+	case (length == 1 || length == 2) && path[0] == "schemas":
+		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> schemas endpoint", remoteAddress, r.URL.Path, remoteAddress))
+		handler = http.HandlerFunc(schemas)
+	case length == 1 && path[0] == "events":
+		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> events endpoint, auth pending", remoteAddress, r.URL.Path, remoteAddress))
+		handler = bearerAuth(http.HandlerFunc(timeline))
+	case length == 2 && path[0] == "history" && path[1] == "aggregate":
+		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> history aggregation endpoint, auth pending", remoteAddress, r.URL.Path, remoteAddress))
+		handler = bearerAuth(http.HandlerFunc(aggregate))
+	case length == 2 && path[0] == "history" && path[1] == "export":
+		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> history export endpoint, auth pending", remoteAddress, r.URL.Path, remoteAddress))
+		handler = bearerAuth(http.HandlerFunc(exportHistory))
+	case length == 2 && path[0] == "history" && path[1] == "backup":
+		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> history backup endpoint, auth pending", remoteAddress, r.URL.Path, remoteAddress))
+		handler = bearerAuth(http.HandlerFunc(backupHistory))
 	case length == 1 && path[0] == "ws":
 		logger.Trace(fmt.Sprintf("[%s]: %s - %s -> ws endpoint, auth pending", remoteAddress, r.URL.Path, remoteAddress))
 		handler = queryAuth(http.HandlerFunc(wsInit))
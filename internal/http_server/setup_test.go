@@ -1,7 +1,9 @@
 package http_server
 
 import (
+	"errors"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/knadh/koanf/providers/confmap"
 	"os"
@@ -10,15 +12,26 @@ import (
 
 func TestMain(m *testing.M) {
 	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
-		"logging.log_level":         "TRACE",
-		"logging.method":            "CONSOLE",
-		"http.host":                 "0.0.0.0",
-		"http.port":                 "8080",
-		"http.cors.allowed_origins": []string{"*"},
-		"http.cors.allowed_methods": []string{"GET", "POST"},
-		"http.cors.allowed_headers": []string{"Origin", "Content-Type", "Authorization"},
-		"data.module_clock":         "5s",
-		"data.storage_time":         "30d",
+		"logging.log_level":                "TRACE",
+		"logging.method":                   "CONSOLE",
+		"http.host":                        "0.0.0.0",
+		"http.port":                        "8080",
+		"http.cors.allowed_origins":        []string{"*"},
+		"http.cors.allowed_methods":        []string{"GET", "POST"},
+		"http.cors.allowed_headers":        []string{"Origin", "Content-Type", "Authorization"},
+		"data.module_clock":                "5s",
+		"data.storage_time":                "720h",
+		"data.purge_cycle":                 "1h",
+		"data.database_file":               "history_test.db",
+		"data.events_storage_time":         "720h",
+		"data.max_database_size":           "0",
+		"data.backup.cycle":                "0",
+		"data.backup.keep":                 1,
+		"data.rollups.minute_storage_time": "720h",
+		"data.rollups.hour_storage_time":   "8760h",
+		"data.rollups.compaction_cycle":    "1h",
+		"main.hostname":                    "test-host",
+		"main.labels":                      map[string]string{"env": "test"},
 	}, "."), nil)
 	if err != nil {
 		panic(err)
@@ -29,5 +42,20 @@ func TestMain(m *testing.M) {
 	}
 
 	code := m.Run()
+
+	if db.GetWriter() != nil {
+		if err := db.Close(); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, file := range []string{"history_test.db", "history_test.db-wal", "history_test.db-shm"} {
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			if err := os.Remove(file); err != nil {
+				panic(err)
+			}
+		}
+	}
+
 	os.Exit(code)
 }
@@ -2,6 +2,8 @@ package websocket
 
 import (
 	"encoding/json"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"time"
 )
 
@@ -20,11 +22,67 @@ type Message struct {
 	OpCode OpCode        `json:"op"`
 	Target TargetAddress `json:"target"`
 	Value  string        `json:"value,omitempty"`
+	Meta   *Metadata     `json:"meta,omitempty"`
+}
+
+// Metadata is the envelope of REPLY messages carrying a value published on a monitor.
+type Metadata struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	Source        string            `json:"source"`
+	ContentType   string            `json:"content_type"`
+	SchemaVersion int               `json:"schema_version"`
+	Host          string            `json:"host"`
+	Labels        map[string]string `json:"labels"`
+	// Replayed is set on values replayed from the history when subscribing with since or last.
+	Replayed bool `json:"replayed,omitempty"`
 }
 
 // NewMessage returns a Message with chosen OpCode, TargetAddress and value.
 func NewMessage(opcode OpCode, target TargetAddress, value string) Message {
-	return Message{opcode, target, value}
+	return Message{OpCode: opcode, Target: target, Value: value}
+}
+
+// NewReply returns a REPLY Message carrying a pubsub message together with its metadata.
+func NewReply(message *pubsub.Message) Message {
+	return Message{
+		OpCode: REPLY,
+		Target: TargetAddress(message.GetMonitor()),
+		Value:  message.GetMessageBody(),
+		Meta: &Metadata{
+			Timestamp:     message.GetTimestamp(),
+			Source:        message.GetSource(),
+			ContentType:   message.GetContentType(),
+			SchemaVersion: message.GetSchemaVersion(),
+			Host:          message.GetHost(),
+			Labels:        message.GetLabels(),
+		},
+	}
+}
+
+// NewHistoryReply returns a REPLY Message carrying a value replayed from the history of a monitor.
+// The schema registered for the monitor, if any, completes the metadata that is not stored in the history.
+func NewHistoryReply(entry db.HistoryMessage, schema pubsub.Schema, hasSchema bool) Message {
+	meta := &Metadata{
+		Timestamp:   entry.Timestamp,
+		Source:      pubsub.SourceOf(entry.Message.Target),
+		ContentType: pubsub.ContentTypeJSON,
+		Host:        entry.Host,
+		Labels:      entry.Labels,
+		Replayed:    true,
+	}
+
+	if hasSchema {
+		meta.Source = schema.Source
+		meta.ContentType = schema.ContentType
+		meta.SchemaVersion = schema.Version
+	}
+
+	return Message{
+		OpCode: REPLY,
+		Target: TargetAddress(entry.Message.Target),
+		Value:  entry.Message.Value,
+		Meta:   meta,
+	}
 }
 
 // Bytes converts a Message to JSON and returns it as a byte slice.
@@ -37,9 +95,38 @@ func (msg Message) Bytes() ([]byte, error) {
 	return jsonData, nil
 }
 
+// SubscribeRequestParameters is a model for parameters that can be set with SUB requests.
+// If Since or Last is set, the stored history of the monitor is replayed before live delivery starts.
+type SubscribeRequestParameters struct {
+	Since time.Time `json:"since,omitempty"`
+	Last  int       `json:"last,omitempty"`
+}
+
+// GetRequestParameters is a model for parameters that can be set with GET requests.
+// MaxAge is a duration as read by parseDuration.
+type GetRequestParameters struct {
+	MaxAge interface{} `json:"max_age,omitempty"`
+}
+
+// EventHistoryRequestParameters is a model for parameters that can be set with EHIST requests.
+type EventHistoryRequestParameters struct {
+	From        time.Time `json:"from,omitempty"`
+	Until       time.Time `json:"until,omitempty"`
+	MinSeverity string    `json:"min_severity,omitempty"`
+}
+
 // HistoryRequestParameters is a model for paramters that can be set with HIST requests.
+// If Bucket is set, the request is answered with the aggregation of Field into buckets of that width instead of the
+// history entries. If MaxPoints is set, the history is downsampled to that many entries by the chart of Field and
+// MaxDensity is ignored.
+// Bucket is a duration as read by parseDuration, while numbers of MaxDensity are nanoseconds.
 type HistoryRequestParameters struct {
-	From       time.Time   `json:"from,omitempty"`
-	Until      time.Time   `json:"until,omitempty"`
-	MaxDensity interface{} `json:"max_density,omitempty"`
+	From        time.Time   `json:"from,omitempty"`
+	Until       time.Time   `json:"until,omitempty"`
+	MaxDensity  interface{} `json:"max_density,omitempty"`
+	MaxPoints   int         `json:"max_points,omitempty"`
+	Field       string      `json:"field,omitempty"`
+	Bucket      interface{} `json:"bucket,omitempty"`
+	Percentiles []float64   `json:"percentiles,omitempty"`
+	Rate        bool        `json:"rate,omitempty"`
 }
@@ -10,4 +10,10 @@ const (
 	HIST  OpCode = "HIST"
 	REPLY OpCode = "REPLY"
 	ERR   OpCode = "ERR"
+
+	// Operations on the event stream. EVENT messages carry events to clients subscribed with ESUB.
+	ESUB   OpCode = "ESUB"
+	EUNSUB OpCode = "EUNSUB"
+	EHIST  OpCode = "EHIST"
+	EVENT  OpCode = "EVENT"
 )
@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/recorder"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// replaySyncTimeout is the maximum time to wait for the recorder to write pending messages before replaying the history.
+const replaySyncTimeout = 2 * time.Second
+
+// replayGate holds back the live messages of monitors whose history is being replayed on a connection,
+// so that they can be sent after the replayed ones.
+type replayGate struct {
+	held map[string][]*pubsub.Message
+	lock sync.Mutex
+}
+
+func newReplayGate() *replayGate {
+	return &replayGate{held: map[string][]*pubsub.Message{}}
+}
+
+// open starts holding back the live messages of a monitor.
+func (gate *replayGate) open(monitor string) {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	gate.held[monitor] = []*pubsub.Message{}
+}
+
+// hold holds back a live message if the history of its monitor is being replayed and reports whether it did so.
+func (gate *replayGate) hold(message *pubsub.Message) bool {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	held, ok := gate.held[message.GetMonitor()]
+	if !ok {
+		return false
+	}
+
+	gate.held[message.GetMonitor()] = append(held, message)
+	return true
+}
+
+// release sends the replayed history followed by the held back live messages newer than the last replayed entry
+// and stops holding back messages of the monitor. Live messages arriving meanwhile wait until release is done.
+func (gate *replayGate) release(conn net.Conn, monitor string, history db.History, schema pubsub.Schema, hasSchema bool) error {
+	gate.lock.Lock()
+	defer gate.lock.Unlock()
+
+	held := gate.held[monitor]
+	delete(gate.held, monitor)
+
+	var boundary time.Time
+	for _, entry := range history {
+		if err := sendMessage(conn, NewHistoryReply(entry, schema, hasSchema)); err != nil {
+			return err
+		}
+
+		boundary = entry.Timestamp
+	}
+
+	for _, message := range held {
+		// Messages published right before the history was read are part of both.
+		if !message.GetTimestamp().After(boundary) {
+			continue
+		}
+
+		if err := sendMessage(conn, NewReply(message)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleSUB handles websocket requests with the SUB OpCode.
+// If since or last is given, the stored history of the monitor is replayed before live delivery starts,
+// without gaps or duplicates between both.
+func handleSUB(conn net.Conn, broker pubsub.Broker, subscriber *pubsub.Subscriber, gate *replayGate, content *Message) error {
+	clientAddress := conn.RemoteAddr()
+	monitor := string(content.Target)
+
+	params := &SubscribeRequestParameters{}
+
+	if content.Value != "" {
+		if err := json.Unmarshal([]byte(content.Value), params); err != nil || params.Last < 0 {
+			logger.Error(fmt.Sprintf("Could not decode the SUB request parameters from %s. Reason: %v", clientAddress, err))
+
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Bad parameters!")); err != nil {
+				return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+			}
+
+			return nil
+		}
+	}
+
+	replay := !params.Since.IsZero() || params.Last > 0
+
+	if replay && pubsub.IsPattern(monitor) {
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Replay is not supported for monitor patterns!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return nil
+	}
+
+	if replay {
+		gate.open(monitor)
+	}
+
+	if err := broker.Subscribe(subscriber, monitor); err != nil {
+		logger.Warn(fmt.Sprintf("Client %s tried to subscribe to invalid monitor %s. Reason: %s", clientAddress, content.Target, err))
+
+		if replay {
+			_ = gate.release(conn, monitor, nil, pubsub.Schema{}, false)
+		}
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Invalid monitor!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return nil
+	}
+
+	logger.Trace(fmt.Sprintf("Client %s subscribed to monitor %s.", clientAddress, content.Target))
+
+	if !replay {
+		return nil
+	}
+
+	if active := recorder.GetRecorder(); active != nil {
+		if err := active.Sync(replaySyncTimeout); err != nil {
+			logger.Warn(fmt.Sprintf("Replaying history of %s for %s without the latest messages. Reason: %s", content.Target, clientAddress, err))
+		}
+	}
+
+	var history db.History
+	var err error
+
+	if params.Since.IsZero() {
+		history, err = db.GetStorage().GetLatestHistoryEntries(monitor, time.Now(), params.Last)
+	} else {
+		history, err = db.GetStorage().GetHistoryEntriesFromUntil(monitor, params.Since, time.Now())
+	}
+
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when retrieving history data of target %s: %s", content.Target, err))
+
+		if err := gate.release(conn, monitor, nil, pubsub.Schema{}, false); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Internal server error!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	if params.Last > 0 && len(history) > params.Last {
+		history = history[len(history)-params.Last:]
+	}
+
+	logger.Trace(fmt.Sprintf("Replaying %d messages of %s for %s.", len(history), content.Target, clientAddress))
+
+	schema, hasSchema := broker.GetSchema(monitor)
+
+	if err := gate.release(conn, monitor, history, schema, hasSchema); err != nil {
+		return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+	}
+
+	return nil
+}
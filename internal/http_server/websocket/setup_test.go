@@ -5,6 +5,7 @@ import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/knadh/koanf/providers/confmap"
@@ -14,11 +15,18 @@ import (
 
 func TestMain(m *testing.M) {
 	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
-		"logging.log_level":  "TRACE",
-		"logging.method":     "CONSOLE",
-		"data.storage_time":  "720h",
-		"data.purge_cycle":   "1h",
-		"data.database_file": "history_test.db",
+		"logging.log_level":                "TRACE",
+		"logging.method":                   "CONSOLE",
+		"data.storage_time":                "720h",
+		"data.purge_cycle":                 "1h",
+		"data.rollups.minute_storage_time": "720h",
+		"data.rollups.hour_storage_time":   "8760h",
+		"data.rollups.compaction_cycle":    "1h",
+		"data.events_storage_time":         "720h",
+		"data.max_database_size":           "0",
+		"data.backup.cycle":                "0",
+		"data.backup.keep":                 1,
+		"data.database_file":               "history_test.db",
 	}, "."), nil)
 	if err != nil {
 		panic(err)
@@ -28,17 +36,24 @@ func TestMain(m *testing.M) {
 		panic(err)
 	}
 
-	if err := db.InitDatabase(); err != nil {
+	if err := db.OpenDatabase(); err != nil {
 		panic(err)
 	}
 
 	ctx.GetContext().RegisterBroker(pubsub.NewBroker())
+	events.Init(db.GetWriter(), db.GetReader())
 
 	code := m.Run()
 
-	if _, err := os.Stat("history_test.db"); !errors.Is(err, os.ErrNotExist) {
-		if err := os.Remove("history_test.db"); err != nil {
-			panic(err)
+	if err := db.Close(); err != nil {
+		panic(err)
+	}
+
+	for _, file := range []string{"history_test.db", "history_test.db-wal", "history_test.db-shm"} {
+		if _, err := os.Stat(file); !errors.Is(err, os.ErrNotExist) {
+			if err := os.Remove(file); err != nil {
+				panic(err)
+			}
 		}
 	}
 
@@ -6,25 +6,54 @@ import (
 	"fmt"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var logger logging.Logger
+var loggerOnce sync.Once
 
 var FatalWebsocketError error = errors.New("fatal websocket error")
 
+// connections counts the currently open websocket connections.
+var connections atomic.Int64
+
+// writeLocks holds a *sync.Mutex per connection, as the request loop and the listeners of the subscribers of a
+// connection send messages concurrently and the frames of a message must not be interleaved with others.
+var writeLocks sync.Map
+
+// GetConnectionCount returns the number of currently open websocket connections.
+func GetConnectionCount() int64 {
+	return connections.Load()
+}
+
 // HandleWebsocket handles the websocket connections.
 func HandleWebsocket(conn net.Conn) {
-	var err error
 	clientAddress := conn.RemoteAddr()
 
-	logger = logging.GetLogger()
+	loggerOnce.Do(func() {
+		logger = logging.GetLogger()
+	})
+
+	connections.Add(1)
+	defer connections.Add(-1)
+
+	// The listeners are done sending once the subscribers have been destructed and the connection is closed. Pending
+	// requests are dropped once done is closed.
+	done := make(chan struct{})
+	var listeners sync.WaitGroup
+	defer func() {
+		close(done)
+		listeners.Wait()
+		writeLocks.Delete(conn)
+	}()
 
 	defer func(conn net.Conn) {
 		logger.Debug(fmt.Sprintf("Closing connection from %s", clientAddress))
@@ -44,13 +73,54 @@ func HandleWebsocket(conn net.Conn) {
 		subscriber.Destruct()
 	}(subscriber)
 
-	go subscriber.Listen(func(m *pubsub.Message) {
-		logger.Trace(fmt.Sprintf("Sending message from %s to connection from %s", m.GetMonitor(), clientAddress))
+	gate := newReplayGate()
 
-		if err = sendMessage(conn, NewMessage(REPLY, TargetAddress(m.GetMonitor()), m.GetMessageBody())); err != nil {
-			return
+	listeners.Add(1)
+	go func() {
+		defer listeners.Done()
+
+		subscriber.Listen(func(m *pubsub.Message) {
+			if gate.hold(m) {
+				return
+			}
+
+			logger.Trace(fmt.Sprintf("Sending message from %s to connection from %s", m.GetMonitor(), clientAddress))
+
+			if err := sendMessage(conn, NewReply(m)); err != nil {
+				return
+			}
+		})
+
+		// Closing the connection makes the read loop below return as well.
+		if subscriber.IsDisconnected() {
+			logger.Warn(fmt.Sprintf("Connection from %s does not keep up with the published messages. Aborting connection...", clientAddress))
+			_ = conn.Close()
 		}
-	})
+	}()
+
+	// Set up event subscriber if the event stream is available
+
+	var eventBroker pubsub.Broker
+	var eventSubscriber *pubsub.Subscriber
+
+	if stream := events.GetStream(); stream != nil {
+		eventBroker = stream.GetBroker()
+		eventSubscriber = eventBroker.AddSubscriber()
+		defer eventSubscriber.Destruct()
+
+		listeners.Add(1)
+		go func() {
+			defer listeners.Done()
+
+			eventSubscriber.Listen(func(m *pubsub.Message) {
+				logger.Trace(fmt.Sprintf("Sending %s event to connection from %s", m.GetMonitor(), clientAddress))
+
+				if err := sendMessage(conn, NewMessage(EVENT, TargetAddress(m.GetMonitor()), m.GetMessageBody())); err != nil {
+					return
+				}
+			})
+		}()
+	}
 
 	for {
 		// Receiving message
@@ -78,7 +148,7 @@ func HandleWebsocket(conn net.Conn) {
 
 		content := &Message{}
 
-		if err = json.Unmarshal(msg, content); err != nil {
+		if err := json.Unmarshal(msg, content); err != nil {
 			logger.Warn(fmt.Sprintf("Can't decode message from %s with reason %s! Dropping request...", clientAddress, err))
 
 			if err := sendMessage(conn, NewMessage(ERR, GetEmptyTarget(), "Bad Request!")); err != nil {
@@ -90,64 +160,176 @@ func HandleWebsocket(conn net.Conn) {
 
 		switch content.OpCode {
 		case GET:
-			if err := handleGET(conn, content); err != nil {
-				if errors.Is(FatalWebsocketError, err) {
+			if err := handleGET(conn, content, done, &listeners); err != nil {
+				if errors.Is(err, FatalWebsocketError) {
 					logger.Error(fmt.Sprintf("A fatal websocket error occurred. Forcefully aborting connection to %s!", clientAddress))
 					return
 				}
 			}
 		case SUB:
-			broker.Subscribe(subscriber, string(content.Target))
-			logger.Trace(fmt.Sprintf("Client %s subscribed to monitor %s.", clientAddress, content.Target))
+			if err := handleSUB(conn, broker, subscriber, gate, content); err != nil {
+				if errors.Is(err, FatalWebsocketError) {
+					logger.Error(fmt.Sprintf("A fatal websocket error occurred. Forcefully aborting connection to %s!", clientAddress))
+					return
+				}
+			}
 		case UNSUB:
 			broker.Unsubscribe(subscriber, string(content.Target))
 			logger.Trace(fmt.Sprintf("Client %s unsubscribed from monitor %s.", clientAddress, content.Target))
 		case HIST:
 			if err := handleHIST(conn, content); err != nil {
-				if errors.Is(FatalWebsocketError, err) {
+				if errors.Is(err, FatalWebsocketError) {
 					logger.Error(fmt.Sprintf("A fatal websocket error occurred. Forcefully aborting connection to %s!", clientAddress))
 					return
 				}
 			}
-		case REPLY:
-			if err = sendMessage(conn, NewMessage(ERR, content.Target, "Clients may not send messages of the type REPLY!")); err != nil {
+		case ESUB, EUNSUB:
+			if eventBroker == nil {
+				if err := sendMessage(conn, NewMessage(ERR, content.Target, "Events are not available!")); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			if content.OpCode == EUNSUB {
+				eventBroker.Unsubscribe(eventSubscriber, string(content.Target))
+				logger.Trace(fmt.Sprintf("Client %s unsubscribed from events %s.", clientAddress, content.Target))
+				continue
+			}
+
+			if err := eventBroker.Subscribe(eventSubscriber, string(content.Target)); err != nil {
+				logger.Warn(fmt.Sprintf("Client %s tried to subscribe to invalid event type %s. Reason: %s", clientAddress, content.Target, err))
+
+				if err := sendMessage(conn, NewMessage(ERR, content.Target, "Invalid event type!")); err != nil {
+					return
+				}
+
+				continue
+			}
+
+			logger.Trace(fmt.Sprintf("Client %s subscribed to events %s.", clientAddress, content.Target))
+		case EHIST:
+			if err := handleEHIST(conn, content); err != nil {
+				if errors.Is(err, FatalWebsocketError) {
+					logger.Error(fmt.Sprintf("A fatal websocket error occurred. Forcefully aborting connection to %s!", clientAddress))
+					return
+				}
+			}
+		case REPLY, EVENT:
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, fmt.Sprintf("Clients may not send messages of the type %s!", content.OpCode))); err != nil {
 				return
 			}
 		case ERR:
-			if err = sendMessage(conn, NewMessage(ERR, content.Target, "Clients may not send messages of the type ERR!")); err != nil {
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Clients may not send messages of the type ERR!")); err != nil {
 				return
 			}
 		default:
-			if err = sendMessage(conn, NewMessage(ERR, content.Target, fmt.Sprintf("Unsupported Operation %s!", content.OpCode))); err != nil {
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, fmt.Sprintf("Unsupported Operation %s!", content.OpCode))); err != nil {
 				return
 			}
 		}
 	}
 }
 
-// handleGET handles websocket request with the GET OpCode
-func handleGET(conn net.Conn, content *Message) error {
+// getTimeout is the longest time a GET request waits for the next message on its monitor. It is a variable, so that
+// tests can shorten it.
+var getTimeout = time.Minute
+
+// handleGET handles websocket request with the GET OpCode.
+// It answers with the last value retained by the broker unless that value is older than the requested max_age,
+// in which case it waits for the next message published on the monitor, but no longer than getTimeout.
+// A pending request is added to pending and dropped once done is closed, so that it isn't answered on a closed
+// connection.
+func handleGET(conn net.Conn, content *Message, done <-chan struct{}, pending *sync.WaitGroup) error {
+	clientAddress := conn.RemoteAddr()
 	broker := ctx.GetContext().GetBroker()
+
+	params := &GetRequestParameters{}
+
+	if content.Value != "" {
+		if err := json.Unmarshal([]byte(content.Value), params); err != nil {
+			logger.Error(fmt.Sprintf("Could not decode the GET request parameters from %s. Reason: %s", clientAddress, err))
+
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Bad parameters!")); err != nil {
+				return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+			}
+
+			return err
+		}
+	}
+
+	maxAge, err := parseDuration(params.MaxAge)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not parse max_age of GET request from %s. Reason: %s", clientAddress, err))
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Bad parameters!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	if m, ok := broker.GetRetained(string(content.Target)); ok && (maxAge == 0 || time.Since(m.GetTimestamp()) <= maxAge) {
+		logger.Trace(fmt.Sprintf("Sending retained message from %s to connection from %s", m.GetMonitor(), clientAddress))
+
+		if err := sendMessage(conn, NewReply(m)); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return nil
+	}
+
 	temporarySubscriber := broker.AddSubscriber()
+	replies := make(chan Message, 1)
 
-	var receiveOnce sync.Once
+	// reply hands over the reply to the request unless there is one already, either the next message or the error after
+	// getTimeout.
+	reply := func(m Message) {
+		select {
+		case replies <- m:
+		default:
+		}
+	}
 
 	logger.Trace(fmt.Sprintf("Added temporary subscriber to fulfill GET request from %s on monitor %s.", conn.RemoteAddr(), content.Target))
 
+	timeout := time.AfterFunc(getTimeout, func() {
+		logger.Debug(fmt.Sprintf("No message has been published on %s within %s for GET request from %s.", content.Target, getTimeout, clientAddress))
+		reply(NewMessage(ERR, content.Target, "No current value!"))
+	})
+
 	go temporarySubscriber.Listen(func(m *pubsub.Message) {
-		receiveOnce.Do(func() {
-			logger.Trace(fmt.Sprintf("Sending single message from %s to connection from %s", m.GetMonitor(), conn.RemoteAddr()))
+		logger.Trace(fmt.Sprintf("Sending single message from %s to connection from %s", m.GetMonitor(), conn.RemoteAddr()))
+		reply(NewReply(m))
+	})
 
-			broker.Unsubscribe(temporarySubscriber, string(content.Target))
-			defer temporarySubscriber.Destruct()
+	if err := broker.Subscribe(temporarySubscriber, string(content.Target)); err != nil {
+		timeout.Stop()
+		temporarySubscriber.Destruct()
 
-			if err := sendMessage(conn, NewMessage(REPLY, TargetAddress(m.GetMonitor()), m.GetMessageBody())); err != nil {
-				return
-			}
-		})
-	})
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Invalid monitor!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
 
-	broker.Subscribe(temporarySubscriber, string(content.Target))
+		return err
+	}
+
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+
+		select {
+		case m := <-replies:
+			_ = sendMessage(conn, m)
+		case <-done:
+			logger.Trace(fmt.Sprintf("Dropping GET request from %s on monitor %s as the connection has been closed.", clientAddress, content.Target))
+		}
+
+		timeout.Stop()
+		broker.Unsubscribe(temporarySubscriber, string(content.Target))
+		temporarySubscriber.Destruct()
+	}()
 
 	return nil
 }
@@ -178,46 +360,48 @@ func handleHIST(conn net.Conn, content *Message) error {
 
 	logger.Trace(fmt.Sprintf("Parsed HistoryRequestParameters { From: %s, Until: %s, MaxDensity: %s } from %s", params.From, params.Until, params.MaxDensity, clientAddress))
 
+	if params.Bucket != nil {
+		return handleAggregate(conn, content, params)
+	}
+
 	var history db.History
-	reader := db.GetReader()
+	var err error
 
-	if params.MaxDensity != "" {
+	// The reader picks the raw history or a rollup tier depending on the range and the requested density.
+	if params.MaxPoints != 0 {
+		history, err = db.GetHistoryDownsampled(db.GetStorage(), string(content.Target), params.From, params.Until, params.Field, params.MaxPoints)
+	} else {
+		// Numbers of max_density are nanoseconds, as it took encoded time.Duration values before other durations were
+		// introduced.
 		var maxDensity time.Duration
 		switch duration := params.MaxDensity.(type) {
 		case float64:
 			maxDensity = time.Duration(duration)
 		case string:
-			var err error
-			maxDensity, err = time.ParseDuration(duration)
-			if err != nil {
-				return err
+			if duration != "" {
+				maxDensity, err = time.ParseDuration(duration)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
-		var err error
-		history, err = reader.GetHistoryEntriesFromUntilThinned(string(content.Target), params.From, params.Until, maxDensity)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Error when retrieving history data of target %s: %s", content.Target, err.Error()))
-
-			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Internal server error!")); err != nil {
-				return fmt.Errorf("%w: %s", FatalWebsocketError, err)
-			}
+		history, err = db.GetHistory(db.GetStorage(), string(content.Target), params.From, params.Until, maxDensity)
+	}
 
-			return err
+	if err != nil {
+		reply := "Internal server error!"
+		if errors.Is(err, db.ErrInvalidMaxPoints) {
+			reply = "Bad parameters!"
 		}
 
-	} else {
-		var err error
-		history, err = reader.GetHistoryEntriesFromUntil(string(content.Target), params.From, params.Until)
-		if err != nil {
-			logger.Error(fmt.Sprintf("Error when retrieving history data of target %s: %s", content.Target, err.Error()))
-
-			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Internal server error!")); err != nil {
-				return fmt.Errorf("%w: %s", FatalWebsocketError, err)
-			}
+		logger.Error(fmt.Sprintf("Error when retrieving history data of target %s: %s", content.Target, err.Error()))
 
-			return err
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, reply)); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
 		}
+
+		return err
 	}
 
 	historyJson, err := json.Marshal(history)
@@ -241,6 +425,147 @@ func handleHIST(conn net.Conn, content *Message) error {
 	return nil
 }
 
+// handleAggregate answers HIST requests with a bucket width with the aggregation of the requested field.
+func handleAggregate(conn net.Conn, content *Message, params *HistoryRequestParameters) error {
+	clientAddress := conn.RemoteAddr()
+
+	bucket, err := parseDuration(params.Bucket)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not decode the bucket width from %s. Reason: %s", clientAddress, err))
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Bad parameters!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	buckets, err := db.AggregateHistory(db.GetStorage(), db.AggregateQuery{
+		Target:      string(content.Target),
+		Field:       params.Field,
+		From:        params.From,
+		Until:       params.Until,
+		Bucket:      bucket,
+		Percentiles: params.Percentiles,
+		Rate:        params.Rate,
+	})
+	if err != nil {
+		reply := "Internal server error!"
+		if errors.Is(err, db.ErrInvalidAggregateQuery) {
+			reply = "Bad parameters!"
+		}
+
+		logger.Error(fmt.Sprintf("Error when aggregating history data of target %s for %s: %s", content.Target, clientAddress, err))
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, reply)); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	bucketsJson, err := json.Marshal(buckets)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when marshalling aggregated history data of target %s: %s", content.Target, err.Error()))
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Internal server error!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	logger.Trace(fmt.Sprintf("Aggregated history of target %s for %s.", content.Target, clientAddress))
+
+	if err = sendMessage(conn, NewMessage(REPLY, content.Target, string(bucketsJson))); err != nil {
+		return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+	}
+
+	return nil
+}
+
+// handleEHIST handles websocket requests with the EHIST OpCode.
+// It answers with the timeline of events whose type matches the target, which defaults to all events.
+func handleEHIST(conn net.Conn, content *Message) error {
+	clientAddress := conn.RemoteAddr()
+
+	stream := events.GetStream()
+	if stream == nil {
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Events are not available!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return nil
+	}
+
+	params := &EventHistoryRequestParameters{}
+
+	if content.Value != "" {
+		if err := json.Unmarshal([]byte(content.Value), params); err != nil {
+			logger.Error(fmt.Sprintf("Could not decode the event history request parameters from %s. Reason: %s", clientAddress, err))
+
+			if err := sendMessage(conn, NewMessage(ERR, content.Target, "Bad parameters!")); err != nil {
+				return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+			}
+
+			return err
+		}
+	}
+
+	timeline, err := stream.Query(events.Query{
+		From:        params.From,
+		Until:       params.Until,
+		Pattern:     string(content.Target),
+		MinSeverity: events.Severity(params.MinSeverity),
+	})
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when retrieving events %s for %s: %s", content.Target, clientAddress, err))
+
+		reply := "Internal server error!"
+		if errors.Is(err, pubsub.ErrInvalidPattern) || errors.Is(err, events.ErrInvalidSeverity) {
+			reply = "Bad parameters!"
+		}
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, reply)); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	timelineJSON, err := json.Marshal(timeline)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when marshalling events %s: %s", content.Target, err))
+
+		if err := sendMessage(conn, NewMessage(ERR, content.Target, "Internal server error!")); err != nil {
+			return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+		}
+
+		return err
+	}
+
+	if err = sendMessage(conn, NewMessage(REPLY, content.Target, string(timelineJSON))); err != nil {
+		return fmt.Errorf("%w: %s", FatalWebsocketError, err)
+	}
+
+	return nil
+}
+
+// parseDuration parses the durations of request parameters, which are given either as a number of seconds or as a
+// duration string like "30s". A nil value results in a zero duration.
+func parseDuration(value interface{}) (time.Duration, error) {
+	switch duration := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return time.Duration(duration * float64(time.Second)), nil
+	case string:
+		return time.ParseDuration(duration)
+	default:
+		return 0, fmt.Errorf("unsupported duration %v", value)
+	}
+}
+
 // sendMessage sends the Message msg via the net.Conn connection conn
 func sendMessage(conn net.Conn, msg Message) error {
 	var err error
@@ -251,7 +576,11 @@ func sendMessage(conn net.Conn, msg Message) error {
 		return err
 	}
 
+	lock, _ := writeLocks.LoadOrStore(conn, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
 	err = wsutil.WriteServerText(conn, bytes)
+	lock.(*sync.Mutex).Unlock()
+
 	if err != nil {
 		logger.Error(fmt.Sprintf("Sending %s message for %s was unsuccessful with reason %s.", msg.OpCode, conn.RemoteAddr(), err))
 		return err
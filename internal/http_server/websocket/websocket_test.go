@@ -8,6 +8,8 @@ import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/gobwas/ws/wsutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,6 +20,20 @@ import (
 	"time"
 )
 
+// assertReply asserts that received is the expected REPLY message carrying the metadata of a published message.
+func assertReply(t *testing.T, expected Message, received []byte) {
+	reply := Message{}
+	require.NoError(t, json.Unmarshal(received, &reply))
+
+	require.NotNil(t, reply.Meta)
+	assert.False(t, reply.Meta.Timestamp.IsZero())
+	assert.Equal(t, "Some", reply.Meta.Source)
+	assert.Equal(t, pubsub.ContentTypeJSON, reply.Meta.ContentType)
+
+	reply.Meta = nil
+	assert.Equal(t, expected, reply)
+}
+
 func TestNewMessage(t *testing.T) {
 	msg := NewMessage(UNSUB, "Some.Target.Address", "Some value")
 	assert.Equal(t, UNSUB, msg.OpCode)
@@ -27,6 +43,7 @@ func TestNewMessage(t *testing.T) {
 
 func TestHandleWebsocketBadRequest(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	go HandleWebsocket(server)
 
@@ -51,6 +68,7 @@ func TestSendMessage(t *testing.T) {
 	wg.Add(1)
 
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	msg := NewMessage(UNSUB, "Some.Target.Address", "Some value")
 
@@ -80,12 +98,51 @@ func TestSendMessage(t *testing.T) {
 
 }
 
+// slowConn yields to other goroutines before every write, so that writes of concurrent senders interleave unless
+// they are serialized.
+type slowConn struct {
+	net.Conn
+}
+
+func (conn slowConn) Write(b []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return conn.Conn.Write(b)
+}
+
+func TestSendMessageConcurrently(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	const senders = 8
+
+	// Frames are written as header and payload, which mustn't interleave with the frames of other messages.
+	body := strings.Repeat("Some value ", 1000)
+
+	for i := 0; i < senders; i++ {
+		go func() {
+			if err := sendMessage(slowConn{server}, NewMessage(REPLY, "Some.Target.Address", body)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	expected, err := NewMessage(REPLY, "Some.Target.Address", body).Bytes()
+	require.NoError(t, err)
+
+	for i := 0; i < senders; i++ {
+		received, err := wsutil.ReadServerText(client)
+		require.NoError(t, err)
+		assert.Equal(t, expected, received)
+	}
+}
+
 func TestREPLY(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	go HandleWebsocket(server)
 
-	request, err := Message{REPLY, "Some.Target", "Some value!"}.Bytes()
+	request, err := NewMessage(REPLY, "Some.Target", "Some value!").Bytes()
 	if err != nil {
 		t.Error(err)
 		return
@@ -107,10 +164,11 @@ func TestREPLY(t *testing.T) {
 
 func TestERR(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	go HandleWebsocket(server)
 
-	request, err := Message{ERR, "Some.Target", "Some value!"}.Bytes()
+	request, err := NewMessage(ERR, "Some.Target", "Some value!").Bytes()
 	if err != nil {
 		t.Error(err)
 		return
@@ -132,10 +190,11 @@ func TestERR(t *testing.T) {
 
 func TestUnsupportedOption(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	go HandleWebsocket(server)
 
-	request, err := Message{"UNSUPPORTED", "Some.Target", "Some value!"}.Bytes()
+	request, err := NewMessage("UNSUPPORTED", "Some.Target", "Some value!").Bytes()
 	if err != nil {
 		t.Error(err)
 		return
@@ -160,6 +219,7 @@ func TestSUB(t *testing.T) {
 	timeout := time.After(1 * time.Second)
 
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	request := NewMessage(SUB, "Some.Target.SUB", "")
 
@@ -185,13 +245,7 @@ func TestSUB(t *testing.T) {
 			return
 		}
 
-		expected, err := msg.Bytes()
-		if err != nil {
-			t.Error(err)
-			return
-		}
-
-		assert.Equal(t, expected, received)
+		assertReply(t, msg, received)
 		done <- true
 	}()
 
@@ -218,12 +272,166 @@ func TestSUB(t *testing.T) {
 	}
 }
 
+func TestSUBInvalidPattern(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go HandleWebsocket(server)
+
+	bytes, err := NewMessage(SUB, "Some.#.Target", "").Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	message, err := wsutil.ReadServerText(client)
+	require.NoError(t, err)
+
+	expected, err := NewMessage(ERR, "Some.#.Target", "Invalid monitor!").Bytes()
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, message)
+}
+
+func TestSUBReplay(t *testing.T) {
+	now := time.Now()
+	// History entries outlive a test run, so every run uses its own monitor.
+	target := fmt.Sprintf("Some.Target.Replay%d", now.UnixNano())
+
+	var entries []db.HistoryEntry
+	for i := 3; i > 0; i-- {
+		entries = append(entries, db.HistoryEntry{
+			Timestamp: now.Add(-time.Duration(i) * time.Minute),
+			Target:    target,
+			Content:   fmt.Sprintf("Stored Value %d", 3-i),
+			Host:      "test-host",
+		})
+	}
+	require.NoError(t, db.GetWriter().AddHistoryEntries(entries))
+
+	for description, test := range map[string]struct {
+		params   SubscribeRequestParameters
+		expected []string
+	}{
+		"Last":  {SubscribeRequestParameters{Last: 2}, []string{"Stored Value 1", "Stored Value 2"}},
+		"Since": {SubscribeRequestParameters{Since: now.Add(-90 * time.Second)}, []string{"Stored Value 2"}},
+		"Both":  {SubscribeRequestParameters{Since: now.Add(-150 * time.Second), Last: 5}, []string{"Stored Value 1", "Stored Value 2"}},
+	} {
+		t.Run(description, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go HandleWebsocket(server)
+
+			value, err := json.Marshal(test.params)
+			require.NoError(t, err)
+
+			bytes, err := NewMessage(SUB, TargetAddress(target), string(value)).Bytes()
+			require.NoError(t, err)
+			require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+			for _, expected := range test.expected {
+				received, err := wsutil.ReadServerText(client)
+				require.NoError(t, err)
+
+				message, err := decodeMessage(received)
+				require.NoError(t, err)
+				assert.Equal(t, REPLY, message.OpCode)
+				assert.Equal(t, expected, message.Value)
+				require.NotNil(t, message.Meta)
+				assert.True(t, message.Meta.Replayed)
+				assert.Equal(t, "test-host", message.Meta.Host)
+			}
+
+			ctx.GetContext().GetBroker().Publish(target, "Live Value")
+
+			received, err := wsutil.ReadServerText(client)
+			require.NoError(t, err)
+
+			message, err := decodeMessage(received)
+			require.NoError(t, err)
+			assert.Equal(t, "Live Value", message.Value)
+			require.NotNil(t, message.Meta)
+			assert.False(t, message.Meta.Replayed)
+		})
+	}
+}
+
+func TestSUBReplayNegative(t *testing.T) {
+	for target, value := range map[string]string{
+		"Some.Target.*":      `{"last": 5}`,
+		"Some.Target.Replay": `{"last": -1}`,
+	} {
+		t.Run(target+" "+value, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go HandleWebsocket(server)
+
+			bytes, err := NewMessage(SUB, TargetAddress(target), value).Bytes()
+			require.NoError(t, err)
+			require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+			received, err := wsutil.ReadServerText(client)
+			require.NoError(t, err)
+
+			message, err := decodeMessage(received)
+			require.NoError(t, err)
+			assert.Equal(t, ERR, message.OpCode)
+		})
+	}
+}
+
+func TestReplayGate(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	gate := newReplayGate()
+
+	broker := pubsub.NewBroker()
+	subscriber := broker.AddSubscriber()
+	defer subscriber.Destruct()
+	require.NoError(t, broker.Subscribe(subscriber, "Some.Target.Gate"))
+
+	messages := make(chan *pubsub.Message, 3)
+	go subscriber.Listen(func(message *pubsub.Message) {
+		messages <- message
+	})
+
+	gate.open("Some.Target.Gate")
+
+	// The first live message has also been written to the history, the second one has not.
+	broker.Publish("Some.Target.Gate", "Duplicate")
+	broker.Publish("Some.Target.Gate", "Live")
+	broker.Publish("Some.Target.Other", "Other")
+
+	duplicate := <-messages
+	live := <-messages
+	assert.True(t, gate.hold(duplicate))
+	assert.True(t, gate.hold(live))
+
+	history := db.History{{Timestamp: duplicate.GetTimestamp().Add(-time.Second)}, {Timestamp: duplicate.GetTimestamp()}}
+	history[0].Message.Target, history[0].Message.Value = "Some.Target.Gate", "Stored"
+	history[1].Message.Target, history[1].Message.Value = "Some.Target.Gate", "Duplicate"
+
+	go func() {
+		assert.NoError(t, gate.release(server, "Some.Target.Gate", history, pubsub.Schema{}, false))
+	}()
+
+	for _, expected := range []string{"Stored", "Duplicate", "Live"} {
+		received, err := wsutil.ReadServerText(client)
+		require.NoError(t, err)
+
+		message, err := decodeMessage(received)
+		require.NoError(t, err)
+		assert.Equal(t, expected, message.Value)
+	}
+
+	assert.False(t, gate.hold(live))
+}
+
 func TestUNSUB(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	go HandleWebsocket(server)
 
-	request, err := Message{SUB, "Some.Target.UNSUB", ""}.Bytes()
+	request, err := NewMessage(SUB, "Some.Target.UNSUB", "").Bytes()
 	if err != nil {
 		t.Error(err)
 		return
@@ -254,7 +462,7 @@ func TestUNSUB(t *testing.T) {
 		for {
 			select {
 			case <-quit:
-				break
+				return
 			default:
 				ctx.GetContext().GetBroker().Publish("Some.Target.UNSUB", "Some Value!")
 				time.Sleep(50 * time.Millisecond)
@@ -264,7 +472,7 @@ func TestUNSUB(t *testing.T) {
 
 	wg.Wait()
 
-	unsubRequest, err := Message{UNSUB, "Some.Target.UNSUB", ""}.Bytes()
+	unsubRequest, err := NewMessage(UNSUB, "Some.Target.UNSUB", "").Bytes()
 	if err != nil {
 		t.Error(err)
 		return
@@ -275,17 +483,14 @@ func TestUNSUB(t *testing.T) {
 		return
 	}
 
-	fail := make(chan bool)
+	fail := make(chan bool, 1)
 	timeout := time.After(100 * time.Millisecond)
 
 	go func() {
-		_, err := wsutil.ReadServerText(client)
-		if err != nil {
-			t.Error(err)
-			return
+		// Reading fails once the connection is closed at the end of the test.
+		if _, err := wsutil.ReadServerText(client); err == nil {
+			fail <- true
 		}
-
-		fail <- true
 	}()
 
 	select {
@@ -303,6 +508,7 @@ func TestGET(t *testing.T) {
 	timeout := time.After(1 * time.Second)
 
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 
 	request := NewMessage(GET, "Some.Target.GET", "")
 
@@ -328,13 +534,7 @@ func TestGET(t *testing.T) {
 			return
 		}
 
-		expected, err := msg.Bytes()
-		if err != nil {
-			t.Error(err)
-			return
-		}
-
-		assert.Equal(t, expected, received)
+		assertReply(t, msg, received)
 		done <- true
 	}()
 
@@ -363,6 +563,179 @@ func TestGET(t *testing.T) {
 	quit <- true
 }
 
+func TestGETRetained(t *testing.T) {
+	ctx.GetContext().GetBroker().Publish("Some.Target.GETRetained", "Retained Value!")
+
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go HandleWebsocket(server)
+
+	bytes, err := NewMessage(GET, "Some.Target.GETRetained", "").Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	received := make(chan []byte)
+
+	go func() {
+		message, err := wsutil.ReadServerText(client)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		received <- message
+	}()
+
+	expected := NewMessage(REPLY, "Some.Target.GETRetained", "Retained Value!")
+
+	select {
+	case <-time.After(1 * time.Second):
+		t.Fatal("Retained value was not sent immediately...")
+	case message := <-received:
+		assertReply(t, expected, message)
+	}
+}
+
+func TestGETMaxAge(t *testing.T) {
+	broker := ctx.GetContext().GetBroker()
+	broker.Publish("Some.Target.GETMaxAge", "Stale Value!")
+
+	time.Sleep(100 * time.Millisecond)
+
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go HandleWebsocket(server)
+
+	bytes, err := NewMessage(GET, "Some.Target.GETMaxAge", `{"max_age": 0.05}`).Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	received := make(chan []byte)
+
+	go func() {
+		message, err := wsutil.ReadServerText(client)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		received <- message
+	}()
+
+	quit := make(chan bool)
+	defer close(quit)
+
+	go func() {
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(50 * time.Millisecond):
+				broker.Publish("Some.Target.GETMaxAge", "Fresh Value!")
+			}
+		}
+	}()
+
+	expected := NewMessage(REPLY, "Some.Target.GETMaxAge", "Fresh Value!")
+
+	select {
+	case <-time.After(1 * time.Second):
+		t.Fatal("Test didn't finish in time...")
+	case message := <-received:
+		assertReply(t, expected, message)
+	}
+}
+
+func TestGETBadParameters(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go HandleWebsocket(server)
+
+	bytes, err := NewMessage(GET, "Some.Target.GETBadParameters", `{"max_age": "yesterday"}`).Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	message, err := wsutil.ReadServerText(client)
+	require.NoError(t, err)
+
+	expected, err := NewMessage(ERR, "Some.Target.GETBadParameters", "Bad parameters!").Bytes()
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, message)
+}
+
+func TestGETClosedConnection(t *testing.T) {
+	previousTimeout := getTimeout
+	getTimeout = 50 * time.Millisecond
+	defer func() { getTimeout = previousTimeout }()
+
+	broker := ctx.GetContext().GetBroker()
+	subscribers := broker.GetSubscriberCount()
+
+	server, client := net.Pipe()
+
+	closed := make(chan struct{})
+	go func() {
+		HandleWebsocket(server)
+		close(closed)
+	}()
+
+	bytes, err := NewMessage(GET, "Some.Target.GETClosedConnection", "").Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+	require.NoError(t, client.Close())
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Connection wasn't closed in time...")
+	}
+
+	// The pending request is dropped with the connection instead of being answered after the timeout.
+	time.Sleep(2 * getTimeout)
+
+	_, ok := writeLocks.Load(server)
+	assert.False(t, ok)
+
+	assert.Eventually(t, func() bool {
+		return broker.GetSubscriberCount() <= subscribers
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestGETTimeout(t *testing.T) {
+	previousTimeout := getTimeout
+	getTimeout = 50 * time.Millisecond
+	defer func() { getTimeout = previousTimeout }()
+
+	broker := ctx.GetContext().GetBroker()
+	broker.Publish("Some.Target.GETTimeout", "Stale Value!")
+
+	time.Sleep(20 * time.Millisecond)
+
+	subscribers := broker.GetSubscriberCount()
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	go HandleWebsocket(server)
+
+	bytes, err := NewMessage(GET, "Some.Target.GETTimeout", `{"max_age": "10ms"}`).Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	message, err := wsutil.ReadServerText(client)
+	require.NoError(t, err)
+
+	assertMessage(t, ERR, "Some.Target.GETTimeout", "No current value!", message)
+
+	// Only the subscriber of the connection is left.
+	assert.Eventually(t, func() bool {
+		return broker.GetSubscriberCount() == subscribers+1
+	}, time.Second, 10*time.Millisecond)
+}
+
 func TestHIST(t *testing.T) {
 	// SETUP TEST DATA
 
@@ -411,7 +784,7 @@ func TestHIST(t *testing.T) {
 
 		compressedValue := buf.String()
 
-		_, err := stmt.Exec(reference.Add(-time.Duration(i)*time.Minute), "Some.Target", compressedValue)
+		_, err := stmt.Exec(reference.Add(-time.Duration(i)*time.Minute).UTC(), "Some.Target", compressedValue)
 		if err != nil {
 			t.Error(err)
 			return
@@ -465,6 +838,7 @@ func TestHIST(t *testing.T) {
 	// BEGIN ACTUAL TEST
 
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 	go HandleWebsocket(server)
 
 	history, err := sendHISTRequest(client, "Some.Target", HistoryRequestParameters{Until: time.Now()})
@@ -499,6 +873,7 @@ func TestHIST(t *testing.T) {
 	assert.Equal(t, "Some.Target", history[2].Message.Target)
 
 	history, err = sendHISTRequest(client, "Some.Target", HistoryRequestParameters{Until: time.Now(), MaxDensity: 2 * time.Minute})
+	t.Logf("DEBUG %+v", history)
 
 	require.Equal(t, 3, len(history))
 	assert.Equal(t, "Message No. 0", history[0].Message.Value)
@@ -506,8 +881,134 @@ func TestHIST(t *testing.T) {
 	assert.Equal(t, "Message No. 4", history[2].Message.Value)
 }
 
+func TestHISTAggregate(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	require.NoError(t, db.GetWriter().AddHistoryEntries([]db.HistoryEntry{
+		{Timestamp: base.Add(10 * time.Second), Target: "Some.Target.Aggregate", Content: `{"value": 10}`},
+		{Timestamp: base.Add(20 * time.Second), Target: "Some.Target.Aggregate", Content: `{"value": 20}`},
+		{Timestamp: base.Add(70 * time.Second), Target: "Some.Target.Aggregate", Content: `{"value": 60}`},
+	}))
+
+	for description, test := range map[string]struct {
+		params   string
+		opCode   OpCode
+		expected []db.AggregateBucket
+	}{
+		"Buckets": {
+			params: fmt.Sprintf(`{"from": "%s", "until": "%s", "field": "value", "bucket": "1m", "rate": true}`, base.Format(time.RFC3339), base.Add(2*time.Minute).Format(time.RFC3339)),
+			opCode: REPLY,
+			expected: []db.AggregateBucket{
+				{Start: base, Count: 2, Min: 10, Max: 20, Avg: 15, Rate: func() *float64 { rate := 1.0; return &rate }()},
+				{Start: base.Add(time.Minute), Count: 1, Min: 60, Max: 60, Avg: 60, Rate: func() *float64 { rate := 0.8; return &rate }()},
+			},
+		},
+		"SecondsBucket": {
+			params: fmt.Sprintf(`{"from": "%s", "until": "%s", "field": "value", "bucket": 60}`, base.Format(time.RFC3339), base.Add(2*time.Minute).Format(time.RFC3339)),
+			opCode: REPLY,
+			expected: []db.AggregateBucket{
+				{Start: base, Count: 2, Min: 10, Max: 20, Avg: 15},
+				{Start: base.Add(time.Minute), Count: 1, Min: 60, Max: 60, Avg: 60},
+			},
+		},
+		"InvalidBucket": {params: `{"field": "value", "bucket": "often"}`, opCode: ERR},
+		"Percentiles":   {params: `{"field": "value", "bucket": "1m", "percentiles": [200]}`, opCode: ERR},
+	} {
+		t.Run(description, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go HandleWebsocket(server)
+
+			bytes, err := NewMessage(HIST, "Some.Target.Aggregate", test.params).Bytes()
+			require.NoError(t, err)
+			require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+			received, err := wsutil.ReadServerText(client)
+			require.NoError(t, err)
+
+			message, err := decodeMessage(received)
+			require.NoError(t, err)
+			require.Equal(t, test.opCode, message.OpCode)
+
+			if test.opCode != REPLY {
+				return
+			}
+
+			var buckets []db.AggregateBucket
+			require.NoError(t, json.Unmarshal([]byte(message.Value), &buckets))
+			require.Equal(t, len(test.expected), len(buckets))
+
+			for i, expected := range test.expected {
+				assert.True(t, expected.Start.Equal(buckets[i].Start))
+				buckets[i].Start = expected.Start
+				assert.Equal(t, expected, buckets[i])
+			}
+		})
+	}
+}
+
+func TestHISTMaxPoints(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	var entries []db.HistoryEntry
+	for i := 0; i < 60; i++ {
+		usage := 10
+		if i == 37 {
+			usage = 90
+		}
+
+		entries = append(entries, db.HistoryEntry{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Target:    "Some.Target.MaxPoints",
+			Content:   fmt.Sprintf(`{"cpu0": {"usage": 10}, "cpu1": {"usage": %d}}`, usage),
+		})
+	}
+
+	require.NoError(t, db.GetWriter().AddHistoryEntries(entries))
+
+	for description, test := range map[string]struct {
+		params string
+		opCode OpCode
+	}{
+		"Downsampled": {params: `{"field": "cpu*.usage", "max_points": 6}`, opCode: REPLY},
+		"TooFew":      {params: `{"field": "cpu*.usage", "max_points": 1}`, opCode: ERR},
+	} {
+		t.Run(description, func(t *testing.T) {
+			server, client := net.Pipe()
+			defer func() { _ = client.Close() }()
+			go HandleWebsocket(server)
+
+			bytes, err := NewMessage(HIST, "Some.Target.MaxPoints", test.params).Bytes()
+			require.NoError(t, err)
+			require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+			received, err := wsutil.ReadServerText(client)
+			require.NoError(t, err)
+
+			message, err := decodeMessage(received)
+			require.NoError(t, err)
+			require.Equal(t, test.opCode, message.OpCode)
+
+			if test.opCode != REPLY {
+				return
+			}
+
+			var history db.History
+			require.NoError(t, json.Unmarshal([]byte(message.Value), &history))
+			require.Equal(t, 6, len(history))
+
+			var spike bool
+			for _, entry := range history {
+				spike = spike || entry.Timestamp.Equal(start.Add(37*time.Second))
+			}
+
+			assert.True(t, spike)
+		})
+	}
+}
+
 func TestHISTNegative(t *testing.T) {
 	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
 	go HandleWebsocket(server)
 
 	request := Message{
@@ -537,6 +1038,83 @@ func TestHISTNegative(t *testing.T) {
 
 }
 
+func TestESUB(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go HandleWebsocket(server)
+
+	for _, request := range []Message{
+		NewMessage(ESUB, "Plugin.*", ""),
+		// The invalid subscription is answered, so its reply tells that the first subscription has been handled.
+		NewMessage(ESUB, "Plugin.#.Crashed", ""),
+	} {
+		bytes, err := request.Bytes()
+		require.NoError(t, err)
+		require.NoError(t, wsutil.WriteClientText(client, bytes))
+	}
+
+	received, err := wsutil.ReadServerText(client)
+	require.NoError(t, err)
+	assertMessage(t, ERR, "Plugin.#.Crashed", "Invalid event type!", received)
+
+	events.Emit(events.Event{Type: events.TypeLoginFailed, Severity: events.SeverityWarning, Source: "HTTP"})
+	events.Emit(events.Event{Type: events.TypePluginCrashed, Severity: events.SeverityError, Source: "Plugins", Attributes: map[string]string{"plugin": "demo"}})
+
+	received, err = wsutil.ReadServerText(client)
+	require.NoError(t, err)
+
+	message, err := decodeMessage(received)
+	require.NoError(t, err)
+	assert.Equal(t, EVENT, message.OpCode)
+	assert.Equal(t, TargetAddress(events.TypePluginCrashed), message.Target)
+
+	var event events.Event
+	require.NoError(t, json.Unmarshal([]byte(message.Value), &event))
+	assert.Equal(t, events.SeverityError, event.Severity)
+	assert.Equal(t, map[string]string{"plugin": "demo"}, event.Attributes)
+}
+
+func TestEHIST(t *testing.T) {
+	server, client := net.Pipe()
+	defer func() { _ = client.Close() }()
+	go HandleWebsocket(server)
+
+	start := time.Now()
+	events.Emit(events.Event{Type: events.TypeConfigReloaded, Severity: events.SeverityInfo, Source: "Excubitor"})
+	events.Emit(events.Event{Type: events.TypeConfigReloadFailed, Severity: events.SeverityError, Source: "Excubitor"})
+	events.Emit(events.Event{Type: events.TypeModuleFailed, Severity: events.SeverityError, Source: "Excubitor"})
+
+	value, err := json.Marshal(EventHistoryRequestParameters{From: start, MinSeverity: "error"})
+	require.NoError(t, err)
+
+	bytes, err := NewMessage(EHIST, "Config.*", string(value)).Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	received, err := wsutil.ReadServerText(client)
+	require.NoError(t, err)
+
+	message, err := decodeMessage(received)
+	require.NoError(t, err)
+	assert.Equal(t, REPLY, message.OpCode)
+
+	var timeline []events.Event
+	require.NoError(t, json.Unmarshal([]byte(message.Value), &timeline))
+	require.Len(t, timeline, 1)
+	assert.Equal(t, events.TypeConfigReloadFailed, timeline[0].Type)
+
+	value, err = json.Marshal(EventHistoryRequestParameters{MinSeverity: "fatal"})
+	require.NoError(t, err)
+
+	bytes, err = NewMessage(EHIST, "", string(value)).Bytes()
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(client, bytes))
+
+	received, err = wsutil.ReadServerText(client)
+	require.NoError(t, err)
+	assertMessage(t, ERR, "", "Bad parameters!", received)
+}
+
 func decodeMessage(messageJSON []byte) (Message, error) {
 	var output Message
 	if err := json.Unmarshal(messageJSON, &output); err != nil {
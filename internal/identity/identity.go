@@ -0,0 +1,47 @@
+// Package identity describes the host this Excubitor instance is running on.
+// It allows data of many instances to be told apart once it has been aggregated elsewhere.
+package identity
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"os"
+)
+
+// Identity models the hostname and the configured labels of this Excubitor instance.
+type Identity struct {
+	Hostname string            `json:"hostname"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// Get returns the identity of this Excubitor instance.
+// The hostname is taken from main.hostname and falls back to the hostname reported by the kernel.
+func Get() Identity {
+	return Identity{
+		Hostname: GetHostname(),
+		Labels:   GetLabels(),
+	}
+}
+
+// GetHostname returns the hostname of this Excubitor instance.
+func GetHostname() string {
+	if hostname := config.GetConfig().String("main.hostname"); hostname != "" {
+		return hostname
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+
+	return hostname
+}
+
+// GetLabels returns a copy of the labels configured in main.labels.
+func GetLabels() map[string]string {
+	labels := map[string]string{}
+	for key, value := range config.GetConfig().StringMap("main.labels") {
+		labels[key] = value
+	}
+
+	return labels
+}
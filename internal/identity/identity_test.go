@@ -0,0 +1,42 @@
+package identity
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, Identity{Hostname: hostname, Labels: map[string]string{}}, Get())
+
+	restore, err := config.Override(map[string]interface{}{
+		"main.hostname": "db-01",
+		"main.labels": map[string]interface{}{
+			"env":        "prod",
+			"role":       "db",
+			"datacenter": "fra1",
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	t.Cleanup(restore)
+
+	assert.Equal(t, Identity{
+		Hostname: "db-01",
+		Labels: map[string]string{
+			"env":        "prod",
+			"role":       "db",
+			"datacenter": "fra1",
+		},
+	}, Get())
+}
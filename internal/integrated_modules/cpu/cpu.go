@@ -3,19 +3,21 @@ package cpu
 import (
 	"encoding/json"
 	"fmt"
-	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/hostfs"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 )
 
 var logger logging.Logger
 
 // Tick is a function that is called whenever the context wants the module to report its values.
-func Tick() {
+// The values are published on broker.
+func Tick(broker pubsub.Publisher) {
 	logger = logging.GetLogger()
 
 	cpuInfo, err := readCPUInfoFile()
 	if err != nil {
-		logger.Error(fmt.Sprintf("Could not read /proc/cpuinfo! Reason: %s", err))
+		logger.Error(fmt.Sprintf("Could not read %s! Reason: %s", hostfs.ProcPath("cpuinfo"), err))
 		return
 	}
 
@@ -31,7 +33,6 @@ func Tick() {
 		return
 	}
 
-	broker := ctx.GetContext().GetBroker()
 	broker.Publish("CPU.CpuInfo", string(jsonOutput))
 
 	go func() {
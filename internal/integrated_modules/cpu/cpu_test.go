@@ -0,0 +1,49 @@
+package cpu
+
+import (
+	"encoding/json"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTick(t *testing.T) {
+	broker := pubsub.NewRecordingBroker(pubsub.NewBroker())
+
+	Tick(broker)
+
+	// The usage is calculated and published asynchronously.
+	require.Eventually(t, func() bool {
+		return len(broker.GetPublishedOn("CPU.Usage")) == 1
+	}, 5*time.Second, 10*time.Millisecond, "Tick didn't publish all monitors in time...")
+
+	cpuInfo := broker.GetPublishedOn("CPU.CpuInfo")
+	require.Len(t, cpuInfo, 1)
+
+	var cpus []cpu
+	require.NoError(t, json.Unmarshal([]byte(cpuInfo[0]), &cpus))
+	require.Equal(t, 2, len(cpus))
+	assert.Equal(t, "Intel(R) Core(TM) i7-7700K CPU @ 4.20GHz", cpus[0].ModelName)
+	assert.Equal(t, uint(1), cpus[1].Id)
+
+	var usage map[string]cpuUsage
+	require.NoError(t, json.Unmarshal([]byte(broker.GetPublishedOn("CPU.Usage")[0]), &usage))
+	assert.Equal(t, 17, len(usage))
+	assert.Equal(t, float64(0), usage["cpu"].Usage)
+}
+
+func TestRegisterSchemas(t *testing.T) {
+	broker := pubsub.NewBroker()
+	require.NoError(t, RegisterSchemas(broker))
+
+	schema, ok := broker.GetSchema("CPU.Usage")
+	require.True(t, ok)
+	assert.Equal(t, "CPU", schema.Source)
+	assert.JSONEq(t, `{"type": "object", "additionalProperties": {"type": "object", "properties": {"usage": {"type": "number"}}}}`, string(schema.Schema))
+	assert.Equal(t, []string{"*.usage"}, schema.NumericFields)
+
+	_, ok = broker.GetSchema("CPU.CpuInfo")
+	assert.True(t, ok)
+}
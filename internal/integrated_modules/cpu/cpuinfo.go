@@ -2,7 +2,7 @@ package cpu
 
 import (
 	"fmt"
-	"os"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/hostfs"
 	"regexp"
 	"strconv"
 )
@@ -17,9 +17,9 @@ type cpu struct {
 	Flags      string  `json:"flags"`
 }
 
-// readCPUInfoFile reads the contents of /proc/cpuinfo and returns them in a byte slice.
+// readCPUInfoFile reads the contents of the cpuinfo file of the host's procfs and returns them in a byte slice.
 func readCPUInfoFile() ([]byte, error) {
-	file, err := os.ReadFile("/proc/cpuinfo")
+	file, err := hostfs.ReadProcFile("cpuinfo")
 	return file, err
 }
 
@@ -0,0 +1,31 @@
+package cpu
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+)
+
+// numericFields are the fields of the CPU monitors that are stored as series.
+var numericFields = map[string][]string{
+	"CPU.Usage": {"*.usage"},
+}
+
+// RegisterSchemas registers the schemas of all monitors published by the CPU module.
+func RegisterSchemas(broker pubsub.Broker) error {
+	for monitor, value := range map[string]any{
+		"CPU.CpuInfo": []cpu{},
+		"CPU.Usage":   map[string]cpuUsage{},
+	} {
+		err := broker.RegisterSchema(pubsub.Schema{
+			Monitor:       monitor,
+			Source:        "CPU",
+			Version:       1,
+			Schema:        pubsub.SchemaFromValue(value),
+			NumericFields: numericFields[monitor],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
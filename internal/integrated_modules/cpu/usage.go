@@ -2,7 +2,7 @@ package cpu
 
 import (
 	"fmt"
-	"os"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/hostfs"
 	"strconv"
 	"strings"
 	"time"
@@ -28,14 +28,14 @@ type cpuUsage struct {
 func calculateCPUUsage() (map[string]cpuUsage, error) {
 	returnMap := make(map[string]cpuUsage)
 
-	firstReading, err := os.ReadFile("/proc/stat")
+	firstReading, err := hostfs.ReadProcFile("stat")
 	if err != nil {
 		return nil, err
 	}
 
 	time.Sleep(1000 * time.Millisecond)
 
-	secondReading, err := os.ReadFile("/proc/stat")
+	secondReading, err := hostfs.ReadProcFile("stat")
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +62,12 @@ func calculateCPUUsage() (map[string]cpuUsage, error) {
 		spentIdle := second.Idle - first.Idle
 		spentWorking := diff - spentIdle
 
+		// Without any elapsed ticks there is no usage to report. Dividing by zero would produce NaN.
+		if diff == 0 {
+			returnMap[first.name] = cpuUsage{Usage: 0}
+			continue
+		}
+
 		usage := float64(100) * float64(spentWorking) / float64(diff)
 		returnMap[first.name] = cpuUsage{Usage: usage}
 	}
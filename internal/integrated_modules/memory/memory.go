@@ -3,25 +3,27 @@ package memory
 import (
 	"encoding/json"
 	"fmt"
-	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/hostfs"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
-	"os"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 )
 
 var logger logging.Logger
 
-func Tick() {
+// Tick is a function that is called whenever the context wants the module to report its values.
+// The values are published on broker.
+func Tick(broker pubsub.Publisher) {
 	logger = logging.GetLogger()
 
-	memInfoFile, err := os.ReadFile("/proc/meminfo")
+	memInfoFile, err := hostfs.ReadProcFile("meminfo")
 	if err != nil {
-		logger.Error(fmt.Sprintf("Could not read file '/proc/meminfo'. Reason: %s", err))
+		logger.Error(fmt.Sprintf("Could not read file '%s'. Reason: %s", hostfs.ProcPath("meminfo"), err))
 		return
 	}
 
 	entries, err := parseMemInfo(string(memInfoFile))
 	if err != nil {
-		logger.Error(fmt.Sprintf("Could not parse file '/proc/meminfo'. Reason: %s", err))
+		logger.Error(fmt.Sprintf("Could not parse file '%s'. Reason: %s", hostfs.ProcPath("meminfo"), err))
 		return
 	}
 
@@ -39,8 +41,6 @@ func Tick() {
 		logger.Error(fmt.Sprintf("Couldn't encode swap information! Reason: %s", err))
 	}
 
-	broker := ctx.GetContext().GetBroker()
-
 	broker.Publish("Memory.MemInfo", string(memInfoJSON))
 	broker.Publish("Memory.SwapInfo", string(swapInfoJSON))
 }
@@ -0,0 +1,18 @@
+package memory
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTick(t *testing.T) {
+	broker := pubsub.NewRecordingBroker(pubsub.NewBroker())
+
+	Tick(broker)
+
+	assert.Equal(t, []pubsub.Publication{
+		{Monitor: "Memory.MemInfo", Message: `{"mem_total":30500812,"mem_free":21509628,"mem_available":25526628}`},
+		{Monitor: "Memory.SwapInfo", Message: `{"swap_total":8388604,"swap_free":8388604}`},
+	}, broker.GetPublished())
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+)
+
+// numericFields are the fields of the Memory monitors that are stored as series.
+var numericFields = map[string][]string{
+	"Memory.MemInfo":  {"mem_total", "mem_free", "mem_available"},
+	"Memory.SwapInfo": {"swap_total", "swap_free"},
+}
+
+// RegisterSchemas registers the schemas of all monitors published by the Memory module.
+func RegisterSchemas(broker pubsub.Broker) error {
+	for monitor, value := range map[string]any{
+		"Memory.MemInfo":  meminfo{},
+		"Memory.SwapInfo": swapInfo{},
+	} {
+		err := broker.RegisterSchema(pubsub.Schema{
+			Monitor:       monitor,
+			Source:        "Memory",
+			Version:       1,
+			Schema:        pubsub.SchemaFromValue(value),
+			NumericFields: numericFields[monitor],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
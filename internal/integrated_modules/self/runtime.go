@@ -0,0 +1,65 @@
+package self
+
+import (
+	"os"
+	"runtime/metrics"
+)
+
+type runtimeStats struct {
+	Goroutines       uint64 `json:"goroutines"`
+	HeapObjectsBytes uint64 `json:"heap_objects_bytes"`
+	HeapGoalBytes    uint64 `json:"heap_goal_bytes"`
+	TotalMemoryBytes uint64 `json:"total_memory_bytes"`
+	GCCycles         uint64 `json:"gc_cycles"`
+	OpenFDs          int    `json:"open_fds"`
+}
+
+// runtimeSamples maps the runtime/metrics keys read by readRuntimeStats to the fields they are stored in.
+var runtimeSamples = []struct {
+	name  string
+	field func(stats *runtimeStats) *uint64
+}{
+	{"/sched/goroutines:goroutines", func(stats *runtimeStats) *uint64 { return &stats.Goroutines }},
+	{"/memory/classes/heap/objects:bytes", func(stats *runtimeStats) *uint64 { return &stats.HeapObjectsBytes }},
+	{"/gc/heap/goal:bytes", func(stats *runtimeStats) *uint64 { return &stats.HeapGoalBytes }},
+	{"/memory/classes/total:bytes", func(stats *runtimeStats) *uint64 { return &stats.TotalMemoryBytes }},
+	{"/gc/cycles/total:gc-cycles", func(stats *runtimeStats) *uint64 { return &stats.GCCycles }},
+}
+
+// readRuntimeStats reads the health of the Go runtime of the Excubitor process.
+func readRuntimeStats() (runtimeStats, error) {
+	stats := runtimeStats{}
+
+	samples := make([]metrics.Sample, len(runtimeSamples))
+	for i, sample := range runtimeSamples {
+		samples[i].Name = sample.name
+	}
+
+	metrics.Read(samples)
+
+	for i, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			*runtimeSamples[i].field(&stats) = sample.Value.Uint64()
+		}
+	}
+
+	openFDs, err := countOpenFDs()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.OpenFDs = openFDs
+
+	return stats, nil
+}
+
+// countOpenFDs counts the file descriptors opened by the Excubitor process.
+// This deliberately reads the procfs of the process itself instead of the configured procfs root of the monitored host.
+func countOpenFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
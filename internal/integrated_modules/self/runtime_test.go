@@ -0,0 +1,19 @@
+package self
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReadRuntimeStats(t *testing.T) {
+	stats, err := readRuntimeStats()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Greater(t, stats.Goroutines, uint64(0))
+	assert.Greater(t, stats.HeapObjectsBytes, uint64(0))
+	assert.Greater(t, stats.TotalMemoryBytes, stats.HeapObjectsBytes)
+	assert.Greater(t, stats.OpenFDs, 0)
+}
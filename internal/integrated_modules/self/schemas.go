@@ -0,0 +1,27 @@
+package self
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+)
+
+// RegisterSchemas registers the schemas of all monitors published by the Excubitor module.
+func RegisterSchemas(broker pubsub.Broker) error {
+	for monitor, value := range map[string]any{
+		"Excubitor.Runtime":       runtimeStats{},
+		"Excubitor.Connections":   connectionStats{},
+		"Excubitor.Database":      databaseStats{},
+		"Excubitor.TickDurations": map[string]float64{},
+	} {
+		err := broker.RegisterSchema(pubsub.Schema{
+			Monitor: monitor,
+			Source:  "Excubitor",
+			Version: 1,
+			Schema:  pubsub.SchemaFromValue(value),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
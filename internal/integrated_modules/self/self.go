@@ -0,0 +1,106 @@
+package self
+
+import (
+	"encoding/json"
+	"fmt"
+	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/http_server/websocket"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"sync"
+)
+
+var logger logging.Logger
+
+type connectionStats struct {
+	WebsocketConnections int64  `json:"websocket_connections"`
+	BrokerSubscribers    int    `json:"broker_subscribers"`
+	DroppedMessages      uint64 `json:"dropped_messages"`
+}
+
+type databaseStats struct {
+	SizeBytes   int64  `json:"size_bytes"`
+	RowsWritten uint64 `json:"rows_written"`
+}
+
+// lastRowsWritten holds the number of rows the database writer had written on the last tick.
+var lastRowsWritten uint64
+var lastRowsWrittenLock sync.Mutex
+
+// Tick is a function that is called whenever the context wants the module to report its values.
+// The values are published on broker.
+func Tick(broker pubsub.Broker) {
+	logger = logging.GetLogger()
+
+	runtimeStats, err := readRuntimeStats()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not read runtime statistics! Reason: %s", err))
+	} else {
+		publish(broker, "Excubitor.Runtime", runtimeStats)
+	}
+
+	publish(broker, "Excubitor.Connections", connectionStats{
+		WebsocketConnections: websocket.GetConnectionCount(),
+		BrokerSubscribers:    broker.GetSubscriberCount(),
+		DroppedMessages:      broker.GetDropped(),
+	})
+
+	stats, err := readDatabaseStats(db.GetStorage())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Could not determine database size! Reason: %s", err))
+	} else {
+		publish(broker, "Excubitor.Database", stats)
+	}
+
+	tickDurations := map[string]float64{}
+	for module, duration := range ctx.GetContext().GetTickDurations() {
+		tickDurations[module] = float64(duration.Microseconds()) / 1000
+	}
+
+	publish(broker, "Excubitor.TickDurations", tickDurations)
+}
+
+// readDatabaseStats reads the size of the storage and the number of history entries written since the last tick.
+// Both are reported as 0 by storages that can't tell them.
+func readDatabaseStats(storage db.Storage) (databaseStats, error) {
+	var stats databaseStats
+
+	if sizedStorage, ok := storage.(db.SizedStorage); ok {
+		size, err := sizedStorage.GetSize()
+		if err != nil {
+			return databaseStats{}, err
+		}
+
+		stats.SizeBytes = size
+	}
+
+	if countingStorage, ok := storage.(db.CountingStorage); ok {
+		stats.RowsWritten = rowsWrittenSinceLastTick(countingStorage)
+	}
+
+	return stats, nil
+}
+
+// rowsWrittenSinceLastTick returns the number of history entries written since the last tick.
+func rowsWrittenSinceLastTick(storage db.CountingStorage) uint64 {
+	lastRowsWrittenLock.Lock()
+	defer lastRowsWrittenLock.Unlock()
+
+	rowsWritten := storage.GetRowsWritten()
+	delta := rowsWritten - lastRowsWritten
+	lastRowsWritten = rowsWritten
+
+	return delta
+}
+
+// publish encodes value as JSON and publishes it on the given monitor.
+func publish(broker pubsub.Publisher, monitor string, value any) {
+	jsonOutput, err := json.Marshal(value)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Couldn't encode %s! Reason: %s", monitor, err))
+		return
+	}
+
+	broker.Publish(monitor, string(jsonOutput))
+}
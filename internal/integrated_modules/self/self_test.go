@@ -0,0 +1,44 @@
+package self
+
+import (
+	"encoding/json"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTick(t *testing.T) {
+	broker := pubsub.NewRecordingBroker(pubsub.NewBroker())
+
+	subscriber := broker.AddSubscriber()
+	defer subscriber.Destruct()
+
+	if err := db.GetWriter().AddHistoryEntry("Some.Target", "Some content"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	Tick(broker)
+
+	connectionMessages := broker.GetPublishedOn("Excubitor.Connections")
+	require.Len(t, connectionMessages, 1)
+
+	var connections connectionStats
+	require.NoError(t, json.Unmarshal([]byte(connectionMessages[0]), &connections))
+	assert.Equal(t, int64(0), connections.WebsocketConnections)
+	assert.Equal(t, 1, connections.BrokerSubscribers)
+	assert.Equal(t, uint64(0), connections.DroppedMessages)
+
+	databaseMessages := broker.GetPublishedOn("Excubitor.Database")
+	require.Len(t, databaseMessages, 1)
+
+	var database databaseStats
+	require.NoError(t, json.Unmarshal([]byte(databaseMessages[0]), &database))
+	assert.Greater(t, database.SizeBytes, int64(0))
+	assert.GreaterOrEqual(t, database.RowsWritten, uint64(1))
+
+	assert.Len(t, broker.GetPublishedOn("Excubitor.Runtime"), 1)
+	assert.Len(t, broker.GetPublishedOn("Excubitor.TickDurations"), 1)
+}
@@ -0,0 +1,183 @@
+// Package jsonfields addresses fields inside the JSON bodies published by monitors.
+//
+// Fields are addressed with dot-separated paths, i.e. "mem_total" or "cpu0.usage". Every segment of a path may be a
+// glob pattern as understood by path.Match, so "cpu*.usage" addresses the usage of every core.
+package jsonfields
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parse decodes a JSON body into a generic value.
+func Parse(body string) (any, error) {
+	var value any
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Lookup returns all values whose path matches the given field path.
+// The values are sorted by their path so that the result is deterministic.
+func Lookup(value any, fieldPath string) []any {
+	var segments []string
+	if fieldPath != "" {
+		segments = strings.Split(fieldPath, ".")
+	}
+
+	var matches []match
+	lookup(value, "", segments, &matches)
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].path < matches[j].path
+	})
+
+	values := make([]any, len(matches))
+	for i, m := range matches {
+		values[i] = m.value
+	}
+
+	return values
+}
+
+// LookupNumbers returns all numeric values whose path matches the given field path.
+// Values that aren't numbers are skipped.
+func LookupNumbers(value any, fieldPath string) []float64 {
+	var numbers []float64
+	for _, v := range Lookup(value, fieldPath) {
+		if number, ok := v.(float64); ok {
+			numbers = append(numbers, number)
+		}
+	}
+
+	return numbers
+}
+
+// MatchPath reports whether the dot-separated path of a field, as returned by Flatten, matches the given field path.
+func MatchPath(fieldPath string, leafPath string) bool {
+	segments := strings.Split(fieldPath, ".")
+	leafSegments := strings.Split(leafPath, ".")
+
+	if len(segments) != len(leafSegments) {
+		return false
+	}
+
+	for i, segment := range segments {
+		if matched, err := path.Match(segment, leafSegments[i]); err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Flatten returns all leaves of value keyed by their dot-separated path.
+// A scalar value at the top level is returned with the empty path.
+func Flatten(value any) map[string]any {
+	leaves := map[string]any{}
+	flatten(value, "", leaves)
+
+	return leaves
+}
+
+// Numbers returns all numeric leaves of value keyed by their dot-separated path.
+func Numbers(value any) map[string]float64 {
+	numbers := map[string]float64{}
+	for key, leaf := range Flatten(value) {
+		if number, ok := leaf.(float64); ok {
+			numbers[key] = number
+		}
+	}
+
+	return numbers
+}
+
+// Map returns a copy of value with every leaf replaced by the result of mapper, which is called with the leaf's path.
+// The structure of objects and arrays is kept.
+func Map(value any, mapper func(path string, leaf any) any) any {
+	return mapLeaves(value, "", mapper)
+}
+
+type match struct {
+	path  string
+	value any
+}
+
+func lookup(value any, current string, segments []string, matches *[]match) {
+	if len(segments) == 0 {
+		*matches = append(*matches, match{current, value})
+		return
+	}
+
+	for key, child := range children(value) {
+		if matched, err := path.Match(segments[0], key); err != nil || !matched {
+			continue
+		}
+
+		lookup(child, join(current, key), segments[1:], matches)
+	}
+}
+
+func flatten(value any, current string, leaves map[string]any) {
+	nested := children(value)
+	if nested == nil {
+		leaves[current] = value
+		return
+	}
+
+	for key, child := range nested {
+		flatten(child, join(current, key), leaves)
+	}
+}
+
+func mapLeaves(value any, current string, mapper func(path string, leaf any) any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		mapped := make(map[string]any, len(v))
+		for key, child := range v {
+			mapped[key] = mapLeaves(child, join(current, key), mapper)
+		}
+
+		return mapped
+	case []any:
+		mapped := make([]any, len(v))
+		for i, child := range v {
+			mapped[i] = mapLeaves(child, join(current, strconv.Itoa(i)), mapper)
+		}
+
+		return mapped
+	default:
+		return mapper(current, value)
+	}
+}
+
+// children returns the direct children of objects and arrays keyed by their path segment.
+// Array elements are addressed by their index. Scalars have no children.
+func children(value any) map[string]any {
+	switch v := value.(type) {
+	case map[string]any:
+		return v
+	case []any:
+		nested := make(map[string]any, len(v))
+		for i, child := range v {
+			nested[strconv.Itoa(i)] = child
+		}
+
+		return nested
+	default:
+		return nil
+	}
+}
+
+func join(current string, key string) string {
+	if current == "" {
+		return key
+	}
+
+	return current + "." + key
+}
@@ -0,0 +1,87 @@
+package jsonfields
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const body = `{"cpu": {"usage": 10}, "cpu0": {"usage": 20}, "cpu1": {"usage": 30}, "name": "Some CPU", "cores": [{"id": 0}, {"id": 1}]}`
+
+func TestLookupNumbers(t *testing.T) {
+	value, err := Parse(body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, []float64{20}, LookupNumbers(value, "cpu0.usage"))
+	assert.Equal(t, []float64{10, 20, 30}, LookupNumbers(value, "cpu*.usage"))
+	assert.Equal(t, []float64{20, 30}, LookupNumbers(value, "cpu?*.usage"))
+	assert.Equal(t, []float64{1}, LookupNumbers(value, "cores.1.id"))
+	assert.Empty(t, LookupNumbers(value, "name"))
+	assert.Empty(t, LookupNumbers(value, "cpu2.usage"))
+	assert.Equal(t, []any{"Some CPU"}, Lookup(value, "name"))
+}
+
+func TestFlatten(t *testing.T) {
+	value, err := Parse(body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, map[string]any{
+		"cpu.usage":  float64(10),
+		"cpu0.usage": float64(20),
+		"cpu1.usage": float64(30),
+		"name":       "Some CPU",
+		"cores.0.id": float64(0),
+		"cores.1.id": float64(1),
+	}, Flatten(value))
+
+	assert.Equal(t, map[string]float64{
+		"cpu.usage":  10,
+		"cpu0.usage": 20,
+		"cpu1.usage": 30,
+		"cores.0.id": 0,
+		"cores.1.id": 1,
+	}, Numbers(value))
+
+	assert.Equal(t, map[string]any{"": float64(5)}, Flatten(float64(5)))
+}
+
+func TestMap(t *testing.T) {
+	value, err := Parse(body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mapped := Map(value, func(path string, leaf any) any {
+		if number, ok := leaf.(float64); ok {
+			return number * 2
+		}
+
+		return path
+	})
+
+	assert.Equal(t, map[string]any{
+		"cpu":   map[string]any{"usage": float64(20)},
+		"cpu0":  map[string]any{"usage": float64(40)},
+		"cpu1":  map[string]any{"usage": float64(60)},
+		"name":  "name",
+		"cores": []any{map[string]any{"id": float64(0)}, map[string]any{"id": float64(2)}},
+	}, mapped)
+
+	// The original value is left untouched.
+	assert.Equal(t, []float64{10}, LookupNumbers(value, "cpu.usage"))
+}
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, MatchPath("cpu0.usage", "cpu0.usage"))
+	assert.True(t, MatchPath("cpu*.usage", "cpu1.usage"))
+	assert.True(t, MatchPath("cores.*.id", "cores.0.id"))
+	assert.True(t, MatchPath("", ""))
+	assert.False(t, MatchPath("cpu*.usage", "cpu1.usage.total"))
+	assert.False(t, MatchPath("cpu?.usage", "cpu10.usage"))
+}
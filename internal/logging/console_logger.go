@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"log"
 	"os"
 	"sync"
@@ -25,11 +26,11 @@ const (
 
 type ConsoleLogger struct {
 	loggers loggerBundle
-	level   LogLevel
+	level   logLevel
 }
 
 func (logger *ConsoleLogger) Trace(v ...any) {
-	if logger.level > Trace {
+	if logger.level.load() > Trace {
 		return
 	}
 
@@ -37,7 +38,7 @@ func (logger *ConsoleLogger) Trace(v ...any) {
 }
 
 func (logger *ConsoleLogger) Debug(v ...any) {
-	if logger.level > Debug {
+	if logger.level.load() > Debug {
 		return
 	}
 
@@ -45,7 +46,7 @@ func (logger *ConsoleLogger) Debug(v ...any) {
 }
 
 func (logger *ConsoleLogger) Info(v ...any) {
-	if logger.level > Info {
+	if logger.level.load() > Info {
 		return
 	}
 
@@ -53,7 +54,7 @@ func (logger *ConsoleLogger) Info(v ...any) {
 }
 
 func (logger *ConsoleLogger) Warn(v ...any) {
-	if logger.level > Warn {
+	if logger.level.load() > Warn {
 		return
 	}
 
@@ -61,7 +62,7 @@ func (logger *ConsoleLogger) Warn(v ...any) {
 }
 
 func (logger *ConsoleLogger) Error(v ...any) {
-	if logger.level > Error {
+	if logger.level.load() > Error {
 		return
 	}
 
@@ -81,7 +82,7 @@ func GetConsoleLoggerInstance() (*ConsoleLogger, error) {
 	if consoleLoggerInstance == nil {
 		once.Do(
 			func() {
-				levelString := k.String("logging.log_level")
+				levelString := config.GetConfig().String("logging.log_level")
 
 				logFlag := log.Ldate | log.Ltime | log.Lshortfile | log.Lmsgprefix
 
@@ -94,7 +95,10 @@ func GetConsoleLoggerInstance() (*ConsoleLogger, error) {
 					fatalLogger: log.New(os.Stdout, fmt.Sprint("[  ", Purple, Fatal, Reset, "  ] --  "), logFlag),
 				}
 
-				consoleLoggerInstance = &ConsoleLogger{*loggers, GetLogLevelByString(levelString)}
+				instance := &ConsoleLogger{loggers: *loggers}
+				instance.level.store(GetLogLevelByString(levelString))
+
+				consoleLoggerInstance = instance
 			})
 	}
 
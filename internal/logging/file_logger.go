@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"log"
 	"os"
 	"sync"
@@ -9,11 +10,11 @@ import (
 
 type FileLogger struct {
 	loggers loggerBundle
-	level   LogLevel
+	level   logLevel
 }
 
 func (logger *FileLogger) Trace(v ...any) {
-	if logger.level > Trace {
+	if logger.level.load() > Trace {
 		return
 	}
 
@@ -21,7 +22,7 @@ func (logger *FileLogger) Trace(v ...any) {
 }
 
 func (logger *FileLogger) Debug(v ...any) {
-	if logger.level > Debug {
+	if logger.level.load() > Debug {
 		return
 	}
 
@@ -29,7 +30,7 @@ func (logger *FileLogger) Debug(v ...any) {
 }
 
 func (logger *FileLogger) Info(v ...any) {
-	if logger.level > Info {
+	if logger.level.load() > Info {
 		return
 	}
 
@@ -37,7 +38,7 @@ func (logger *FileLogger) Info(v ...any) {
 }
 
 func (logger *FileLogger) Warn(v ...any) {
-	if logger.level > Warn {
+	if logger.level.load() > Warn {
 		return
 	}
 
@@ -45,7 +46,7 @@ func (logger *FileLogger) Warn(v ...any) {
 }
 
 func (logger *FileLogger) Error(v ...any) {
-	if logger.level > Error {
+	if logger.level.load() > Error {
 		return
 	}
 
@@ -71,7 +72,7 @@ func GetFileLoggerInstance() (*FileLogger, error) {
 					return
 				}
 
-				levelString := k.String("logging.log_level")
+				levelString := config.GetConfig().String("logging.log_level")
 
 				logFlag := log.Ldate | log.Ltime | log.Lshortfile | log.Lmsgprefix
 				loggers := &loggerBundle{
@@ -83,7 +84,10 @@ func GetFileLoggerInstance() (*FileLogger, error) {
 					fatalLogger: log.New(file, fmt.Sprint("[  ", Fatal, "  ] --  "), logFlag),
 				}
 
-				fileLoggerInstance = &FileLogger{loggers: *loggers, level: GetLogLevelByString(levelString)}
+				instance := &FileLogger{loggers: *loggers}
+				instance.level.store(GetLogLevelByString(levelString))
+
+				fileLoggerInstance = instance
 			})
 	}
 
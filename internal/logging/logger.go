@@ -6,10 +6,9 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-var k = config.GetConfig()
-
 // LOG LEVELS
 
 // LogLevel is an enum type for the available log levels.
@@ -43,6 +42,19 @@ func (level LogLevel) String() string {
 	}
 }
 
+// logLevel holds the log level of a logger, which is changed by SetLogLevel while the logger is in use.
+type logLevel struct {
+	value atomic.Int32
+}
+
+func (level *logLevel) load() LogLevel {
+	return LogLevel(level.value.Load())
+}
+
+func (level *logLevel) store(value LogLevel) {
+	level.value.Store(int32(value))
+}
+
 // GetLogLevelByString returns the LogLevel matching the given string.
 func GetLogLevelByString(level string) LogLevel {
 	switch strings.ToUpper(level) {
@@ -90,6 +102,8 @@ type loggerBundle struct {
 var DefaultLogger Logger
 var defaultLoggerLock sync.RWMutex
 
+var reloadHookOnce sync.Once
+
 func InitLogging() error {
 	method := config.GetConfig().String("logging.method")
 
@@ -98,9 +112,33 @@ func InitLogging() error {
 		return err
 	}
 
+	reloadHookOnce.Do(func() {
+		config.RegisterReloadHook(func() {
+			SetLogLevel(GetLogLevelByString(config.GetConfig().String("logging.log_level")))
+		})
+	})
+
 	return nil
 }
 
+// SetLogLevel changes the log level of all logger instances that have been created.
+func SetLogLevel(level LogLevel) {
+	defaultLoggerLock.Lock()
+	defer defaultLoggerLock.Unlock()
+
+	if consoleLoggerInstance != nil {
+		consoleLoggerInstance.level.store(level)
+	}
+
+	if fileLoggerInstance != nil {
+		fileLoggerInstance.level.store(level)
+	}
+
+	if MultiLoggerInstance != nil {
+		MultiLoggerInstance.level.store(level)
+	}
+}
+
 func GetLogger() Logger {
 	defaultLoggerLock.RLock()
 	defer defaultLoggerLock.RUnlock()
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	"io"
 	"log"
 	"os"
@@ -10,11 +11,11 @@ import (
 
 type MultiLogger struct {
 	loggers loggerBundle
-	level   LogLevel
+	level   logLevel
 }
 
 func (logger *MultiLogger) Trace(v ...any) {
-	if logger.level > Trace {
+	if logger.level.load() > Trace {
 		return
 	}
 
@@ -22,7 +23,7 @@ func (logger *MultiLogger) Trace(v ...any) {
 }
 
 func (logger *MultiLogger) Debug(v ...any) {
-	if logger.level > Debug {
+	if logger.level.load() > Debug {
 		return
 	}
 
@@ -30,7 +31,7 @@ func (logger *MultiLogger) Debug(v ...any) {
 }
 
 func (logger *MultiLogger) Info(v ...any) {
-	if logger.level > Info {
+	if logger.level.load() > Info {
 		return
 	}
 
@@ -38,7 +39,7 @@ func (logger *MultiLogger) Info(v ...any) {
 }
 
 func (logger *MultiLogger) Warn(v ...any) {
-	if logger.level > Warn {
+	if logger.level.load() > Warn {
 		return
 	}
 
@@ -46,7 +47,7 @@ func (logger *MultiLogger) Warn(v ...any) {
 }
 
 func (logger *MultiLogger) Error(v ...any) {
-	if logger.level > Error {
+	if logger.level.load() > Error {
 		return
 	}
 
@@ -74,7 +75,7 @@ func GetMultiLoggerInstance() (*MultiLogger, error) {
 
 				multiWriter := io.MultiWriter(file, os.Stdout)
 
-				levelString := k.String("logging.log_level")
+				levelString := config.GetConfig().String("logging.log_level")
 
 				logFlag := log.Ldate | log.Ltime | log.Lshortfile | log.Lmsgprefix
 				loggers := &loggerBundle{
@@ -86,7 +87,10 @@ func GetMultiLoggerInstance() (*MultiLogger, error) {
 					fatalLogger: log.New(multiWriter, fmt.Sprint("[  ", Fatal, "  ] --  "), logFlag),
 				}
 
-				MultiLoggerInstance = &MultiLogger{loggers: *loggers, level: GetLogLevelByString(levelString)}
+				instance := &MultiLogger{loggers: *loggers}
+				instance.level.store(GetLogLevelByString(levelString))
+
+				MultiLoggerInstance = instance
 			})
 	}
 
@@ -0,0 +1,308 @@
+// Package mqtt mirrors the messages published on selected monitors to an MQTT broker.
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"os"
+	"strings"
+	"time"
+)
+
+var logger logging.Logger
+
+var ErrInvalidConfiguration = errors.New("invalid mqtt configuration")
+
+const (
+	// StatusOnline is published retained on the status topic once the bridge is connected.
+	StatusOnline = "online"
+	// StatusOffline is published retained on the status topic when the bridge disconnects
+	// and registered as last will, so that it is published by the MQTT broker if the connection is lost.
+	StatusOffline = "offline"
+)
+
+// connectTimeout is the time to wait for the MQTT broker to acknowledge a connection or a disconnect.
+const connectTimeout = 10 * time.Second
+
+// connectRetryInterval is the time to wait before retrying to connect to an unreachable MQTT broker.
+const connectRetryInterval = 30 * time.Second
+
+// TLSOptions configure the TLS connection to the MQTT broker.
+type TLSOptions struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Options configure a Bridge.
+type Options struct {
+	// Broker is the URI of the MQTT broker, e.g. tcp://localhost:1883 or ssl://mqtt.example.com:8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// TopicPrefix is prepended to all topics, followed by the hostname of this Excubitor instance.
+	TopicPrefix string
+	// Monitors contains the monitor patterns to mirror.
+	Monitors []string
+	QoS      byte
+	Retained bool
+	TLS      TLSOptions
+}
+
+// Bridge publishes the messages of a pubsub broker to an MQTT broker.
+type Bridge struct {
+	broker     pubsub.Broker
+	options    Options
+	host       string
+	client     paho.Client
+	subscriber *pubsub.Subscriber
+	done       chan bool
+	// retryInterval is the time to wait before retrying to connect, which is connectRetryInterval unless changed by tests.
+	retryInterval time.Duration
+}
+
+// Start creates a Bridge from the configuration and connects it, if the MQTT bridge is enabled.
+func Start() (*Bridge, error) {
+	logger = logging.GetLogger()
+
+	if !config.GetConfig().Bool("mqtt.enabled") {
+		return nil, nil
+	}
+
+	bridge, err := New(ctx.GetContext().GetBroker(), readOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bridge.Start(); err != nil {
+		return nil, err
+	}
+
+	return bridge, nil
+}
+
+// readOptions reads the bridge options from the configuration.
+func readOptions() Options {
+	conf := config.GetConfig()
+
+	return Options{
+		Broker:      conf.String("mqtt.broker"),
+		ClientID:    conf.String("mqtt.client_id"),
+		Username:    conf.String("mqtt.username"),
+		Password:    conf.String("mqtt.password"),
+		TopicPrefix: conf.String("mqtt.topic_prefix"),
+		Monitors:    conf.Strings("mqtt.monitors"),
+		QoS:         byte(conf.Int("mqtt.qos")),
+		Retained:    conf.Bool("mqtt.retained"),
+		TLS: TLSOptions{
+			Enabled:            conf.Bool("mqtt.tls.enabled"),
+			CAFile:             conf.String("mqtt.tls.ca_file"),
+			CertFile:           conf.String("mqtt.tls.cert_file"),
+			KeyFile:            conf.String("mqtt.tls.key_file"),
+			InsecureSkipVerify: conf.Bool("mqtt.tls.insecure_skip_verify"),
+		},
+	}
+}
+
+// New constructs a Bridge mirroring the messages published on broker.
+func New(broker pubsub.Broker, options Options) (*Bridge, error) {
+	logger = logging.GetLogger()
+
+	if options.Broker == "" {
+		return nil, fmt.Errorf("%w: broker is not set", ErrInvalidConfiguration)
+	}
+
+	if options.QoS > 2 {
+		return nil, fmt.Errorf("%w: qos needs to be 0, 1 or 2. Is: %d", ErrInvalidConfiguration, options.QoS)
+	}
+
+	if len(options.Monitors) == 0 {
+		options.Monitors = []string{pubsub.MultiLevelWildcard}
+	}
+
+	for _, pattern := range options.Monitors {
+		if err := pubsub.ValidatePattern(pattern); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidConfiguration, err)
+		}
+	}
+
+	host := identity.GetHostname()
+
+	if options.ClientID == "" {
+		options.ClientID = fmt.Sprintf("excubitor-%s", host)
+	}
+
+	return &Bridge{
+		broker:        broker,
+		options:       options,
+		host:          host,
+		done:          make(chan bool),
+		retryInterval: connectRetryInterval,
+	}, nil
+}
+
+// Topic returns the MQTT topic a monitor is mirrored to, e.g. excubitor/<host>/CPU/Usage for CPU.Usage.
+func (bridge *Bridge) Topic(monitor string) string {
+	return bridge.topic(strings.Split(monitor, pubsub.TopicSeparator)...)
+}
+
+// StatusTopic returns the MQTT topic the online and offline status of this instance is published on.
+func (bridge *Bridge) StatusTopic() string {
+	return bridge.topic("status")
+}
+
+func (bridge *Bridge) topic(levels ...string) string {
+	var parts []string
+	if prefix := strings.Trim(bridge.options.TopicPrefix, "/"); prefix != "" {
+		parts = append(parts, prefix)
+	}
+
+	return strings.Join(append(append(parts, bridge.host), levels...), "/")
+}
+
+// Start starts connecting to the MQTT broker and mirroring the configured monitors.
+// Connecting doesn't block. If the MQTT broker is unreachable, connecting is retried until it succeeds or the bridge
+// is stopped, and messages published in the meantime aren't mirrored.
+func (bridge *Bridge) Start() error {
+	clientOptions, err := bridge.clientOptions()
+	if err != nil {
+		return err
+	}
+
+	bridge.client = paho.NewClient(clientOptions)
+
+	logger.Info(fmt.Sprintf("Connecting to MQTT broker %s...", bridge.options.Broker))
+
+	token := bridge.client.Connect()
+	go func() {
+		// Only completes without error once connected, as failed attempts are retried.
+		token.Wait()
+
+		if err := token.Error(); err != nil {
+			logger.Error(fmt.Sprintf("Could not connect to MQTT broker %s! Reason: %s", bridge.options.Broker, err))
+		}
+	}()
+
+	bridge.subscriber = bridge.broker.AddSubscriber()
+	for _, pattern := range bridge.options.Monitors {
+		if err := bridge.broker.Subscribe(bridge.subscriber, pattern); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		bridge.subscriber.Listen(bridge.publish)
+		bridge.done <- true
+	}()
+
+	return nil
+}
+
+// Stop stops mirroring, publishes the offline status and disconnects from the MQTT broker.
+func (bridge *Bridge) Stop() {
+	bridge.subscriber.Destruct()
+	<-bridge.done
+
+	if !bridge.client.IsConnectionOpen() {
+		// Aborts connecting once the current attempt is over without waiting for it.
+		bridge.client.Disconnect(0)
+		return
+	}
+
+	bridge.client.Publish(bridge.StatusTopic(), bridge.options.QoS, true, StatusOffline).WaitTimeout(connectTimeout)
+	bridge.client.Disconnect(uint(connectTimeout.Milliseconds()))
+}
+
+// clientOptions builds the options of the MQTT client, including the last will and TLS.
+func (bridge *Bridge) clientOptions() (*paho.ClientOptions, error) {
+	clientOptions := paho.NewClientOptions().
+		AddBroker(bridge.options.Broker).
+		SetClientID(bridge.options.ClientID).
+		SetUsername(bridge.options.Username).
+		SetPassword(bridge.options.Password).
+		SetConnectTimeout(connectTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(bridge.retryInterval).
+		SetWill(bridge.StatusTopic(), StatusOffline, bridge.options.QoS, true).
+		SetOnConnectHandler(func(client paho.Client) {
+			logger.Info(fmt.Sprintf("Connected to MQTT broker %s.", bridge.options.Broker))
+
+			// Published on every (re)connect, as the MQTT broker publishes the last will whenever the connection is lost.
+			client.Publish(bridge.StatusTopic(), bridge.options.QoS, true, StatusOnline)
+		}).
+		SetConnectionLostHandler(func(client paho.Client, err error) {
+			logger.Warn(fmt.Sprintf("Lost connection to MQTT broker %s, reconnecting... Reason: %s", bridge.options.Broker, err))
+		})
+
+	if bridge.options.TLS.Enabled {
+		tlsConfig, err := bridge.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	return clientOptions, nil
+}
+
+// tlsConfig loads the CA and client certificates configured for the MQTT connection.
+func (bridge *Bridge) tlsConfig() (*tls.Config, error) {
+	options := bridge.options.TLS
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAFile != "" {
+		ca, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: reading ca file: %s", ErrInvalidConfiguration, err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%w: ca file %s contains no certificates", ErrInvalidConfiguration, options.CAFile)
+		}
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: loading client certificate: %s", ErrInvalidConfiguration, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// publish mirrors a single message to the MQTT broker.
+func (bridge *Bridge) publish(message *pubsub.Message) {
+	topic := bridge.Topic(message.GetMonitor())
+
+	token := bridge.client.Publish(topic, bridge.options.QoS, bridge.options.Retained, message.GetMessageBody())
+
+	// With QoS 0 the token completes once the message has been written, so waiting only guards against a stalled connection.
+	if !token.WaitTimeout(connectTimeout) {
+		logger.Warn(fmt.Sprintf("Timeout while publishing %s to MQTT broker.", topic))
+		return
+	}
+
+	if err := token.Error(); err != nil {
+		logger.Error(fmt.Sprintf("Could not publish %s to MQTT broker! Reason: %s", topic, err))
+	}
+}
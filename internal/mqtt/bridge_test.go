@@ -0,0 +1,257 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	return listener
+}
+
+func TestNewNegative(t *testing.T) {
+	for description, options := range map[string]Options{
+		"Missing broker":  {},
+		"Invalid qos":     {Broker: "tcp://localhost:1883", QoS: 3},
+		"Invalid pattern": {Broker: "tcp://localhost:1883", Monitors: []string{"CPU.#.Usage"}},
+	} {
+		t.Run(description, func(t *testing.T) {
+			_, err := New(pubsub.NewBroker(), options)
+			assert.ErrorIs(t, err, ErrInvalidConfiguration)
+		})
+	}
+}
+
+func TestTopic(t *testing.T) {
+	bridge, err := New(pubsub.NewBroker(), Options{Broker: "tcp://localhost:1883", TopicPrefix: "/excubitor/"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "excubitor/test-host/CPU/Usage", bridge.Topic("CPU.Usage"))
+	assert.Equal(t, "excubitor/test-host/status", bridge.StatusTopic())
+	assert.Equal(t, "excubitor-test-host", bridge.options.ClientID)
+
+	bridge, err = New(pubsub.NewBroker(), Options{Broker: "tcp://localhost:1883"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-host/CPU/Usage", bridge.Topic("CPU.Usage"))
+}
+
+func TestBridge(t *testing.T) {
+	listener := listen(t)
+	fake := newFakeBroker(t, listener)
+	broker := pubsub.NewBroker()
+
+	bridge, err := New(broker, Options{
+		Broker:      "tcp://" + listener.Addr().String(),
+		Username:    "user",
+		Password:    "secret",
+		TopicPrefix: "excubitor",
+		Monitors:    []string{"CPU.*"},
+		QoS:         1,
+		Retained:    true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+
+	assert.Eventually(t, func() bool {
+		return len(fake.getConnects()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	connects := fake.getConnects()
+	require.Len(t, connects, 1)
+	assert.Equal(t, "user", connects[0].Username)
+	assert.Equal(t, []byte("secret"), connects[0].Password)
+	assert.Equal(t, "excubitor-test-host", connects[0].ClientIdentifier)
+	assert.True(t, connects[0].WillFlag)
+	assert.True(t, connects[0].WillRetain)
+	assert.Equal(t, "excubitor/test-host/status", connects[0].WillTopic)
+	assert.Equal(t, []byte(StatusOffline), connects[0].WillMessage)
+
+	require.Eventually(t, bridge.client.IsConnectionOpen, time.Second, 10*time.Millisecond)
+
+	broker.Publish("CPU.Usage", `{"cpu": {"usage": 12.5}}`)
+	broker.Publish("Memory.MemInfo", `{}`)
+
+	assert.Eventually(t, func() bool {
+		return len(fake.getPublished("excubitor/test-host/CPU/Usage")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	published := fake.getPublished("excubitor/test-host/CPU/Usage")[0]
+	assert.Equal(t, `{"cpu": {"usage": 12.5}}`, string(published.Payload))
+	assert.EqualValues(t, 1, published.Qos)
+	assert.True(t, published.Retain)
+
+	bridge.Stop()
+
+	status := fake.getPublished("excubitor/test-host/status")
+	require.Len(t, status, 2)
+	assert.Equal(t, StatusOnline, string(status[0].Payload))
+	assert.Equal(t, StatusOffline, string(status[1].Payload))
+	assert.True(t, status[1].Retain)
+
+	assert.NotContains(t, fake.getTopics(), "excubitor/test-host/Memory/MemInfo")
+}
+
+func TestBridgeLastWill(t *testing.T) {
+	listener := listen(t)
+	fake := newFakeBroker(t, listener)
+
+	bridge, err := New(pubsub.NewBroker(), Options{Broker: "tcp://" + listener.Addr().String(), TopicPrefix: "excubitor"})
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	require.Eventually(t, bridge.client.IsConnectionOpen, time.Second, 10*time.Millisecond)
+
+	fake.dropConnections()
+
+	assert.Eventually(t, func() bool {
+		for _, packet := range fake.getPublished("excubitor/test-host/status") {
+			if string(packet.Payload) == StatusOffline && packet.Retain {
+				return true
+			}
+		}
+
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	bridge.Stop()
+}
+
+func TestBridgeConnectionRefused(t *testing.T) {
+	listener := listen(t)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	bridge, err := New(pubsub.NewBroker(), Options{Broker: "tcp://" + address, TopicPrefix: "excubitor"})
+	require.NoError(t, err)
+
+	bridge.retryInterval = 50 * time.Millisecond
+
+	// An unreachable broker doesn't fail the start, connecting is retried until it is reachable.
+	require.NoError(t, bridge.Start())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, bridge.client.IsConnectionOpen())
+
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+
+	fake := newFakeBroker(t, listener)
+
+	assert.Eventually(t, func() bool {
+		return len(fake.getPublished("excubitor/test-host/status")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	bridge.Stop()
+}
+
+func TestBridgeStopUnreachable(t *testing.T) {
+	listener := listen(t)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	bridge, err := New(pubsub.NewBroker(), Options{Broker: "tcp://" + address})
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+
+	stopped := make(chan bool)
+	go func() {
+		bridge.Stop()
+		stopped <- true
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(connectTimeout):
+		t.Fatal("Stopping the bridge didn't finish in time...")
+	}
+}
+
+func TestBridgeTLS(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	require.NoError(t, err)
+
+	fake := newFakeBroker(t, listener)
+	broker := pubsub.NewBroker()
+
+	bridge, err := New(broker, Options{
+		Broker:      "ssl://" + listener.Addr().String(),
+		TopicPrefix: "excubitor",
+		TLS:         TLSOptions{Enabled: true, CAFile: certFile},
+	})
+	require.NoError(t, err)
+	require.NoError(t, bridge.Start())
+	require.Eventually(t, bridge.client.IsConnectionOpen, time.Second, 10*time.Millisecond)
+
+	broker.Publish("CPU.Usage", `{}`)
+
+	assert.Eventually(t, func() bool {
+		return len(fake.getPublished("excubitor/test-host/CPU/Usage")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	bridge.Stop()
+}
+
+func TestBridgeTLSInvalidCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte("no certificate"), 0600))
+
+	bridge, err := New(pubsub.NewBroker(), Options{Broker: "ssl://localhost:8883", TLS: TLSOptions{Enabled: true, CAFile: caFile}})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, bridge.Start(), ErrInvalidConfiguration)
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its key to a temporary directory.
+func writeCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	encodedKey, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey}), 0600))
+
+	return certFile, keyFile
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"net"
+	"sync"
+	"testing"
+)
+
+// fakeBroker is a minimal in-process MQTT broker recording the packets sent by its clients.
+// It publishes the last will of a client as the real broker would if the connection is lost without a DISCONNECT.
+type fakeBroker struct {
+	listener  net.Listener
+	connects  []*packets.ConnectPacket
+	published []*packets.PublishPacket
+	conns     []net.Conn
+	lock      sync.Mutex
+}
+
+func newFakeBroker(t *testing.T, listener net.Listener) *fakeBroker {
+	broker := &fakeBroker{listener: listener}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			broker.lock.Lock()
+			broker.conns = append(broker.conns, conn)
+			broker.lock.Unlock()
+
+			go broker.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+		broker.dropConnections()
+	})
+
+	return broker
+}
+
+func (broker *fakeBroker) serve(conn net.Conn) {
+	var connect *packets.ConnectPacket
+
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			if connect != nil && connect.WillFlag {
+				will := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+				will.TopicName = connect.WillTopic
+				will.Payload = connect.WillMessage
+				will.Qos = connect.WillQos
+				will.Retain = connect.WillRetain
+
+				broker.record(will)
+			}
+
+			return
+		}
+
+		switch packet := packet.(type) {
+		case *packets.ConnectPacket:
+			connect = packet
+
+			broker.lock.Lock()
+			broker.connects = append(broker.connects, packet)
+			broker.lock.Unlock()
+
+			_ = packets.NewControlPacket(packets.Connack).Write(conn)
+		case *packets.PublishPacket:
+			broker.record(packet)
+
+			switch packet.Qos {
+			case 1:
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = packet.MessageID
+				_ = ack.Write(conn)
+			case 2:
+				rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+				rec.MessageID = packet.MessageID
+				_ = rec.Write(conn)
+			}
+		case *packets.PubrelPacket:
+			comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+			comp.MessageID = packet.MessageID
+			_ = comp.Write(conn)
+		case *packets.PingreqPacket:
+			_ = packets.NewControlPacket(packets.Pingresp).Write(conn)
+		case *packets.DisconnectPacket:
+			_ = conn.Close()
+			return
+		}
+	}
+}
+
+func (broker *fakeBroker) record(packet *packets.PublishPacket) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.published = append(broker.published, packet)
+}
+
+// dropConnections closes all client connections without a DISCONNECT.
+func (broker *fakeBroker) dropConnections() {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	for _, conn := range broker.conns {
+		_ = conn.Close()
+	}
+}
+
+func (broker *fakeBroker) getConnects() []*packets.ConnectPacket {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	return append([]*packets.ConnectPacket{}, broker.connects...)
+}
+
+// getPublished returns all messages published on topic.
+func (broker *fakeBroker) getPublished(topic string) []*packets.PublishPacket {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	var published []*packets.PublishPacket
+	for _, packet := range broker.published {
+		if packet.TopicName == topic {
+			published = append(published, packet)
+		}
+	}
+
+	return published
+}
+
+// getTopics returns the topics of all published messages in order.
+func (broker *fakeBroker) getTopics() []string {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	var topics []string
+	for _, packet := range broker.published {
+		topics = append(topics, packet.TopicName)
+	}
+
+	return topics
+}
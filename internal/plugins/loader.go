@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
 	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/events"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/pkg/shared/modules"
 	"github.com/hashicorp/go-plugin"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
 var logger logging.Logger
@@ -54,7 +57,8 @@ func LoadPlugins() error {
 	return nil
 }
 
-func InitPlugins() error {
+// InitPlugins starts all loaded plugins and registers them as modules publishing their values on broker.
+func InitPlugins(broker pubsub.Publisher) error {
 	for _, pl := range loadablePlugins {
 		client := plugin.NewClient(&plugin.ClientConfig{
 			HandshakeConfig: handshakeConfig,
@@ -80,15 +84,43 @@ func InitPlugins() error {
 		}
 
 		loadedPlugin := rawPlugin.(shared.ModuleProvider)
+		name := loadedPlugin.GetName()
+		path := pl
+
+		events.Emit(events.Event{
+			Type:       events.TypePluginStarted,
+			Severity:   events.SeverityInfo,
+			Source:     "Plugins",
+			Message:    fmt.Sprintf("Plugin %s has been started.", name),
+			Attributes: map[string]string{"plugin": name, "path": path},
+		})
+
+		var crashOnce sync.Once
 
 		module := modules.NewModule(
-			loadedPlugin.GetName(),
+			name,
 			loadedPlugin.GetVersion(),
 			nil,
 			func() {
+				if client.Exited() {
+					crashOnce.Do(func() {
+						logger.Error(fmt.Sprintf("Plugin %s has exited and won't report any values.", name))
+
+						events.Emit(events.Event{
+							Type:       events.TypePluginCrashed,
+							Severity:   events.SeverityError,
+							Source:     "Plugins",
+							Message:    fmt.Sprintf("Plugin %s has exited.", name),
+							Attributes: map[string]string{"plugin": name, "path": path},
+						})
+					})
+
+					return
+				}
+
 				messages := loadedPlugin.TickFunction()
 				for _, msg := range messages {
-					ctx.GetContext().GetBroker().Publish(msg.Monitor, msg.Body)
+					broker.Publish(msg.Monitor, msg.Body)
 				}
 			},
 		)
@@ -1,99 +1,43 @@
 package pubsub
 
-import (
-	"fmt"
-	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
-	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
-	"sync"
-)
-
-var logger logging.Logger
-
-type Subscribers map[string]*Subscriber
-
-// Broker is used to interact with the pubsub architecture
-type Broker struct {
-	subscribers Subscribers
-	monitors    map[string]Subscribers
-	logger      logging.Logger
-	lock        sync.RWMutex
-}
-
-// NewBroker constructs a new Broker
-func NewBroker() *Broker {
-	logger = logging.GetLogger()
-
-	return &Broker{
-		subscribers: Subscribers{},
-		logger:      logging.GetLogger(),
-		monitors:    map[string]Subscribers{},
-	}
+// Publisher publishes messages on monitors. Modules that only report values depend on a Publisher.
+type Publisher interface {
+	// Publish publishes a message on a monitor.
+	Publish(monitor string, message string)
 }
 
-// AddSubscriber adds a new subscriber to the subscriber pool and returns its reference
-func (broker *Broker) AddSubscriber() *Subscriber {
-	broker.lock.Lock()
-	defer broker.lock.Unlock()
-
-	id, subscriber := newSubscriber()
-	broker.logger.Trace(fmt.Sprintf("Adding new subscriber with id %s.", id))
-
-	broker.subscribers[id] = subscriber
-	return subscriber
+// SchemaRegistry keeps the schemas of the messages published on each monitor.
+type SchemaRegistry interface {
+	// RegisterSchema registers the schema of a monitor, replacing a schema registered earlier for the same monitor.
+	RegisterSchema(schema Schema) error
+	// GetSchema returns the schema registered for a monitor and whether there is one.
+	GetSchema(monitor string) (Schema, bool)
+	// GetSchemas returns all registered schemas ordered by monitor.
+	GetSchemas() []Schema
 }
 
-// Subscribe can add a monitor to a given subscriber
-func (broker *Broker) Subscribe(subscriber *Subscriber, monitor string) {
-	broker.lock.Lock()
-	defer broker.lock.Unlock()
-
-	broker.logger.Debug(fmt.Sprintf("Subscribing %s to %s.", subscriber.id, monitor))
-
-	if broker.monitors[monitor] == nil {
-		broker.monitors[monitor] = Subscribers{}
-	}
-
-	subscriber.addMonitor(monitor)
-	broker.monitors[monitor][subscriber.id] = subscriber
+// Broker is used to interact with the pubsub architecture.
+// MemoryBroker is the implementation Excubitor runs on; other implementations,
+// e.g. an instrumented or a networked broker, can wrap or replace it, creating their subscribers with NewSubscriber.
+type Broker interface {
+	Publisher
+	SchemaRegistry
+
+	// AddSubscriber adds a new subscriber using the configured queue size and drop policy.
+	AddSubscriber() *Subscriber
+	// AddSubscriberWithQueue adds a new subscriber with the given queue size and drop policy.
+	AddSubscriberWithQueue(queueSize int, policy DropPolicy) *Subscriber
+	// Subscribe subscribes a subscriber to a monitor or a monitor pattern.
+	Subscribe(subscriber *Subscriber, monitor string) error
+	// Unsubscribe removes a monitor or a monitor pattern from a subscriber.
+	Unsubscribe(subscriber *Subscriber, monitor string)
+
+	// GetRetained returns the last message published on a monitor and whether there is one.
+	GetRetained(monitor string) (*Message, bool)
+	// GetSubscriberCount returns the number of subscribers currently registered.
+	GetSubscriberCount() int
+	// GetDropped returns the number of messages dropped across all subscribers because their queues were full.
+	GetDropped() uint64
 }
 
-// Unsubscribe removes a monitor from a given subscriber
-func (broker *Broker) Unsubscribe(subscriber *Subscriber, monitor string) {
-	broker.lock.Lock()
-	defer broker.lock.Unlock()
-
-	broker.logger.Debug(fmt.Sprintf("Unsubscribing %s from monitor %s.", monitor, subscriber.id))
-
-	delete(broker.monitors[monitor], subscriber.id)
-	subscriber.removeMonitor(monitor)
-}
-
-// Publish publishes messages flagged with a given monitor to the pubsub architecture
-func (broker *Broker) Publish(monitor string, message string) {
-	broker.lock.RLock()
-	subscribers := broker.monitors[monitor]
-	broker.lock.RUnlock()
-
-	broker.logger.Trace(fmt.Sprintf("Publishing message on monitor %s.", monitor))
-
-	m := NewMessage(message, monitor)
-
-	for _, subscriber := range subscribers {
-		m := m // Reassign m so that it cannot be changed while the for loop is running...
-
-		if !subscriber.active {
-			continue
-		}
-
-		go (func(s *Subscriber) {
-			s.signal(m)
-		})(subscriber)
-	}
-
-	err := db.GetWriter().AddHistoryEntry(monitor, message)
-	if err != nil {
-		broker.logger.Error(err)
-		return
-	}
-
-}
+var _ Broker = (*MemoryBroker)(nil)
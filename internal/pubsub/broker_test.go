@@ -1,15 +1,18 @@
 package pubsub
 
 import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
 	"github.com/stretchr/testify/assert"
+	"runtime"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewBroker(t *testing.T) {
 	broker := NewBroker()
 
-	assert.IsType(t, &Broker{}, broker)
+	assert.IsType(t, &MemoryBroker{}, broker)
 }
 
 func TestAddSubscriber(t *testing.T) {
@@ -62,6 +65,8 @@ func TestPublish(t *testing.T) {
 	go sub.Listen(func(message *Message) {
 		assert.Equal(t, message.GetMonitor(), "Monitor")
 		assert.Equal(t, message.GetMessageBody(), "Test Message!")
+		assert.Equal(t, identity.GetHostname(), message.GetHost())
+		assert.NotNil(t, message.GetLabels())
 		wg.Done()
 	})
 
@@ -77,3 +82,155 @@ func TestPublish(t *testing.T) {
 	wg1.Wait()
 
 }
+
+func TestGetSubscriberCount(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriber()
+	sub1 := broker.AddSubscriber()
+
+	broker.Subscribe(sub, "Monitor")
+	assert.Equal(t, 2, broker.GetSubscriberCount())
+
+	sub.Destruct()
+	assert.Equal(t, 1, broker.GetSubscriberCount())
+	assert.Empty(t, broker.monitors.match("Monitor"))
+
+	sub1.Destruct()
+	assert.Equal(t, 0, broker.GetSubscriberCount())
+}
+
+func TestGetRetained(t *testing.T) {
+	broker := NewBroker()
+
+	_, ok := broker.GetRetained("Monitor")
+	assert.False(t, ok)
+
+	before := time.Now()
+	broker.Publish("Monitor", "First Message!")
+	broker.Publish("Monitor", "Second Message!")
+
+	message, ok := broker.GetRetained("Monitor")
+	assert.True(t, ok)
+	assert.Equal(t, "Monitor", message.GetMonitor())
+	assert.Equal(t, "Second Message!", message.GetMessageBody())
+	assert.False(t, message.GetTimestamp().Before(before))
+
+	_, ok = broker.GetRetained("Other Monitor")
+	assert.False(t, ok)
+}
+
+func TestSubscribeInvalidPattern(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriber()
+
+	assert.ErrorIs(t, broker.Subscribe(sub, "CPU.#.Usage"), ErrInvalidPattern)
+	assert.Empty(t, sub.GetMonitors())
+}
+
+func TestPublishPattern(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriber()
+
+	assert.NoError(t, broker.Subscribe(sub, "Pattern.*"))
+	assert.NoError(t, broker.Subscribe(sub, "Pattern.Usage"))
+
+	received := make(chan *Message, 3)
+	go sub.Listen(func(message *Message) {
+		received <- message
+	})
+
+	broker.Publish("Pattern.Usage", "Usage")
+	broker.Publish("Other.Usage", "Other")
+	broker.Publish("Pattern.CpuInfo", "CpuInfo")
+
+	var monitors []string
+	timeout := time.After(1 * time.Second)
+
+	for len(monitors) < 2 {
+		select {
+		case message := <-received:
+			monitors = append(monitors, message.GetMonitor())
+		case <-timeout:
+			t.Fatal("Did not receive all messages in time...")
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"Pattern.Usage", "Pattern.CpuInfo"}, monitors)
+
+	select {
+	case message := <-received:
+		t.Errorf("Received unexpected message on %s.", message.GetMonitor())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribePattern(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriber()
+
+	assert.NoError(t, broker.Subscribe(sub, "CPU.*"))
+	assert.NoError(t, broker.Subscribe(sub, "CPU.Usage"))
+	assert.NoError(t, broker.Subscribe(sub, "Memory.MemInfo"))
+
+	broker.Unsubscribe(sub, "CPU.*")
+	assert.ElementsMatch(t, []string{"Memory.MemInfo"}, sub.GetMonitors())
+
+	assert.NoError(t, broker.Subscribe(sub, "Memory.#"))
+	broker.Unsubscribe(sub, "Memory.#")
+	assert.Empty(t, sub.GetMonitors())
+	assert.Empty(t, broker.monitors.match("Memory.MemInfo"))
+}
+
+func TestPublishStalledSubscriber(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriberWithQueue(16, DropOldest)
+	assert.NoError(t, broker.Subscribe(sub, "Monitor"))
+
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 1000; i++ {
+		broker.Publish("Monitor", "Message")
+	}
+
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+	assert.Len(t, sub.messages, 16)
+	assert.EqualValues(t, 1000-16, sub.GetDropped())
+}
+
+// benchmarkStalledSubscriber publishes to a subscriber that never listens.
+// Memory and goroutines stay constant as the subscriber's queue is bounded.
+func benchmarkStalledSubscriber(b *testing.B, policy DropPolicy) {
+	broker := NewBroker()
+	sub := broker.AddSubscriberWithQueue(DefaultQueueSize, policy)
+	if err := broker.Subscribe(sub, "Monitor"); err != nil {
+		b.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	goroutines := runtime.NumGoroutine()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		broker.Publish("Monitor", "Message")
+	}
+
+	b.StopTimer()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)), "heap-growth-bytes")
+	b.ReportMetric(float64(runtime.NumGoroutine()-goroutines), "goroutine-growth")
+}
+
+func BenchmarkPublishStalledSubscriberDropOldest(b *testing.B) {
+	benchmarkStalledSubscriber(b, DropOldest)
+}
+
+func BenchmarkPublishStalledSubscriberDropNewest(b *testing.B) {
+	benchmarkStalledSubscriber(b, DropNewest)
+}
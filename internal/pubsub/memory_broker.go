@@ -0,0 +1,177 @@
+package pubsub
+
+import (
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/identity"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var logger logging.Logger
+var loggerOnce sync.Once
+
+// initLogger sets the package logger once the logging has been initialized.
+func initLogger() {
+	loggerOnce.Do(func() {
+		logger = logging.GetLogger()
+	})
+}
+
+type Subscribers map[string]*Subscriber
+
+// MemoryBroker is the in-process implementation of Broker. It is the broker Excubitor runs on.
+type MemoryBroker struct {
+	subscribers Subscribers
+	monitors    *topicTrie
+	retained    map[string]*Message
+	schemas     map[string]Schema
+	dropped     atomic.Uint64
+	logger      logging.Logger
+	lock        sync.RWMutex
+}
+
+// NewBroker constructs a new MemoryBroker
+func NewBroker() *MemoryBroker {
+	initLogger()
+
+	return &MemoryBroker{
+		subscribers: Subscribers{},
+		logger:      logging.GetLogger(),
+		monitors:    newTopicTrie(),
+		retained:    map[string]*Message{},
+		schemas:     map[string]Schema{},
+	}
+}
+
+// AddSubscriber adds a new subscriber to the subscriber pool and returns its reference.
+// Its queue size and drop policy are taken from pubsub.queue_size and pubsub.drop_policy.
+func (broker *MemoryBroker) AddSubscriber() *Subscriber {
+	queueSize, policy := readQueueConfig()
+
+	return broker.AddSubscriberWithQueue(queueSize, policy)
+}
+
+// AddSubscriberWithQueue adds a new subscriber with the given queue size and drop policy to the subscriber pool and returns its reference.
+// This is meant for internal consumers that need a larger queue or a different policy than websocket clients.
+func (broker *MemoryBroker) AddSubscriberWithQueue(queueSize int, policy DropPolicy) *Subscriber {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	subscriber := NewSubscriber(queueSize, policy, SubscriberHooks{
+		OnDrop:     func() { broker.dropped.Add(1) },
+		OnDestruct: broker.removeSubscriber,
+	})
+	broker.logger.Trace(fmt.Sprintf("Adding new subscriber with id %s.", subscriber.id))
+
+	broker.subscribers[subscriber.id] = subscriber
+	return subscriber
+}
+
+// removeSubscriber removes a subscriber and all of its subscriptions from the broker
+func (broker *MemoryBroker) removeSubscriber(subscriber *Subscriber) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.logger.Trace(fmt.Sprintf("Removing subscriber with id %s.", subscriber.id))
+
+	delete(broker.subscribers, subscriber.id)
+	for _, monitor := range subscriber.GetMonitors() {
+		broker.monitors.remove(monitor, subscriber)
+	}
+}
+
+// GetSubscriberCount returns the number of subscribers currently registered with the broker
+func (broker *MemoryBroker) GetSubscriberCount() int {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	return len(broker.subscribers)
+}
+
+// GetDropped returns the number of messages dropped across all subscribers because their queues were full
+func (broker *MemoryBroker) GetDropped() uint64 {
+	return broker.dropped.Load()
+}
+
+// Subscribe can add a monitor to a given subscriber.
+// The monitor may be a pattern in which * matches exactly one level and # matches all remaining levels,
+// e.g. CPU.* matches CPU.Usage and Memory.# matches Memory.SwapInfo.
+func (broker *MemoryBroker) Subscribe(subscriber *Subscriber, monitor string) error {
+	if err := ValidatePattern(monitor); err != nil {
+		return err
+	}
+
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.logger.Debug(fmt.Sprintf("Subscribing %s to %s.", subscriber.id, monitor))
+
+	subscriber.AddMonitor(monitor)
+	broker.monitors.insert(monitor, subscriber)
+
+	return nil
+}
+
+// Unsubscribe removes a monitor from a given subscriber.
+// If the monitor is a pattern, all subscriptions of the subscriber to monitors matched by the pattern are removed as well.
+func (broker *MemoryBroker) Unsubscribe(subscriber *Subscriber, monitor string) {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.logger.Debug(fmt.Sprintf("Unsubscribing %s from monitor %s.", subscriber.id, monitor))
+
+	for _, subscribed := range subscriber.GetMonitors() {
+		if subscribed != monitor && (IsPattern(subscribed) || !MatchTopic(monitor, subscribed)) {
+			continue
+		}
+
+		broker.monitors.remove(subscribed, subscriber)
+		subscriber.RemoveMonitor(subscribed)
+	}
+}
+
+// SourceOf returns the module a monitor belongs to if no schema names it, i.e. the first level of the monitor name.
+func SourceOf(monitor string) string {
+	source, _, _ := strings.Cut(monitor, TopicSeparator)
+	return source
+}
+
+// GetRetained returns the last message published on a monitor.
+// The second return value is false if nothing has been published on the monitor yet.
+func (broker *MemoryBroker) GetRetained(monitor string) (*Message, bool) {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	message, ok := broker.retained[monitor]
+	return message, ok
+}
+
+// Publish publishes messages flagged with a given monitor to the pubsub architecture.
+// The message is retained as the last value of the monitor until the next message is published on it.
+func (broker *MemoryBroker) Publish(monitor string, message string) {
+	broker.logger.Trace(fmt.Sprintf("Publishing message on monitor %s.", monitor))
+
+	m := NewMessage(message, monitor)
+
+	host := identity.Get()
+	m.host = host.Hostname
+	m.labels = host.Labels
+
+	broker.lock.Lock()
+	m.source = SourceOf(monitor)
+	if schema, ok := broker.schemas[monitor]; ok {
+		m.source = schema.Source
+		m.contentType = schema.ContentType
+		m.schemaVersion = schema.Version
+	}
+
+	subscribers := broker.monitors.match(monitor)
+	broker.retained[monitor] = m
+	broker.lock.Unlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.Signal(m)
+	}
+}
@@ -1,14 +1,23 @@
 package pubsub
 
+import "time"
+
 // Message is used to model pubsub messages
 type Message struct {
-	monitor string
-	body    string
+	monitor   string
+	body      string
+	host      string
+	labels    map[string]string
+	timestamp time.Time
+
+	source        string
+	contentType   string
+	schemaVersion int
 }
 
 // NewMessage constructs a new message
 func NewMessage(message string, monitor string) *Message {
-	return &Message{monitor, message}
+	return &Message{monitor: monitor, body: message, timestamp: time.Now(), contentType: ContentTypeJSON}
 }
 
 // GetMonitor gives back the name of the monitor the message was flagged with
@@ -20,3 +29,34 @@ func (message *Message) GetMonitor() string {
 func (message *Message) GetMessageBody() string {
 	return message.body
 }
+
+// GetTimestamp returns the time the message has been published at
+func (message *Message) GetTimestamp() time.Time {
+	return message.timestamp
+}
+
+// GetSource returns the name of the module that published the message
+func (message *Message) GetSource() string {
+	return message.source
+}
+
+// GetContentType returns the content type of the message body
+func (message *Message) GetContentType() string {
+	return message.contentType
+}
+
+// GetSchemaVersion returns the version of the schema the message body conforms to.
+// It is 0 if no schema has been registered for the monitor.
+func (message *Message) GetSchemaVersion() int {
+	return message.schemaVersion
+}
+
+// GetHost returns the hostname of the Excubitor instance that published the message
+func (message *Message) GetHost() string {
+	return message.host
+}
+
+// GetLabels returns the labels of the Excubitor instance that published the message
+func (message *Message) GetLabels() map[string]string {
+	return message.labels
+}
@@ -0,0 +1,279 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/jsonfields"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PublishMode decides which messages published on a monitor are forwarded by a FilteringBroker.
+type PublishMode string
+
+const (
+	// PublishAlways forwards every message. It exempts monitors from policies with broader patterns listed after it.
+	PublishAlways PublishMode = "always"
+	// PublishOnChange forwards a message only if its body differs from the last forwarded one.
+	PublishOnChange PublishMode = "on_change"
+	// PublishDeadband forwards a message only if one of its numeric fields moved by more than the deadband
+	// or any other field changed since the last forwarded message.
+	PublishDeadband PublishMode = "deadband"
+)
+
+var ErrInvalidPublishPolicy = errors.New("invalid publish policy")
+
+// PublishPolicy configures which messages published on the monitors matching a pattern are forwarded.
+type PublishPolicy struct {
+	// Monitor is a monitor pattern as used for subscriptions.
+	Monitor string      `koanf:"monitor"`
+	Mode    PublishMode `koanf:"mode"`
+	// Deadband is the absolute change of a numeric field needed for a message to be forwarded in PublishDeadband mode.
+	Deadband float64 `koanf:"deadband"`
+	// Heartbeat forwards a message regardless of the mode once this much time has passed since the last forwarded one.
+	// Zero disables the heartbeat.
+	Heartbeat time.Duration `koanf:"heartbeat"`
+}
+
+// validate checks whether the policy can be applied.
+func (policy PublishPolicy) validate() error {
+	if err := ValidatePattern(policy.Monitor); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPublishPolicy, err)
+	}
+
+	switch policy.Mode {
+	case PublishAlways, PublishOnChange, PublishDeadband:
+	default:
+		return fmt.Errorf("%w: mode of %s needs to be one of always, on_change or deadband. Is: %s", ErrInvalidPublishPolicy, policy.Monitor, policy.Mode)
+	}
+
+	if policy.Deadband < 0 {
+		return fmt.Errorf("%w: deadband of %s needs to be positive", ErrInvalidPublishPolicy, policy.Monitor)
+	}
+
+	if policy.Heartbeat < 0 {
+		return fmt.Errorf("%w: heartbeat of %s needs to be positive", ErrInvalidPublishPolicy, policy.Monitor)
+	}
+
+	return nil
+}
+
+// forwarded is the last message forwarded on a monitor.
+type forwarded struct {
+	body      string
+	timestamp time.Time
+}
+
+// FilteringBroker wraps a Broker and drops published messages that don't add information according to the
+// PublishPolicy of their monitor. Monitors without a policy are forwarded unchanged.
+// Dropped messages don't replace the retained value, but as it was still current when they were published,
+// GetRetained reports it with the timestamp of the last dropped message.
+type FilteringBroker struct {
+	Broker
+	policies   []PublishPolicy
+	last       map[string]forwarded
+	observed   map[string]time.Time
+	suppressed atomic.Uint64
+	lock       sync.Mutex
+}
+
+// NewFilteringBroker constructs a FilteringBroker applying policies to the messages published on broker.
+// For each monitor, the first policy whose pattern matches it applies.
+func NewFilteringBroker(broker Broker, policies []PublishPolicy) (*FilteringBroker, error) {
+	filtering := &FilteringBroker{
+		Broker:   broker,
+		last:     map[string]forwarded{},
+		observed: map[string]time.Time{},
+	}
+
+	if err := filtering.SetPolicies(policies); err != nil {
+		return nil, err
+	}
+
+	return filtering, nil
+}
+
+// NewFilteringBrokerFromConfig constructs a FilteringBroker applying the policies in pubsub.publish_policies.
+// Changed policies are applied on configuration reloads.
+func NewFilteringBrokerFromConfig(broker Broker) (*FilteringBroker, error) {
+	policies, err := readPublishPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	filtering, err := NewFilteringBroker(broker, policies)
+	if err != nil {
+		return nil, err
+	}
+
+	config.RegisterReloadHook(func() {
+		policies, err := readPublishPolicies()
+		if err == nil {
+			err = filtering.SetPolicies(policies)
+		}
+
+		if err != nil {
+			logger.Error(fmt.Sprintf("Could not apply publish policies, keeping the current ones! Reason: %s", err))
+		}
+	})
+
+	return filtering, nil
+}
+
+// readPublishPolicies reads the publish policies from the configuration.
+func readPublishPolicies() ([]PublishPolicy, error) {
+	var policies []PublishPolicy
+	if err := config.GetConfig().Unmarshal("pubsub.publish_policies", &policies); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPublishPolicy, err)
+	}
+
+	return policies, nil
+}
+
+// SetPolicies replaces the policies of the broker. Invalid policies are rejected and the current ones are kept.
+func (broker *FilteringBroker) SetPolicies(policies []PublishPolicy) error {
+	for _, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+	}
+
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.policies = append([]PublishPolicy{}, policies...)
+
+	return nil
+}
+
+// GetSuppressed returns the number of messages that have not been forwarded because of their publish policy.
+func (broker *FilteringBroker) GetSuppressed() uint64 {
+	return broker.suppressed.Load()
+}
+
+// Publish forwards the message to the wrapped broker if the publish policy of its monitor permits it.
+func (broker *FilteringBroker) Publish(monitor string, message string) {
+	if !broker.admit(monitor, message, time.Now()) {
+		broker.suppressed.Add(1)
+		logger.Trace(fmt.Sprintf("Suppressing message on monitor %s as it didn't change.", monitor))
+		return
+	}
+
+	broker.Broker.Publish(monitor, message)
+}
+
+// GetRetained returns the last message forwarded on a monitor. If messages have been dropped since, it is returned
+// with the timestamp of the last dropped one.
+func (broker *FilteringBroker) GetRetained(monitor string) (*Message, bool) {
+	message, ok := broker.Broker.GetRetained(monitor)
+	if !ok {
+		return nil, false
+	}
+
+	broker.lock.Lock()
+	observed, seen := broker.observed[monitor]
+	broker.lock.Unlock()
+
+	if !seen || !observed.After(message.GetTimestamp()) {
+		return message, true
+	}
+
+	// Retained messages are shared with the subscribers, so the copy is changed instead.
+	refreshed := *message
+	refreshed.timestamp = observed
+
+	return &refreshed, true
+}
+
+// admit decides whether a message is forwarded and remembers it as the last forwarded message if so.
+// Otherwise, it remembers when the message was dropped.
+func (broker *FilteringBroker) admit(monitor string, message string, now time.Time) bool {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	policy, ok := broker.policyOf(monitor)
+	if !ok || policy.Mode == PublishAlways {
+		return true
+	}
+
+	last, seen := broker.last[monitor]
+
+	admitted := !seen ||
+		(policy.Heartbeat > 0 && now.Sub(last.timestamp) >= policy.Heartbeat) ||
+		changed(policy, last.body, message)
+
+	if admitted {
+		broker.last[monitor] = forwarded{body: message, timestamp: now}
+		delete(broker.observed, monitor)
+	} else {
+		broker.observed[monitor] = now
+	}
+
+	return admitted
+}
+
+// policyOf returns the first policy matching the monitor. The caller has to hold the lock.
+func (broker *FilteringBroker) policyOf(monitor string) (PublishPolicy, bool) {
+	for _, policy := range broker.policies {
+		if MatchTopic(policy.Monitor, monitor) {
+			return policy, true
+		}
+	}
+
+	return PublishPolicy{}, false
+}
+
+// changed reports whether message differs from the last forwarded one according to the policy's mode.
+func changed(policy PublishPolicy, last string, message string) bool {
+	if last == message {
+		return false
+	}
+
+	if policy.Mode != PublishDeadband {
+		return true
+	}
+
+	lastValue, err := jsonfields.Parse(last)
+	if err != nil {
+		return true
+	}
+
+	value, err := jsonfields.Parse(message)
+	if err != nil {
+		return true
+	}
+
+	lastLeaves := jsonfields.Flatten(lastValue)
+	leaves := jsonfields.Flatten(value)
+
+	if len(lastLeaves) != len(leaves) {
+		return true
+	}
+
+	for path, leaf := range leaves {
+		lastLeaf, ok := lastLeaves[path]
+		if !ok {
+			return true
+		}
+
+		number, isNumber := leaf.(float64)
+		lastNumber, lastIsNumber := lastLeaf.(float64)
+
+		if isNumber && lastIsNumber {
+			if math.Abs(number-lastNumber) > policy.Deadband {
+				return true
+			}
+
+			continue
+		}
+
+		if leaf != lastLeaf {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewFilteringBrokerNegative(t *testing.T) {
+	for description, policy := range map[string]PublishPolicy{
+		"Invalid pattern":    {Monitor: "CPU.#.Usage", Mode: PublishOnChange},
+		"Invalid mode":       {Monitor: "CPU.Usage", Mode: "sometimes"},
+		"Negative deadband":  {Monitor: "CPU.Usage", Mode: PublishDeadband, Deadband: -1},
+		"Negative heartbeat": {Monitor: "CPU.Usage", Mode: PublishOnChange, Heartbeat: -time.Second},
+	} {
+		t.Run(description, func(t *testing.T) {
+			_, err := NewFilteringBroker(NewBroker(), []PublishPolicy{policy})
+			assert.ErrorIs(t, err, ErrInvalidPublishPolicy)
+		})
+	}
+}
+
+func TestNewFilteringBrokerFromConfig(t *testing.T) {
+	broker, err := NewFilteringBrokerFromConfig(NewBroker())
+	require.NoError(t, err)
+
+	assert.Equal(t, []PublishPolicy{{Monitor: "Static.*", Mode: PublishOnChange, Heartbeat: 10 * time.Minute}}, broker.policies)
+}
+
+func TestFilteringBrokerOnChange(t *testing.T) {
+	recording := NewRecordingBroker(NewBroker())
+	broker, err := NewFilteringBroker(recording, []PublishPolicy{
+		{Monitor: "CPU.CpuInfo", Mode: PublishOnChange},
+	})
+	require.NoError(t, err)
+
+	for _, message := range []string{`{"model": "A"}`, `{"model": "A"}`, `{"model": "B"}`, `{"model": "B"}`} {
+		broker.Publish("CPU.CpuInfo", message)
+		broker.Publish("CPU.Usage", message)
+	}
+
+	assert.Equal(t, []string{`{"model": "A"}`, `{"model": "B"}`}, recording.GetPublishedOn("CPU.CpuInfo"))
+	assert.Len(t, recording.GetPublishedOn("CPU.Usage"), 4)
+	assert.Equal(t, uint64(2), broker.GetSuppressed())
+
+	retained, ok := broker.GetRetained("CPU.CpuInfo")
+	require.True(t, ok)
+	assert.Equal(t, `{"model": "B"}`, retained.GetMessageBody())
+}
+
+func TestFilteringBrokerRetained(t *testing.T) {
+	broker, err := NewFilteringBroker(NewBroker(), []PublishPolicy{
+		{Monitor: "CPU.CpuInfo", Mode: PublishOnChange},
+	})
+	require.NoError(t, err)
+
+	broker.Publish("CPU.CpuInfo", `{"model": "A"}`)
+
+	published, ok := broker.GetRetained("CPU.CpuInfo")
+	require.True(t, ok)
+
+	time.Sleep(10 * time.Millisecond)
+	broker.Publish("CPU.CpuInfo", `{"model": "A"}`)
+
+	// The unchanged value is reported as current as of the suppressed message.
+	retained, ok := broker.GetRetained("CPU.CpuInfo")
+	require.True(t, ok)
+	assert.Equal(t, `{"model": "A"}`, retained.GetMessageBody())
+	assert.True(t, retained.GetTimestamp().After(published.GetTimestamp()))
+
+	// The message delivered to subscribers isn't changed.
+	original, ok := broker.Broker.GetRetained("CPU.CpuInfo")
+	require.True(t, ok)
+	assert.Equal(t, published.GetTimestamp(), original.GetTimestamp())
+
+	time.Sleep(10 * time.Millisecond)
+	broker.Publish("CPU.CpuInfo", `{"model": "B"}`)
+
+	retained, ok = broker.GetRetained("CPU.CpuInfo")
+	require.True(t, ok)
+	assert.Equal(t, `{"model": "B"}`, retained.GetMessageBody())
+
+	_, ok = broker.GetRetained("CPU.Usage")
+	assert.False(t, ok)
+}
+
+func TestFilteringBrokerDeadband(t *testing.T) {
+	broker, err := NewFilteringBroker(NewBroker(), []PublishPolicy{
+		{Monitor: "Memory.*", Mode: PublishDeadband, Deadband: 10},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	for _, test := range []struct {
+		message  string
+		admitted bool
+	}{
+		{`{"free": 100, "unit": "kB"}`, true},
+		{`{"free": 105, "unit": "kB"}`, false},
+		// Compared to the last forwarded message, not the last published one.
+		{`{"free": 110, "unit": "kB"}`, false},
+		{`{"free": 111, "unit": "kB"}`, true},
+		{`{"free": 111, "unit": "MB"}`, true},
+		{`{"free": 111, "unit": "MB", "total": 200}`, true},
+		{`{"free": 101, "unit": "MB", "total": 200}`, false},
+		{`not json`, true},
+	} {
+		assert.Equal(t, test.admitted, broker.admit("Memory.MemInfo", test.message, now), test.message)
+	}
+}
+
+func TestFilteringBrokerHeartbeat(t *testing.T) {
+	broker, err := NewFilteringBroker(NewBroker(), []PublishPolicy{
+		{Monitor: "CPU.Usage", Mode: PublishAlways},
+		{Monitor: "CPU.*", Mode: PublishOnChange, Heartbeat: time.Minute},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	assert.True(t, broker.admit("CPU.CpuInfo", "static", now))
+	assert.False(t, broker.admit("CPU.CpuInfo", "static", now.Add(59*time.Second)))
+	assert.True(t, broker.admit("CPU.CpuInfo", "static", now.Add(time.Minute)))
+	assert.False(t, broker.admit("CPU.CpuInfo", "static", now.Add(90*time.Second)))
+
+	// The first matching policy applies, so CPU.Usage is exempt from the broader pattern.
+	assert.True(t, broker.admit("CPU.Usage", "static", now))
+	assert.True(t, broker.admit("CPU.Usage", "static", now))
+}
+
+func TestFilteringBrokerSetPolicies(t *testing.T) {
+	broker, err := NewFilteringBroker(NewBroker(), nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	assert.True(t, broker.admit("CPU.CpuInfo", "static", now))
+	assert.True(t, broker.admit("CPU.CpuInfo", "static", now))
+
+	require.NoError(t, broker.SetPolicies([]PublishPolicy{{Monitor: "CPU.CpuInfo", Mode: PublishOnChange}}))
+	assert.True(t, broker.admit("CPU.CpuInfo", "static", now))
+	assert.False(t, broker.admit("CPU.CpuInfo", "static", now))
+
+	assert.ErrorIs(t, broker.SetPolicies([]PublishPolicy{{Monitor: "CPU.CpuInfo", Mode: "never"}}), ErrInvalidPublishPolicy)
+	assert.False(t, broker.admit("CPU.CpuInfo", "static", now))
+}
@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+)
+
+// DropPolicy decides what happens to messages published to a subscriber whose queue is full.
+type DropPolicy string
+
+const (
+	// DropOldest discards the oldest queued message to make room for the new one.
+	DropOldest DropPolicy = "drop_oldest"
+	// DropNewest discards the new message and keeps the queued ones.
+	DropNewest DropPolicy = "drop_newest"
+	// DropDisconnect discards the new message and destructs the subscriber.
+	DropDisconnect DropPolicy = "disconnect"
+)
+
+// DefaultQueueSize is used whenever pubsub.queue_size is not set to a positive value.
+const DefaultQueueSize = 64
+
+var ErrInvalidDropPolicy = errors.New("invalid drop policy")
+
+// ParseDropPolicy parses a DropPolicy from its configuration value.
+func ParseDropPolicy(policy string) (DropPolicy, error) {
+	switch DropPolicy(policy) {
+	case DropOldest, DropNewest, DropDisconnect:
+		return DropPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidDropPolicy, policy)
+	}
+}
+
+// readQueueConfig reads the queue size and drop policy for new subscribers from the configuration.
+// Invalid values fall back to the defaults so that subscribers can always be created.
+func readQueueConfig() (int, DropPolicy) {
+	queueSize := config.GetConfig().Int("pubsub.queue_size")
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	policy, err := ParseDropPolicy(config.GetConfig().String("pubsub.drop_policy"))
+	if err != nil {
+		policy = DropOldest
+	}
+
+	return queueSize, policy
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import "sync"
+
+// Publication is a message published on a RecordingBroker.
+type Publication struct {
+	Monitor string
+	Message string
+}
+
+// RecordingBroker wraps a Broker and records every message published on it.
+// It is meant for tests that need to check what a module publishes without subscribing to it.
+type RecordingBroker struct {
+	Broker
+	published []Publication
+	lock      sync.RWMutex
+}
+
+// NewRecordingBroker constructs a RecordingBroker forwarding to the given broker.
+func NewRecordingBroker(broker Broker) *RecordingBroker {
+	return &RecordingBroker{
+		Broker: broker,
+	}
+}
+
+// Publish records the message and publishes it on the wrapped broker.
+func (broker *RecordingBroker) Publish(monitor string, message string) {
+	broker.lock.Lock()
+	broker.published = append(broker.published, Publication{Monitor: monitor, Message: message})
+	broker.lock.Unlock()
+
+	broker.Broker.Publish(monitor, message)
+}
+
+// GetPublished returns all recorded publications in the order they were published.
+func (broker *RecordingBroker) GetPublished() []Publication {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	return append([]Publication{}, broker.published...)
+}
+
+// GetPublishedOn returns the recorded messages published on the given monitor in the order they were published.
+func (broker *RecordingBroker) GetPublishedOn(monitor string) []string {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	var messages []string
+	for _, publication := range broker.published {
+		if publication.Monitor == monitor {
+			messages = append(messages, publication.Message)
+		}
+	}
+
+	return messages
+}
+
+// Reset discards all recorded publications.
+func (broker *RecordingBroker) Reset() {
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.published = nil
+}
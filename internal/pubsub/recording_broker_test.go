@@ -0,0 +1,41 @@
+package pubsub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRecordingBroker(t *testing.T) {
+	broker := NewRecordingBroker(NewBroker())
+
+	sub := broker.AddSubscriber()
+	defer sub.Destruct()
+	assert.NoError(t, broker.Subscribe(sub, "Some.*"))
+
+	broker.Publish("Some.Monitor", "First")
+	broker.Publish("Other.Monitor", "Second")
+	broker.Publish("Some.Monitor", "Third")
+
+	assert.Equal(t, []Publication{
+		{Monitor: "Some.Monitor", Message: "First"},
+		{Monitor: "Other.Monitor", Message: "Second"},
+		{Monitor: "Some.Monitor", Message: "Third"},
+	}, broker.GetPublished())
+	assert.Equal(t, []string{"First", "Third"}, broker.GetPublishedOn("Some.Monitor"))
+
+	// Messages are still delivered and retained by the wrapped broker.
+	select {
+	case message := <-sub.messages:
+		assert.Equal(t, "First", message.GetMessageBody())
+	case <-time.After(time.Second):
+		t.Fatal("Wrapped broker didn't deliver the message in time...")
+	}
+
+	retained, ok := broker.GetRetained("Other.Monitor")
+	assert.True(t, ok)
+	assert.Equal(t, "Second", retained.GetMessageBody())
+
+	broker.Reset()
+	assert.Empty(t, broker.GetPublished())
+}
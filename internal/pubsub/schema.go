@@ -0,0 +1,172 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ContentTypeJSON is the content type of all messages whose monitor has no schema declaring a different one.
+const ContentTypeJSON = "application/json"
+
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema describes the messages published on a monitor.
+type Schema struct {
+	// Monitor is the name of the monitor the schema applies to.
+	Monitor string `json:"monitor"`
+	// Source is the name of the module publishing on the monitor.
+	Source string `json:"source"`
+	// ContentType is the content type of the message bodies. Defaults to ContentTypeJSON.
+	ContentType string `json:"content_type"`
+	// Version is increased whenever the shape of the message bodies changes.
+	Version int `json:"version"`
+	// Schema is a JSON Schema describing the message bodies.
+	Schema json.RawMessage `json:"schema"`
+	// NumericFields lists the field paths, as understood by jsonfields, of numeric values that are stored as series
+	// next to the message bodies, so that queries on them don't need to decode the bodies, i.e. "cpu*.usage".
+	NumericFields []string `json:"numeric_fields,omitempty"`
+}
+
+// RegisterSchema registers the schema of a monitor. A schema registered earlier for the same monitor is replaced.
+func (broker *MemoryBroker) RegisterSchema(schema Schema) error {
+	if err := ValidatePattern(schema.Monitor); err != nil || IsPattern(schema.Monitor) {
+		return fmt.Errorf("%w: %s is not a valid monitor", ErrInvalidSchema, schema.Monitor)
+	}
+
+	if schema.Source == "" {
+		return fmt.Errorf("%w: source of %s is not set", ErrInvalidSchema, schema.Monitor)
+	}
+
+	if schema.Version < 1 {
+		return fmt.Errorf("%w: version of %s needs to be at least 1", ErrInvalidSchema, schema.Monitor)
+	}
+
+	if !json.Valid(schema.Schema) {
+		return fmt.Errorf("%w: schema of %s is not valid JSON", ErrInvalidSchema, schema.Monitor)
+	}
+
+	for _, field := range schema.NumericFields {
+		if !validFieldPath(field) {
+			return fmt.Errorf("%w: numeric field %q of %s is not a valid field path", ErrInvalidSchema, field, schema.Monitor)
+		}
+	}
+
+	if schema.ContentType == "" {
+		schema.ContentType = ContentTypeJSON
+	}
+
+	broker.lock.Lock()
+	defer broker.lock.Unlock()
+
+	broker.logger.Debug(fmt.Sprintf("Registering schema version %d of monitor %s.", schema.Version, schema.Monitor))
+
+	broker.schemas[schema.Monitor] = schema
+
+	return nil
+}
+
+// validFieldPath reports whether every dot-separated segment of a field path is a non-empty, valid glob pattern.
+func validFieldPath(field string) bool {
+	for _, segment := range strings.Split(field, ".") {
+		if _, err := path.Match(segment, ""); segment == "" || err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetSchema returns the schema registered for a monitor.
+// The second return value is false if no schema has been registered for the monitor.
+func (broker *MemoryBroker) GetSchema(monitor string) (Schema, bool) {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	schema, ok := broker.schemas[monitor]
+	return schema, ok
+}
+
+// GetSchemas returns all registered schemas ordered by monitor.
+func (broker *MemoryBroker) GetSchemas() []Schema {
+	broker.lock.RLock()
+	defer broker.lock.RUnlock()
+
+	schemas := make([]Schema, 0, len(broker.schemas))
+	for _, schema := range broker.schemas {
+		schemas = append(schemas, schema)
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Monitor < schemas[j].Monitor
+	})
+
+	return schemas
+}
+
+// SchemaFromValue describes the Go type of value as a JSON Schema.
+// Struct fields are named after their json tags, so the schema matches what encoding/json produces for the value.
+func SchemaFromValue(value any) json.RawMessage {
+	schema, err := json.Marshal(describeType(reflect.TypeOf(value)))
+	if err != nil {
+		// The description consists of maps, slices and strings only, so marshalling it can't fail.
+		panic(err)
+	}
+
+	return schema
+}
+
+// describeType builds the JSON Schema of a Go type.
+func describeType(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return describeType(t.Elem())
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": describeType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": describeType(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := field.Name
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				tagName, _, _ := strings.Cut(tag, ",")
+				if tagName == "-" {
+					continue
+				}
+
+				if tagName != "" {
+					name = tagName
+				}
+			}
+
+			properties[name] = describeType(field.Type)
+		}
+
+		return map[string]any{"type": "object", "properties": properties}
+	default:
+		return map[string]any{}
+	}
+}
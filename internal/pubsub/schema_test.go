@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type schemaTestValue struct {
+	Name     string            `json:"name"`
+	Usage    float64           `json:"usage"`
+	Count    uint              `json:"count,omitempty"`
+	Enabled  bool              `json:"enabled"`
+	Tags     []string          `json:"tags"`
+	Children map[string]int64  `json:"children"`
+	Nested   *schemaTestNested `json:"nested"`
+	Ignored  string            `json:"-"`
+	Untagged string
+	hidden   string
+}
+
+type schemaTestNested struct {
+	Value float32 `json:"value"`
+}
+
+func TestSchemaFromValue(t *testing.T) {
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"usage": {"type": "number"},
+			"count": {"type": "integer"},
+			"enabled": {"type": "boolean"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"children": {"type": "object", "additionalProperties": {"type": "integer"}},
+			"nested": {"type": "object", "properties": {"value": {"type": "number"}}},
+			"Untagged": {"type": "string"}
+		}
+	}`, string(SchemaFromValue(schemaTestValue{hidden: ""})))
+}
+
+func TestRegisterSchema(t *testing.T) {
+	broker := NewBroker()
+
+	err := broker.RegisterSchema(Schema{Monitor: "Test.Value", Source: "Test", Version: 2, Schema: SchemaFromValue(schemaTestNested{})})
+	assert.NoError(t, err)
+
+	schema, ok := broker.GetSchema("Test.Value")
+	assert.True(t, ok)
+	assert.Equal(t, ContentTypeJSON, schema.ContentType)
+	assert.Equal(t, 2, schema.Version)
+
+	assert.NoError(t, broker.RegisterSchema(Schema{Monitor: "Test.Value", Source: "Test", Version: 3, Schema: []byte(`{}`), NumericFields: []string{"cpu*.usage", "load"}}))
+	schema, _ = broker.GetSchema("Test.Value")
+	assert.Equal(t, []string{"cpu*.usage", "load"}, schema.NumericFields)
+
+	_, ok = broker.GetSchema("Test.Other")
+	assert.False(t, ok)
+
+	assert.NoError(t, broker.RegisterSchema(Schema{Monitor: "A.Value", Source: "A", Version: 1, Schema: []byte(`{}`)}))
+	schemas := broker.GetSchemas()
+	assert.Len(t, schemas, 2)
+	assert.Equal(t, "A.Value", schemas[0].Monitor)
+	assert.Equal(t, "Test.Value", schemas[1].Monitor)
+}
+
+func TestRegisterSchemaNegative(t *testing.T) {
+	for description, schema := range map[string]Schema{
+		"Pattern":        {Monitor: "Test.*", Source: "Test", Version: 1, Schema: []byte(`{}`)},
+		"Missing source": {Monitor: "Test.Value", Version: 1, Schema: []byte(`{}`)},
+		"Version":        {Monitor: "Test.Value", Source: "Test", Schema: []byte(`{}`)},
+		"Invalid JSON":   {Monitor: "Test.Value", Source: "Test", Version: 1, Schema: []byte(`{`)},
+		"Empty segment":  {Monitor: "Test.Value", Source: "Test", Version: 1, Schema: []byte(`{}`), NumericFields: []string{"cpu0..usage"}},
+		"Invalid glob":   {Monitor: "Test.Value", Source: "Test", Version: 1, Schema: []byte(`{}`), NumericFields: []string{"cpu[.usage"}},
+	} {
+		t.Run(description, func(t *testing.T) {
+			assert.ErrorIs(t, NewBroker().RegisterSchema(schema), ErrInvalidSchema)
+		})
+	}
+}
+
+func TestPublishEnvelope(t *testing.T) {
+	broker := NewBroker()
+
+	err := broker.RegisterSchema(Schema{Monitor: "Test.Value", Source: "TestModule", ContentType: "text/plain", Version: 3, Schema: []byte(`{"type": "string"}`)})
+	assert.NoError(t, err)
+
+	before := time.Now()
+	broker.Publish("Test.Value", "Some value")
+	broker.Publish("Other.Value", "{}")
+
+	message, _ := broker.GetRetained("Test.Value")
+	assert.Equal(t, "TestModule", message.GetSource())
+	assert.Equal(t, "text/plain", message.GetContentType())
+	assert.Equal(t, 3, message.GetSchemaVersion())
+	assert.False(t, message.GetTimestamp().Before(before))
+
+	message, _ = broker.GetRetained("Other.Value")
+	assert.Equal(t, "Other", message.GetSource())
+	assert.Equal(t, ContentTypeJSON, message.GetContentType())
+	assert.Equal(t, 0, message.GetSchemaVersion())
+}
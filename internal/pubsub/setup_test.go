@@ -2,7 +2,6 @@ package pubsub
 
 import (
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
-	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
 	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
 	"github.com/knadh/koanf/providers/confmap"
 	"os"
@@ -11,12 +10,11 @@ import (
 
 func TestMain(m *testing.M) {
 	err := config.GetConfig().Load(confmap.Provider(map[string]interface{}{
-		"logging.log_level":  "TRACE",
-		"logging.method":     "CONSOLE",
-		"data.module_clock":  "5s",
-		"data.storage_time":  "720h",
-		"data.purge_cycle":   "1h",
-		"data.database_file": "history_test.db",
+		"logging.log_level": "TRACE",
+		"logging.method":    "CONSOLE",
+		"pubsub.publish_policies": []map[string]interface{}{
+			{"monitor": "Static.*", "mode": "on_change", "heartbeat": "10m"},
+		},
 	}, "."), nil)
 	if err != nil {
 		panic(err)
@@ -25,15 +23,8 @@ func TestMain(m *testing.M) {
 	if err := logging.InitLogging(); err != nil {
 		panic(err)
 	}
-	if err := db.InitDatabase(); err != nil {
-		panic(err)
-	}
 
 	code := m.Run()
 
-	if err := os.Remove("history_test.db"); err != nil {
-		panic(err)
-	}
-
 	os.Exit(code)
 }
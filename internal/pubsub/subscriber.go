@@ -4,39 +4,71 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Listener func(*Message)
 
-// Subscriber can listen to different monitors on a broker. Its messages channel will be updated whenever a new message is published with the associated broker.
+// waitPollInterval is the interval in which WaitProcessed checks whether the queued messages have been processed.
+const waitPollInterval = 5 * time.Millisecond
+
+// Subscriber can listen to different monitors on a broker. Its messages queue will be updated whenever a new message is published with the associated broker.
+// The queue is bounded; if a subscriber does not keep up with the published messages, its DropPolicy decides what happens.
+// Broker implementations create subscribers with NewSubscriber, record their subscriptions with AddMonitor and
+// RemoveMonitor and deliver messages with Signal.
 type Subscriber struct {
-	id       string
-	messages chan *Message
-	monitors map[string]bool
-	active   bool
-	lock     sync.RWMutex
-	wg       sync.WaitGroup
+	id           string
+	hooks        SubscriberHooks
+	messages     chan *Message
+	monitors     map[string]bool
+	active       bool
+	policy       DropPolicy
+	dropped      atomic.Uint64
+	queued       atomic.Uint64
+	processed    atomic.Uint64
+	disconnected atomic.Bool
+	lock         sync.RWMutex
+}
+
+// SubscriberHooks notify the broker of a Subscriber about events of the subscriber. Hooks that are nil are skipped.
+type SubscriberHooks struct {
+	// OnDrop is called for every message dropped because the queue of the subscriber was full.
+	OnDrop func()
+	// OnDestruct is called on every call of Destruct, so that the broker stops delivering messages to the subscriber.
+	OnDestruct func(*Subscriber)
 }
 
-func newSubscriber() (string, *Subscriber) {
-	id := uuid.New().String()
+// NewSubscriber creates a Subscriber with a queue of queueSize messages, which are handled according to policy
+// once the queue is full.
+func NewSubscriber(queueSize int, policy DropPolicy, hooks SubscriberHooks) *Subscriber {
+	initLogger()
 
-	return id, &Subscriber{
-		id:       id,
-		messages: make(chan *Message),
+	return &Subscriber{
+		id:       uuid.New().String(),
+		hooks:    hooks,
+		messages: make(chan *Message, queueSize),
 		monitors: map[string]bool{},
 		active:   true,
+		policy:   policy,
 	}
 }
 
-func (subscriber *Subscriber) addMonitor(monitor string) {
+// GetID returns the unique id of the Subscriber.
+func (subscriber *Subscriber) GetID() string {
+	return subscriber.id
+}
+
+// AddMonitor records that the Subscriber has been subscribed to a monitor or a monitor pattern.
+func (subscriber *Subscriber) AddMonitor(monitor string) {
 	subscriber.lock.Lock()
 	defer subscriber.lock.Unlock()
 
 	subscriber.monitors[monitor] = true
 }
 
-func (subscriber *Subscriber) removeMonitor(monitor string) {
+// RemoveMonitor records that the Subscriber has been unsubscribed from a monitor or a monitor pattern.
+func (subscriber *Subscriber) RemoveMonitor(monitor string) {
 	subscriber.lock.Lock()
 	defer subscriber.lock.Unlock()
 
@@ -56,43 +88,113 @@ func (subscriber *Subscriber) GetMonitors() []string {
 	return monitors
 }
 
-func (subscriber *Subscriber) signal(message *Message) {
-	subscriber.lock.RLock()
-	defer subscriber.lock.RUnlock()
+// GetDropped returns the number of messages that have been dropped because the subscriber's queue was full.
+func (subscriber *Subscriber) GetDropped() uint64 {
+	return subscriber.dropped.Load()
+}
+
+// IsDisconnected returns whether the subscriber has been disconnected by the DropDisconnect policy.
+func (subscriber *Subscriber) IsDisconnected() bool {
+	return subscriber.disconnected.Load()
+}
+
+// Signal enqueues a message without blocking the publisher.
+// If the queue is full, the message is handled according to the subscriber's DropPolicy.
+// Messages signaled after the Subscriber has been destructed are discarded.
+func (subscriber *Subscriber) Signal(message *Message) {
+	subscriber.lock.Lock()
+	defer subscriber.lock.Unlock()
+
+	if !subscriber.active {
+		return
+	}
+
+	select {
+	case subscriber.messages <- message:
+		subscriber.queued.Add(1)
+		return
+	default:
+	}
+
+	switch subscriber.policy {
+	case DropNewest:
+		subscriber.drop()
+	case DropDisconnect:
+		subscriber.drop()
+
+		if subscriber.disconnected.CompareAndSwap(false, true) {
+			logger.Warn(fmt.Sprintf("Disconnecting subscriber %s as it does not keep up with the published messages.", subscriber.id))
+			go subscriber.Destruct()
+		}
+	default:
+		// The listener might have taken a message in the meantime, so dropping the oldest one must not block.
+		select {
+		case <-subscriber.messages:
+			subscriber.drop()
+			subscriber.processed.Add(1)
+		default:
+		}
+
+		select {
+		case subscriber.messages <- message:
+			subscriber.queued.Add(1)
+		default:
+			subscriber.drop()
+		}
+	}
+}
 
-	if subscriber.active {
-		subscriber.wg.Add(1)
-		defer subscriber.wg.Done()
+// drop counts a dropped message on the subscriber and notifies its broker.
+func (subscriber *Subscriber) drop() {
+	subscriber.dropped.Add(1)
 
-		subscriber.messages <- message
+	if subscriber.hooks.OnDrop != nil {
+		subscriber.hooks.OnDrop()
 	}
 }
 
-// Listen listens for messages on the messages channel and calls a Listener function with the message as an argument.
+// Listen listens for messages on the messages queue and calls a Listener function with the message as an argument.
+// It returns once the Subscriber has been destructed and all queued messages have been handled.
 func (subscriber *Subscriber) Listen(listener Listener) {
-	for {
-		if !subscriber.active {
-			break
-		}
+	for message := range subscriber.messages {
+		logger.Trace(fmt.Sprintf("Subscriber %s received message from %s.", subscriber.id, message.GetMonitor()))
+		listener(message)
+		subscriber.processed.Add(1)
+	}
+}
 
-		if message, ok := <-subscriber.messages; ok {
-			logger.Trace(fmt.Sprintf("Subscriber %s received message from %s.", subscriber.id, message.GetMonitor()))
-			listener(message)
+// WaitProcessed waits until all messages queued before the call have been handled by the listener or dropped.
+// It returns false if that didn't happen within timeout.
+func (subscriber *Subscriber) WaitProcessed(timeout time.Duration) bool {
+	queued := subscriber.queued.Load()
+	deadline := time.Now().Add(timeout)
+
+	for subscriber.processed.Load() < queued {
+		if time.Now().After(deadline) {
+			return false
 		}
+
+		time.Sleep(waitPollInterval)
 	}
+
+	return true
 }
 
 // Destruct destructs a Subscriber
 func (subscriber *Subscriber) Destruct() {
-	subscriber.lock.RLock()
-	defer subscriber.lock.RUnlock()
+	if subscriber.hooks.OnDestruct != nil {
+		subscriber.hooks.OnDestruct(subscriber)
+	}
+
+	subscriber.lock.Lock()
+	defer subscriber.lock.Unlock()
+
+	if !subscriber.active {
+		return
+	}
 
 	logger.Trace(fmt.Sprintf("Destructing subscriber %s", subscriber.id))
 
 	subscriber.active = false
-
-	go func() {
-		subscriber.wg.Wait()
-		close(subscriber.messages)
-	}()
+	close(subscriber.messages)
 }
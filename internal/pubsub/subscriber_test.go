@@ -0,0 +1,156 @@
+package pubsub
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// drain returns the bodies of all messages currently queued for the subscriber.
+func drain(subscriber *Subscriber) []string {
+	var bodies []string
+	for {
+		select {
+		case message := <-subscriber.messages:
+			bodies = append(bodies, message.GetMessageBody())
+		default:
+			return bodies
+		}
+	}
+}
+
+func TestParseDropPolicy(t *testing.T) {
+	for _, policy := range []DropPolicy{DropOldest, DropNewest, DropDisconnect} {
+		parsed, err := ParseDropPolicy(string(policy))
+		assert.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+
+	_, err := ParseDropPolicy("drop_everything")
+	assert.ErrorIs(t, err, ErrInvalidDropPolicy)
+}
+
+func TestDropOldest(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriberWithQueue(2, DropOldest)
+	assert.NoError(t, broker.Subscribe(sub, "Monitor"))
+
+	for i := 0; i < 5; i++ {
+		broker.Publish("Monitor", fmt.Sprint(i))
+	}
+
+	assert.Equal(t, []string{"3", "4"}, drain(sub))
+	assert.EqualValues(t, 3, sub.GetDropped())
+	assert.EqualValues(t, 3, broker.GetDropped())
+}
+
+func TestDropNewest(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriberWithQueue(2, DropNewest)
+	assert.NoError(t, broker.Subscribe(sub, "Monitor"))
+
+	for i := 0; i < 5; i++ {
+		broker.Publish("Monitor", fmt.Sprint(i))
+	}
+
+	assert.Equal(t, []string{"0", "1"}, drain(sub))
+	assert.EqualValues(t, 3, sub.GetDropped())
+}
+
+func TestDropDisconnect(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriberWithQueue(2, DropDisconnect)
+	assert.NoError(t, broker.Subscribe(sub, "Monitor"))
+
+	for i := 0; i < 3; i++ {
+		broker.Publish("Monitor", fmt.Sprint(i))
+	}
+
+	assert.True(t, sub.IsDisconnected())
+	assert.EqualValues(t, 1, sub.GetDropped())
+
+	done := make(chan []string)
+	go func() {
+		var bodies []string
+		sub.Listen(func(message *Message) {
+			bodies = append(bodies, message.GetMessageBody())
+		})
+
+		done <- bodies
+	}()
+
+	select {
+	case bodies := <-done:
+		assert.Equal(t, []string{"0", "1"}, bodies)
+	case <-time.After(1 * time.Second):
+		t.Fatal("Disconnected subscriber did not stop listening...")
+	}
+
+	assert.Equal(t, 0, broker.GetSubscriberCount())
+}
+
+func TestDestructTwice(t *testing.T) {
+	broker := NewBroker()
+	sub := broker.AddSubscriber()
+
+	sub.Destruct()
+	assert.NotPanics(t, sub.Destruct)
+}
+
+func TestWaitProcessed(t *testing.T) {
+	broker := NewBroker()
+	subscriber := broker.AddSubscriberWithQueue(16, DropOldest)
+	defer subscriber.Destruct()
+
+	require.NoError(t, broker.Subscribe(subscriber, "Some.Monitor"))
+
+	for i := 0; i < 8; i++ {
+		broker.Publish("Some.Monitor", "Value")
+	}
+
+	// Nobody is listening yet, so the queued messages can't be processed.
+	assert.False(t, subscriber.WaitProcessed(20*time.Millisecond))
+
+	var handled atomic.Int32
+	go subscriber.Listen(func(message *Message) {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+	})
+
+	assert.True(t, subscriber.WaitProcessed(time.Second))
+	assert.Equal(t, int32(8), handled.Load())
+}
+
+func TestNewSubscriber(t *testing.T) {
+	var dropped, destructed atomic.Int32
+	var subscriber *Subscriber
+
+	subscriber = NewSubscriber(1, DropNewest, SubscriberHooks{
+		OnDrop: func() { dropped.Add(1) },
+		OnDestruct: func(destructing *Subscriber) {
+			assert.Equal(t, subscriber.GetID(), destructing.GetID())
+			destructed.Add(1)
+		},
+	})
+
+	subscriber.AddMonitor("CPU.*")
+	assert.Equal(t, []string{"CPU.*"}, subscriber.GetMonitors())
+
+	subscriber.Signal(NewMessage("First", "CPU.Usage"))
+	subscriber.Signal(NewMessage("Second", "CPU.Usage"))
+
+	assert.Equal(t, []string{"First"}, drain(subscriber))
+	assert.EqualValues(t, 1, dropped.Load())
+
+	subscriber.Destruct()
+	assert.EqualValues(t, 1, destructed.Load())
+
+	// Messages signaled after destruction are discarded.
+	assert.NotPanics(t, func() { subscriber.Signal(NewMessage("Third", "CPU.Usage")) })
+
+	subscriber.RemoveMonitor("CPU.*")
+	assert.Empty(t, subscriber.GetMonitors())
+}
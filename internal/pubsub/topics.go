@@ -0,0 +1,165 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// TopicSeparator separates the levels of a monitor name, e.g. CPU.Usage.
+	TopicSeparator = "."
+	// SingleLevelWildcard matches exactly one level of a monitor name.
+	SingleLevelWildcard = "*"
+	// MultiLevelWildcard matches all remaining levels of a monitor name, including none at all.
+	MultiLevelWildcard = "#"
+)
+
+var ErrInvalidPattern = errors.New("invalid topic pattern")
+
+// ValidatePattern checks whether a pattern is a valid monitor name or subscription pattern.
+// Wildcards have to occupy a whole level and the multi-level wildcard may only be used as the last level.
+func ValidatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("%w: pattern is empty", ErrInvalidPattern)
+	}
+
+	levels := strings.Split(pattern, TopicSeparator)
+	for i, level := range levels {
+		if level == MultiLevelWildcard && i != len(levels)-1 {
+			return fmt.Errorf("%w: %s may only be used as the last level in %s", ErrInvalidPattern, MultiLevelWildcard, pattern)
+		}
+
+		if level != SingleLevelWildcard && level != MultiLevelWildcard &&
+			strings.ContainsAny(level, SingleLevelWildcard+MultiLevelWildcard) {
+			return fmt.Errorf("%w: wildcards have to occupy a whole level in %s", ErrInvalidPattern, pattern)
+		}
+	}
+
+	return nil
+}
+
+// IsPattern returns whether the given monitor name contains wildcards.
+func IsPattern(pattern string) bool {
+	for _, level := range strings.Split(pattern, TopicSeparator) {
+		if level == SingleLevelWildcard || level == MultiLevelWildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchTopic returns whether the monitor name topic is matched by pattern.
+func MatchTopic(pattern string, topic string) bool {
+	return matchLevels(strings.Split(pattern, TopicSeparator), strings.Split(topic, TopicSeparator))
+}
+
+func matchLevels(pattern []string, topic []string) bool {
+	for i, level := range pattern {
+		if level == MultiLevelWildcard {
+			return true
+		}
+
+		if i >= len(topic) || (level != SingleLevelWildcard && level != topic[i]) {
+			return false
+		}
+	}
+
+	return len(pattern) == len(topic)
+}
+
+// topicTrie stores subscribers by the levels of the patterns they are subscribed to.
+// Looking up the subscribers of a monitor only visits the branches that can match it,
+// independent of how many patterns are subscribed in total.
+type topicTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children    map[string]*trieNode
+	subscribers Subscribers
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children:    map[string]*trieNode{},
+		subscribers: Subscribers{},
+	}
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: newTrieNode()}
+}
+
+// insert adds a subscriber to the node of pattern.
+func (trie *topicTrie) insert(pattern string, subscriber *Subscriber) {
+	node := trie.root
+	for _, level := range strings.Split(pattern, TopicSeparator) {
+		child, ok := node.children[level]
+		if !ok {
+			child = newTrieNode()
+			node.children[level] = child
+		}
+
+		node = child
+	}
+
+	node.subscribers[subscriber.id] = subscriber
+}
+
+// remove removes a subscriber from the node of pattern and prunes branches that became empty.
+func (trie *topicTrie) remove(pattern string, subscriber *Subscriber) {
+	removeFromNode(trie.root, strings.Split(pattern, TopicSeparator), subscriber)
+}
+
+func removeFromNode(node *trieNode, levels []string, subscriber *Subscriber) {
+	if len(levels) == 0 {
+		delete(node.subscribers, subscriber.id)
+		return
+	}
+
+	child, ok := node.children[levels[0]]
+	if !ok {
+		return
+	}
+
+	removeFromNode(child, levels[1:], subscriber)
+
+	if len(child.children) == 0 && len(child.subscribers) == 0 {
+		delete(node.children, levels[0])
+	}
+}
+
+// match returns all subscribers subscribed to a pattern matching topic.
+// Subscribers that are subscribed to multiple matching patterns are only contained once.
+func (trie *topicTrie) match(topic string) Subscribers {
+	result := Subscribers{}
+	matchNode(trie.root, strings.Split(topic, TopicSeparator), result)
+
+	return result
+}
+
+func matchNode(node *trieNode, levels []string, result Subscribers) {
+	if child, ok := node.children[MultiLevelWildcard]; ok {
+		for id, subscriber := range child.subscribers {
+			result[id] = subscriber
+		}
+	}
+
+	if len(levels) == 0 {
+		for id, subscriber := range node.subscribers {
+			result[id] = subscriber
+		}
+
+		return
+	}
+
+	if child, ok := node.children[levels[0]]; ok {
+		matchNode(child, levels[1:], result)
+	}
+
+	if child, ok := node.children[SingleLevelWildcard]; ok {
+		matchNode(child, levels[1:], result)
+	}
+}
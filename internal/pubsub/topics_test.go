@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{"CPU.Usage", "CPU.*", "Memory.#", "*.Usage", "#", "*.*"} {
+		assert.NoError(t, ValidatePattern(pattern), pattern)
+	}
+
+	for _, pattern := range []string{"", "#.Usage", "CPU.#.Usage", "CPU.Us*", "Memory.Swap#"} {
+		assert.ErrorIs(t, ValidatePattern(pattern), ErrInvalidPattern, pattern)
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	assert.False(t, IsPattern("CPU.Usage"))
+	assert.True(t, IsPattern("CPU.*"))
+	assert.True(t, IsPattern("Memory.#"))
+}
+
+func TestMatchTopic(t *testing.T) {
+	type testParams struct {
+		pattern string
+		topic   string
+		matches bool
+	}
+
+	for _, params := range []testParams{
+		{"CPU.Usage", "CPU.Usage", true},
+		{"CPU.Usage", "CPU.CpuInfo", false},
+		{"CPU.*", "CPU.Usage", true},
+		{"CPU.*", "CPU", false},
+		{"CPU.*", "CPU.Usage.Core0", false},
+		{"*.Usage", "CPU.Usage", true},
+		{"*.Usage", "Memory.MemInfo", false},
+		{"Memory.#", "Memory.SwapInfo", true},
+		{"Memory.#", "Memory", true},
+		{"Memory.#", "Memory.Swap.Info", true},
+		{"Memory.#", "CPU.Usage", false},
+		{"#", "CPU.Usage", true},
+	} {
+		assert.Equal(t, params.matches, MatchTopic(params.pattern, params.topic), "%s matching %s", params.pattern, params.topic)
+	}
+}
+
+func TestTopicTrie(t *testing.T) {
+	broker := NewBroker()
+	exact := broker.AddSubscriber()
+	module := broker.AddSubscriber()
+	all := broker.AddSubscriber()
+
+	trie := newTopicTrie()
+	trie.insert("CPU.Usage", exact)
+	trie.insert("CPU.*", module)
+	trie.insert("CPU.Usage", module)
+	trie.insert("#", all)
+
+	matched := trie.match("CPU.Usage")
+	assert.Len(t, matched, 3)
+
+	matched = trie.match("CPU.CpuInfo")
+	assert.Len(t, matched, 2)
+	assert.Contains(t, matched, module.id)
+	assert.Contains(t, matched, all.id)
+
+	matched = trie.match("Memory.MemInfo")
+	assert.Len(t, matched, 1)
+	assert.Contains(t, matched, all.id)
+
+	trie.remove("CPU.*", module)
+	trie.remove("#", all)
+	trie.remove("Not.Subscribed", exact)
+
+	matched = trie.match("CPU.CpuInfo")
+	assert.Empty(t, matched)
+	assert.NotContains(t, trie.root.children, "#")
+	assert.NotContains(t, trie.root.children["CPU"].children, "*")
+
+	matched = trie.match("CPU.Usage")
+	assert.Len(t, matched, 2)
+}
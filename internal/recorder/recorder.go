@@ -0,0 +1,295 @@
+// Package recorder persists the messages published on the broker to the history database.
+// It is a regular broker subscriber with its own queue, so publishers are never slowed down by the database.
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/config"
+	ctx "github.com/Excubitor-Monitoring/Excubitor-Backend/internal/context"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/logging"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var logger logging.Logger
+
+var ErrInvalidConfiguration = errors.New("invalid recorder configuration")
+var ErrSyncTimeout = errors.New("timeout while syncing recorder")
+
+// active is the recorder started from the configuration.
+var active atomic.Pointer[Recorder]
+
+// Writer persists batches of history entries. It is implemented by db.Storage.
+type Writer interface {
+	AddHistoryEntries(entries []db.HistoryEntry) error
+}
+
+// Options configure a Recorder.
+type Options struct {
+	// Include contains the monitor patterns to record. Defaults to all monitors.
+	Include []string
+	// Exclude contains the monitor patterns not to record, even if they are matched by Include.
+	Exclude []string
+	// QueueSize is the size of the recorder's subscriber queue.
+	QueueSize int
+	// BatchSize is the number of messages after which a batch is written.
+	BatchSize int
+	// FlushInterval is the maximum time a message waits before its batch is written.
+	FlushInterval time.Duration
+}
+
+// Recorder writes the messages published on a broker to the history in batches.
+type Recorder struct {
+	broker     pubsub.Broker
+	writer     Writer
+	subscriber *pubsub.Subscriber
+	options    Options
+	batch      []db.HistoryEntry
+	lock       sync.Mutex
+	listening  sync.WaitGroup
+	stop       chan bool
+	done       chan bool
+}
+
+// Start creates a Recorder from the configuration and starts recording the messages published on the context's broker.
+// Changes to the include and exclude patterns are applied on configuration reloads.
+func Start() (*Recorder, error) {
+	options, err := readOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	recorder, err := New(ctx.GetContext().GetBroker(), db.GetStorage(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	config.RegisterReloadHook(func() {
+		options, err := readOptions()
+		if err != nil {
+			logger.Error(fmt.Sprintf("Could not reload recorder configuration, keeping the current one! Reason: %s", err))
+			return
+		}
+
+		recorder.SetFilters(options.Include, options.Exclude)
+	})
+
+	recorder.Start()
+	active.Store(recorder)
+
+	return recorder, nil
+}
+
+// GetRecorder returns the recorder started from the configuration or nil if none has been started.
+func GetRecorder() *Recorder {
+	return active.Load()
+}
+
+// readOptions reads the recorder options from the configuration.
+func readOptions() (Options, error) {
+	conf := config.GetConfig()
+
+	flushInterval, err := time.ParseDuration(conf.String("recorder.flush_interval"))
+	if err != nil {
+		return Options{}, fmt.Errorf("%w: flush interval: %s", ErrInvalidConfiguration, err)
+	}
+
+	return Options{
+		Include:       conf.Strings("recorder.include"),
+		Exclude:       conf.Strings("recorder.exclude"),
+		QueueSize:     conf.Int("recorder.queue_size"),
+		BatchSize:     conf.Int("recorder.batch_size"),
+		FlushInterval: flushInterval,
+	}, nil
+}
+
+// New constructs a Recorder writing the messages published on broker to writer.
+func New(broker pubsub.Broker, writer Writer, options Options) (*Recorder, error) {
+	logger = logging.GetLogger()
+
+	if len(options.Include) == 0 {
+		options.Include = []string{pubsub.MultiLevelWildcard}
+	}
+
+	if err := validatePatterns(options.Include, options.Exclude); err != nil {
+		return nil, err
+	}
+
+	if options.QueueSize < 1 {
+		return nil, fmt.Errorf("%w: queue size needs to be at least 1", ErrInvalidConfiguration)
+	}
+
+	if options.BatchSize < 1 {
+		return nil, fmt.Errorf("%w: batch size needs to be at least 1", ErrInvalidConfiguration)
+	}
+
+	if options.FlushInterval <= 0 {
+		return nil, fmt.Errorf("%w: flush interval needs to be positive", ErrInvalidConfiguration)
+	}
+
+	return &Recorder{
+		broker:  broker,
+		writer:  writer,
+		options: options,
+		stop:    make(chan bool),
+		done:    make(chan bool),
+	}, nil
+}
+
+// validatePatterns checks whether all include and exclude patterns are valid.
+func validatePatterns(include []string, exclude []string) error {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if err := pubsub.ValidatePattern(pattern); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidConfiguration, err)
+		}
+	}
+
+	return nil
+}
+
+// SetFilters replaces the include and exclude patterns of the recorder.
+// Invalid patterns are rejected and the current ones are kept.
+func (recorder *Recorder) SetFilters(include []string, exclude []string) {
+	if len(include) == 0 {
+		include = []string{pubsub.MultiLevelWildcard}
+	}
+
+	if err := validatePatterns(include, exclude); err != nil {
+		logger.Error(fmt.Sprintf("Could not apply recorder filters, keeping the current ones! Reason: %s", err))
+		return
+	}
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	recorder.options.Include = include
+	recorder.options.Exclude = exclude
+}
+
+// Start subscribes the recorder to all monitors and starts writing batches.
+func (recorder *Recorder) Start() {
+	recorder.subscriber = recorder.broker.AddSubscriberWithQueue(recorder.options.QueueSize, pubsub.DropOldest)
+
+	if err := recorder.broker.Subscribe(recorder.subscriber, pubsub.MultiLevelWildcard); err != nil {
+		logger.Error(fmt.Sprintf("Could not subscribe recorder! Reason: %s", err))
+		return
+	}
+
+	recorder.listening.Add(1)
+	go func() {
+		defer recorder.listening.Done()
+		recorder.subscriber.Listen(recorder.handle)
+	}()
+
+	go recorder.flushCycle()
+}
+
+// Stop unsubscribes the recorder and writes all pending messages.
+func (recorder *Recorder) Stop() {
+	recorder.subscriber.Destruct()
+	recorder.listening.Wait()
+
+	recorder.stop <- true
+	<-recorder.done
+
+	recorder.Flush()
+}
+
+// Sync writes all messages published before the call to the history, so that they can be read back right away.
+// It returns ErrSyncTimeout if the recorder doesn't catch up within timeout.
+func (recorder *Recorder) Sync(timeout time.Duration) error {
+	// The subscriber's queue is filled synchronously on publish, so once the messages queued up to now are processed,
+	// every message published before this call has been added to the batch.
+	if !recorder.subscriber.WaitProcessed(timeout) {
+		return ErrSyncTimeout
+	}
+
+	recorder.Flush()
+
+	return nil
+}
+
+// GetDropped returns the number of messages that could not be recorded because the recorder's queue was full.
+func (recorder *Recorder) GetDropped() uint64 {
+	return recorder.subscriber.GetDropped()
+}
+
+// handle adds a message to the current batch if it is to be recorded and writes the batch once it is full.
+func (recorder *Recorder) handle(message *pubsub.Message) {
+	recorder.lock.Lock()
+
+	if !recorder.records(message.GetMonitor()) {
+		recorder.lock.Unlock()
+		return
+	}
+
+	recorder.batch = append(recorder.batch, db.HistoryEntry{
+		Timestamp: message.GetTimestamp(),
+		Target:    message.GetMonitor(),
+		Content:   message.GetMessageBody(),
+		Host:      message.GetHost(),
+		Labels:    message.GetLabels(),
+	})
+
+	full := len(recorder.batch) >= recorder.options.BatchSize
+	recorder.lock.Unlock()
+
+	if full {
+		recorder.Flush()
+	}
+}
+
+// records returns whether messages of the monitor are to be recorded. The caller has to hold the lock.
+func (recorder *Recorder) records(monitor string) bool {
+	for _, pattern := range recorder.options.Exclude {
+		if pubsub.MatchTopic(pattern, monitor) {
+			return false
+		}
+	}
+
+	for _, pattern := range recorder.options.Include {
+		if pubsub.MatchTopic(pattern, monitor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flushCycle writes the current batch every flush interval until the recorder is stopped.
+func (recorder *Recorder) flushCycle() {
+	ticker := time.NewTicker(recorder.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			recorder.Flush()
+		case <-recorder.stop:
+			recorder.done <- true
+			return
+		}
+	}
+}
+
+// Flush writes all pending messages to the history.
+func (recorder *Recorder) Flush() {
+	recorder.lock.Lock()
+	batch := recorder.batch
+	recorder.batch = nil
+	recorder.lock.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	logger.Trace(fmt.Sprintf("Recording %d messages.", len(batch)))
+
+	if err := recorder.writer.AddHistoryEntries(batch); err != nil {
+		logger.Error(fmt.Sprintf("Could not record %d messages! Reason: %s", len(batch), err))
+	}
+}
@@ -0,0 +1,163 @@
+package recorder
+
+import (
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/db"
+	"github.com/Excubitor-Monitoring/Excubitor-Backend/internal/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryWriter records all batches written to it.
+type memoryWriter struct {
+	batches [][]db.HistoryEntry
+	lock    sync.Mutex
+}
+
+func (writer *memoryWriter) AddHistoryEntries(entries []db.HistoryEntry) error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	writer.batches = append(writer.batches, entries)
+	return nil
+}
+
+func (writer *memoryWriter) getBatches() [][]db.HistoryEntry {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	return writer.batches
+}
+
+func (writer *memoryWriter) getTargets() []string {
+	var targets []string
+	for _, batch := range writer.getBatches() {
+		for _, entry := range batch {
+			targets = append(targets, entry.Target)
+		}
+	}
+
+	return targets
+}
+
+func TestNewNegative(t *testing.T) {
+	valid := Options{QueueSize: 16, BatchSize: 10, FlushInterval: time.Second}
+
+	for description, modify := range map[string]func(options *Options){
+		"Invalid include pattern": func(options *Options) { options.Include = []string{"CPU.#.Usage"} },
+		"Invalid exclude pattern": func(options *Options) { options.Exclude = []string{"CPU.Us*"} },
+		"Queue size":              func(options *Options) { options.QueueSize = 0 },
+		"Batch size":              func(options *Options) { options.BatchSize = 0 },
+		"Flush interval":          func(options *Options) { options.FlushInterval = 0 },
+	} {
+		t.Run(description, func(t *testing.T) {
+			options := valid
+			modify(&options)
+
+			_, err := New(pubsub.NewBroker(), &memoryWriter{}, options)
+			assert.ErrorIs(t, err, ErrInvalidConfiguration)
+		})
+	}
+}
+
+func TestRecordBatches(t *testing.T) {
+	broker := pubsub.NewBroker()
+	writer := &memoryWriter{}
+
+	recorder, err := New(broker, writer, Options{QueueSize: 16, BatchSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	recorder.Start()
+
+	broker.Publish("CPU.Usage", "1")
+	broker.Publish("CPU.Usage", "2")
+	broker.Publish("Memory.MemInfo", "3")
+
+	assert.Eventually(t, func() bool {
+		return len(writer.getBatches()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	batch := writer.getBatches()[0]
+	require.Len(t, batch, 2)
+	assert.Equal(t, "CPU.Usage", batch[0].Target)
+	assert.Equal(t, "1", batch[0].Content)
+	assert.False(t, batch[0].Timestamp.IsZero())
+	assert.Equal(t, "2", batch[1].Content)
+
+	recorder.Stop()
+
+	assert.Equal(t, []string{"CPU.Usage", "CPU.Usage", "Memory.MemInfo"}, writer.getTargets())
+}
+
+func TestRecordFlushInterval(t *testing.T) {
+	broker := pubsub.NewBroker()
+	writer := &memoryWriter{}
+
+	recorder, err := New(broker, writer, Options{QueueSize: 16, BatchSize: 100, FlushInterval: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	recorder.Start()
+	defer recorder.Stop()
+
+	broker.Publish("CPU.Usage", "1")
+
+	assert.Eventually(t, func() bool {
+		return len(writer.getTargets()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRecordFilters(t *testing.T) {
+	broker := pubsub.NewBroker()
+	writer := &memoryWriter{}
+
+	recorder, err := New(broker, writer, Options{
+		Include:       []string{"CPU.#", "Memory.*"},
+		Exclude:       []string{"CPU.CpuInfo"},
+		QueueSize:     16,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	recorder.Start()
+
+	for _, monitor := range []string{"CPU.Usage", "CPU.CpuInfo", "Memory.MemInfo", "Excubitor.Runtime"} {
+		broker.Publish(monitor, "Value")
+	}
+
+	// Wait until the recorder has handled all messages before changing the filters.
+	time.Sleep(100 * time.Millisecond)
+
+	recorder.SetFilters([]string{"Excubitor.#"}, nil)
+	broker.Publish("CPU.Usage", "Value")
+	broker.Publish("Excubitor.Runtime", "Value")
+
+	time.Sleep(100 * time.Millisecond)
+
+	recorder.SetFilters([]string{"CPU.##"}, nil)
+	broker.Publish("Excubitor.Database", "Value")
+
+	recorder.Stop()
+
+	assert.Equal(t, []string{"CPU.Usage", "Memory.MemInfo", "Excubitor.Runtime", "Excubitor.Database"}, writer.getTargets())
+}
+
+func TestSync(t *testing.T) {
+	broker := pubsub.NewBroker()
+	writer := &memoryWriter{}
+
+	recorder, err := New(broker, writer, Options{QueueSize: 1024, BatchSize: 1000, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	recorder.Start()
+	defer recorder.Stop()
+
+	for i := 0; i < 500; i++ {
+		broker.Publish("CPU.Usage", "Value")
+	}
+
+	require.NoError(t, recorder.Sync(time.Second))
+	assert.Len(t, writer.getTargets(), 500)
+}